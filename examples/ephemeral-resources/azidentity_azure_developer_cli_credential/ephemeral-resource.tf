@@ -0,0 +1,15 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# Requires a prior `azd auth login`
+ephemeral "azidentity_azure_developer_cli_credential" "this" {
+  scopes = ["https://management.azure.com/.default"]
+}
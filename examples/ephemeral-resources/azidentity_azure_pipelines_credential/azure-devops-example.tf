@@ -0,0 +1,37 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azuredevops = {
+      source  = "microsoft/azuredevops"
+      version = "1.6.0"
+    }
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+ephemeral "azidentity_environment_variable" "system_collectionuri" {
+  key = "SYSTEM_COLLECTIONURI"
+}
+
+locals {
+  azure_devops_app_id = "499b84ac-1321-427f-aa17-267ca6975798" # Azure DevOps Application ID in Entra
+}
+
+ephemeral "azidentity_azure_pipelines_credential" "this" {
+  scopes = ["${local.azure_devops_app_id}/.default"]
+}
+
+provider "azuredevops" {
+  org_service_url       = ephemeral.azidentity_environment_variable.system_collectionuri.value
+  personal_access_token = ephemeral.azidentity_azure_pipelines_credential.this.access_token
+}
+
+data "azuredevops_projects" "this" {}
+
+output "projects" {
+  value = data.azuredevops_projects.this
+}
@@ -0,0 +1,24 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# Uses AZURESUBSCRIPTION_TENANT_ID, AZURESUBSCRIPTION_CLIENT_ID, AZURESUBSCRIPTION_SERVICE_CONNECTION_ID
+# and SYSTEM_ACCESSTOKEN as set in an AzureCLI@2 task
+ephemeral "azidentity_azure_pipelines_credential" "this" {
+  scopes = ["https://management.azure.com/.default"]
+}
+
+# Explicit configuration
+ephemeral "azidentity_azure_pipelines_credential" "explicit" {
+  tenant_id             = "00000000-0000-0000-0000-000000000000"
+  client_id             = "00000000-0000-0000-0000-000000000000"
+  service_connection_id = "00000000-0000-0000-0000-000000000000"
+  scopes                = ["https://management.azure.com/.default"]
+}
@@ -0,0 +1,23 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# Uses workload identity when running in AKS, falling back to the Azure CLI locally
+ephemeral "azidentity_chained_credential" "this" {
+  sources = [
+    {
+      workload_identity = {}
+    },
+    {
+      azure_cli = {}
+    },
+  ]
+  scopes = ["https://management.azure.com/.default"]
+}
@@ -0,0 +1,28 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# PEM encoded certificate and private key
+ephemeral "azidentity_client_certificate_credential" "pem" {
+  tenant_id   = "00000000-0000-0000-0000-000000000000"
+  client_id   = "00000000-0000-0000-0000-000000000000"
+  certificate = file("${path.module}/certificate.pem")
+  scopes      = ["https://management.azure.com/.default"]
+}
+
+# Password protected PKCS#12 archive
+ephemeral "azidentity_client_certificate_credential" "pkcs12" {
+  tenant_id              = "00000000-0000-0000-0000-000000000000"
+  client_id              = "00000000-0000-0000-0000-000000000000"
+  certificate            = filebase64("${path.module}/certificate.pfx")
+  certificate_password   = "supersecret"
+  send_certificate_chain = true
+  scopes                 = ["https://management.azure.com/.default"]
+}
@@ -15,3 +15,16 @@ ephemeral "azidentity_client_secret_credential" "this" {
   client_secret = "supersecret"
   scopes        = ["https://management.azure.com/.default"]
 }
+
+# Assert on the identity of the token, decoded from its claims
+check "identity" {
+  assert {
+    condition     = ephemeral.azidentity_client_secret_credential.this.token_tenant_id == "00000000-0000-0000-0000-000000000000"
+    error_message = "The token was issued by an unexpected tenant"
+  }
+
+  assert {
+    condition     = ephemeral.azidentity_client_secret_credential.this.token_audience == "https://management.azure.com"
+    error_message = "The token was issued for an unexpected audience"
+  }
+}
@@ -0,0 +1,20 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# Uses AZURE_TENANT_ID and AZURE_CLIENT_ID together with AZURE_CLIENT_SECRET,
+# AZURE_CLIENT_CERTIFICATE_PATH or AZURE_USERNAME and AZURE_PASSWORD
+ephemeral "azidentity_environment_credential" "this" {
+  scopes = ["https://management.azure.com/.default"]
+}
+
+output "mode" {
+  value = ephemeral.azidentity_environment_credential.this.mode
+}
@@ -0,0 +1,25 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# Requires the workflow to be granted `permissions: id-token: write`. The ID token
+# is requested from ACTIONS_ID_TOKEN_REQUEST_URL using ACTIONS_ID_TOKEN_REQUEST_TOKEN.
+ephemeral "azidentity_github_actions_credential" "this" {
+  tenant_id = "00000000-0000-0000-0000-000000000000"
+  client_id = "00000000-0000-0000-0000-000000000000"
+  scopes    = ["https://management.azure.com/.default"]
+}
+
+# Uses AZURE_TENANT_ID and AZURE_CLIENT_ID, with a custom audience configured
+# on the federated identity credential
+ephemeral "azidentity_github_actions_credential" "custom_audience" {
+  audience = "api://ze-custom-audience"
+  scopes   = ["https://management.azure.com/.default"]
+}
@@ -15,20 +15,91 @@ ephemeral "azidentity_http_request" "this" {
   request_headers = {
     "Accept" = "application/json"
   }
-}
 
-locals {
-  response_body = jsondecode(ephemeral.azidentity_http_request.this.response_body)
+  # Retry network errors and transient status codes, such as 503 Service
+  # Unavailable, honouring Retry-After headers up to retry_max_delay. All
+  # attempts are bounded by the timeout.
+  retry_max_attempts = 3
+  retry_min_delay    = "1s"
+  retry_max_delay    = "10s"
+  timeout            = "1m"
+
+  # Fail with the status code and the start of the response body, instead of
+  # failing later on an unexpected response body.
+  expected_status_codes = ["2xx"]
+
+  # Extract values from the JSON response body into response_values, failing
+  # with a descriptive error when a value is missing.
+  response_json_paths = {
+    ip = "$.ip"
+  }
+
+  # Decode the whole JSON response body into response_json.
+  decode_response_json = true
 }
 
 check "ip" {
   assert {
-    condition     = local.response_body.ip == "127.0.0.1"
+    condition     = ephemeral.azidentity_http_request.this.response_values["ip"] == "127.0.0.1"
     error_message = "The IP address is not 127.0.0.1"
   }
 
+  # response_json is the response body decoded as JSON, as decode_response_json
+  # is true.
   assert {
-    condition     = local.response_body.bogon == true
+    condition     = ephemeral.azidentity_http_request.this.response_json.bogon == true
     error_message = "The IP address is not a bogon IP address"
   }
 }
+
+# Revoke the session created by the request when the ephemeral resource is
+# closed. A failing close request is reported as a warning.
+ephemeral "azidentity_http_request" "session" {
+  request_url    = "https://api.example.com/sessions"
+  request_method = "POST"
+
+  close_request = {
+    url    = "https://api.example.com/sessions/current"
+    method = "DELETE"
+    headers = {
+      "Accept" = "application/json"
+    }
+  }
+}
+
+# Call Azure Resource Manager with a bearer token acquired by the Azure CLI
+# credential. A claims challenge from the API is retried once with a token
+# satisfying the claims.
+ephemeral "azidentity_http_request" "subscriptions" {
+  request_url    = "https://management.azure.com/subscriptions?api-version=2022-12-01"
+  request_method = "GET"
+
+  azure_auth = {
+    scopes    = ["https://management.azure.com/.default"]
+    azure_cli = {}
+  }
+
+  expected_status_codes = ["200"]
+  response_json_paths = {
+    first_subscription_id = "$.value[0].subscriptionId"
+  }
+}
+
+# Request a token from an OAuth2 token endpoint with a form-encoded body. The
+# fields are URL-encoded and the Content-Type header is set automatically.
+ephemeral "azidentity_http_request" "oauth2_token" {
+  request_url    = "https://login.example.com/oauth2/token"
+  request_method = "POST"
+
+  request_form = {
+    grant_type    = "client_credentials"
+    client_id     = "00000000-0000-0000-0000-000000000000"
+    client_secret = "example-secret"
+    scope         = "api://example/.default"
+  }
+
+  expected_status_codes = ["200"]
+  response_json_paths = {
+    access_token = "$.access_token"
+  }
+}
@@ -0,0 +1,21 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# System-assigned managed identity
+ephemeral "azidentity_managed_identity_credential" "system_assigned" {
+  scopes = ["https://management.azure.com/.default"]
+}
+
+# User-assigned managed identity selected by client ID
+ephemeral "azidentity_managed_identity_credential" "user_assigned" {
+  client_id = "00000000-0000-0000-0000-000000000000"
+  scopes    = ["https://management.azure.com/.default"]
+}
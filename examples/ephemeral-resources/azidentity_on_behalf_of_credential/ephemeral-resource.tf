@@ -0,0 +1,31 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+variable "user_access_token" {
+  type      = string
+  sensitive = true
+  ephemeral = true
+}
+
+variable "client_secret" {
+  type      = string
+  sensitive = true
+  ephemeral = true
+}
+
+# Exchanges the access token of the signed-in user for a Microsoft Graph token on behalf of that user
+ephemeral "azidentity_on_behalf_of_credential" "this" {
+  tenant_id      = "00000000-0000-0000-0000-000000000000"
+  client_id      = "00000000-0000-0000-0000-000000000000"
+  user_assertion = var.user_access_token
+  client_secret  = var.client_secret
+  scopes         = ["https://graph.microsoft.com/.default"]
+}
@@ -0,0 +1,26 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+variable "password" {
+  type      = string
+  sensitive = true
+  ephemeral = true
+}
+
+# Deprecated, only for legacy automation accounts without MFA. Emits a warning
+# every time it's opened.
+ephemeral "azidentity_username_password_credential" "this" {
+  tenant_id = "00000000-0000-0000-0000-000000000000"
+  client_id = "00000000-0000-0000-0000-000000000000"
+  username  = "automation@contoso.com"
+  password  = var.password
+  scopes    = ["https://graph.microsoft.com/.default"]
+}
@@ -0,0 +1,23 @@
+terraform {
+  required_version = ">= 1.10.0"
+  required_providers {
+    azidentity = {
+      source = "co-native-ab/azidentity"
+    }
+  }
+}
+
+provider "azidentity" {}
+
+# Uses AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_FEDERATED_TOKEN_FILE as set by the Azure workload identity webhook
+ephemeral "azidentity_workload_identity_credential" "this" {
+  scopes = ["https://management.azure.com/.default"]
+}
+
+# Explicit configuration
+ephemeral "azidentity_workload_identity_credential" "explicit" {
+  tenant_id       = "00000000-0000-0000-0000-000000000000"
+  client_id       = "00000000-0000-0000-0000-000000000000"
+  token_file_path = "/var/run/secrets/azure/tokens/azure-identity-token"
+  scopes          = ["https://management.azure.com/.default"]
+}
@@ -1 +1,67 @@
 provider "azidentity" {}
+
+# Defaults for all credential resources, overridden by resource-level values.
+# Unset attributes fall back to AZIDENTITY_CLOUD, AZURE_TENANT_ID,
+# AZIDENTITY_TIMEOUT, AZURE_DISABLE_INSTANCE_DISCOVERY and
+# AZURE_ADDITIONALLY_ALLOWED_TENANTS.
+provider "azidentity" {
+  alias     = "defaults"
+  cloud     = "AzurePublic"
+  tenant_id = "00000000-0000-0000-0000-000000000000"
+  timeout   = "1m"
+
+  # Retry throttled and failed token requests, bounded by the timeout
+  max_retries        = 5
+  retry_delay        = "2s"
+  max_retry_delay    = "30s"
+  retry_status_codes = [408, 429, 500, 502, 503, 504]
+
+  # Request cached tokens again when they expire within 10 minutes
+  token_cache_refresh_margin = "10m"
+
+  # Renew credential resources 10 minutes before their token expires
+  renew_margin = "10m"
+}
+
+# A custom cloud, such as Azure Stack Hub with an ADFS authority. Falls back to
+# AZURE_AUTHORITY_HOST when active_directory_authority_host isn't set.
+provider "azidentity" {
+  alias                           = "azure_stack"
+  active_directory_authority_host = "https://adfs.local.azurestack.external/adfs/"
+  disable_instance_discovery      = true
+
+  cloud_services = {
+    resourceManager = {
+      audience = "https://management.adfs.azurestack.local/00000000-0000-0000-0000-000000000000"
+      endpoint = "https://management.local.azurestack.external/"
+    }
+  }
+}
+
+# A cloud loaded from its ARM metadata endpoint, fetched once per provider instance
+provider "azidentity" {
+  alias = "arm_metadata"
+  cloud = "https://management.local.azurestack.external/metadata/endpoints"
+}
+
+# Requests through a TLS inspecting proxy trusting its private root CA, with a
+# client certificate for servers requiring mutual TLS. Falls back to the
+# HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+provider "azidentity" {
+  alias               = "proxy"
+  proxy_url           = "http://proxy.example.com:3128"
+  no_proxy            = "localhost,169.254.169.254,.internal.example.com"
+  ca_certificates_pem = file("${path.module}/proxy-ca.pem")
+  client_certificate  = filebase64("${path.module}/client.pfx")
+}
+
+# Reuse tokens of the client secret and client assertion credentials
+# across Terraform runs. The key is generated with 'openssl rand -base64 32'.
+provider "azidentity" {
+  alias = "persistent_cache"
+
+  persistent_token_cache = {
+    path        = "${path.root}/.terraform/azidentity-token-cache"
+    key_env_var = "AZIDENTITY_TOKEN_CACHE_KEY"
+  }
+}
@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/sync/singleflight"
+)
+
+// cloudMetadataAPIVersion is added to ARM metadata URLs without an api-version.
+const cloudMetadataAPIVersion = "2022-09-01"
+
+// isCloudMetadataURL reports whether a cloud is given as the URL of an ARM
+// metadata endpoint, such as
+// https://management.local.azurestack.external/metadata/endpoints, instead of
+// by name.
+func isCloudMetadataURL(input string) bool {
+	return strings.HasPrefix(input, "https://")
+}
+
+// cloudMetadataCache fetches cloud configurations from ARM metadata endpoints
+// and caches them, so every endpoint is only requested once per provider
+// instance. Concurrent requests of an endpoint share a single fetch, and the
+// mutex only guards the cached configurations, never a fetch.
+type cloudMetadataCache struct {
+	mu         sync.Mutex
+	httpClient *http.Client
+	configs    map[string]cloud.Configuration
+	fetches    singleflight.Group
+}
+
+func newCloudMetadataCache(httpClient *http.Client) *cloudMetadataCache {
+	return &cloudMetadataCache{
+		httpClient: httpClient,
+		configs:    map[string]cloud.Configuration{},
+	}
+}
+
+// cloudMetadataResponse contains the parts of the ARM metadata used to build
+// the cloud configuration. Azure Stack Hub omits resourceManager, the ARM
+// endpoint is then the host serving the metadata.
+type cloudMetadataResponse struct {
+	ResourceManager string `json:"resourceManager"`
+	Authentication  struct {
+		LoginEndpoint string   `json:"loginEndpoint"`
+		Audiences     []string `json:"audiences"`
+	} `json:"authentication"`
+}
+
+// get returns the cloud configuration of the ARM metadata URL, fetching it on
+// the first request. Failures aren't cached. The fetch is shared by the
+// concurrent requests of the URL, so it isn't cancelled with the ctx of the
+// request starting it but bounded by timeout, and every request stops waiting
+// when its own ctx is done.
+func (c *cloudMetadataCache) get(ctx context.Context, metadataURL string, timeout time.Duration) (cloud.Configuration, error) {
+	c.mu.Lock()
+	cloudConfig, ok := c.configs[metadataURL]
+	c.mu.Unlock()
+	if ok {
+		tflog.Debug(ctx, fmt.Sprintf("Using cached cloud configuration of %s", metadataURL))
+		return cloudConfig, nil
+	}
+
+	ch := c.fetches.DoChan(metadataURL, func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+
+		cloudConfig, err := c.fetch(fetchCtx, metadataURL)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		c.configs[metadataURL] = cloudConfig
+		c.mu.Unlock()
+
+		return cloudConfig, nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return cloud.Configuration{}, fmt.Errorf("failed to load cloud configuration from %s: %w", metadataURL, res.Err)
+		}
+
+		cloudConfig, ok := res.Val.(cloud.Configuration)
+		if !ok {
+			return cloud.Configuration{}, fmt.Errorf("failed to load cloud configuration from %s: unexpected result %T", metadataURL, res.Val)
+		}
+
+		return cloudConfig, nil
+	case <-ctx.Done():
+		return cloud.Configuration{}, fmt.Errorf("failed to load cloud configuration from %s: %w", metadataURL, ctx.Err())
+	}
+}
+
+func (c *cloudMetadataCache) fetch(ctx context.Context, metadataURL string) (cloud.Configuration, error) {
+	u, err := url.Parse(metadataURL)
+	if err != nil {
+		return cloud.Configuration{}, err
+	}
+
+	query := u.Query()
+	if query.Get("api-version") == "" {
+		query.Set("api-version", cloudMetadataAPIVersion)
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return cloud.Configuration{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	tflog.Debug(ctx, fmt.Sprintf("Fetching cloud configuration from %s", u.String()))
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return cloud.Configuration{}, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return cloud.Configuration{}, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return cloud.Configuration{}, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	var metadata cloudMetadataResponse
+	err = json.Unmarshal(body, &metadata)
+	if err != nil {
+		return cloud.Configuration{}, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	if metadata.Authentication.LoginEndpoint == "" {
+		return cloud.Configuration{}, errors.New("metadata is missing authentication.loginEndpoint")
+	}
+
+	if len(metadata.Authentication.Audiences) == 0 {
+		return cloud.Configuration{}, errors.New("metadata is missing authentication.audiences")
+	}
+
+	endpoint := metadata.ResourceManager
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("%s://%s/", u.Scheme, u.Host)
+	}
+
+	return cloud.Configuration{
+		ActiveDirectoryAuthorityHost: metadata.Authentication.LoginEndpoint,
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {
+				Audience: metadata.Authentication.Audiences[0],
+				Endpoint: endpoint,
+			},
+		},
+	}, nil
+}
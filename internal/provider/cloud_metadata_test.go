@@ -0,0 +1,320 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestCloudMetadataCache(t *testing.T) {
+	cases := []struct {
+		name             string
+		response         string
+		expectedEndpoint func(serverURL string) string
+	}{
+		{
+			name:     "resource_manager",
+			response: `{"resourceManager":"https://management.ze-cloud.example.com/","authentication":{"loginEndpoint":"https://login.ze-cloud.example.com/","audiences":["https://management.core.ze-cloud.example.com/","https://management.ze-cloud.example.com/"]}}`,
+			expectedEndpoint: func(string) string {
+				return "https://management.ze-cloud.example.com/"
+			},
+		},
+		{
+			name:     "azure_stack",
+			response: `{"galleryEndpoint":"https://ze-gallery.example.com/","authentication":{"loginEndpoint":"https://login.ze-cloud.example.com/","audiences":["https://management.core.ze-cloud.example.com/"]}}`,
+			expectedEndpoint: func(serverURL string) string {
+				return serverURL + "/"
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+
+				if r.URL.Path != "/metadata/endpoints" {
+					t.Errorf("unexpected path %q", r.URL.Path)
+				}
+
+				if r.URL.Query().Get("api-version") != cloudMetadataAPIVersion {
+					t.Errorf("expected api-version %q, got %q", cloudMetadataAPIVersion, r.URL.Query().Get("api-version"))
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, c.response)
+			}))
+			defer server.Close()
+
+			cache := newCloudMetadataCache(server.Client())
+			for range 2 {
+				cloudConfig, err := cache.get(context.Background(), server.URL+"/metadata/endpoints", time.Minute)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				if cloudConfig.ActiveDirectoryAuthorityHost != "https://login.ze-cloud.example.com/" {
+					t.Errorf("unexpected authority host %q", cloudConfig.ActiveDirectoryAuthorityHost)
+				}
+
+				service := cloudConfig.Services[cloud.ResourceManager]
+				if service.Audience != "https://management.core.ze-cloud.example.com/" {
+					t.Errorf("unexpected audience %q", service.Audience)
+				}
+
+				if service.Endpoint != c.expectedEndpoint(server.URL) {
+					t.Errorf("expected endpoint %q, got %q", c.expectedEndpoint(server.URL), service.Endpoint)
+				}
+			}
+
+			if requests.Load() != 1 {
+				t.Errorf("expected the metadata to be requested once, got %d requests", requests.Load())
+			}
+		})
+	}
+}
+
+func TestCloudMetadataCacheFailure(t *testing.T) {
+	cases := []struct {
+		name          string
+		status        int
+		response      string
+		expectedError string
+	}{
+		{
+			name:          "status",
+			status:        http.StatusInternalServerError,
+			response:      `{}`,
+			expectedError: "unexpected status code 500",
+		},
+		{
+			name:          "invalid_json",
+			status:        http.StatusOK,
+			response:      `ze-invalid-json`,
+			expectedError: "failed to parse metadata",
+		},
+		{
+			name:          "missing_login_endpoint",
+			status:        http.StatusOK,
+			response:      `{"authentication":{"audiences":["https://management.core.ze-cloud.example.com/"]}}`,
+			expectedError: "missing authentication.loginEndpoint",
+		},
+		{
+			name:          "missing_audiences",
+			status:        http.StatusOK,
+			response:      `{"authentication":{"loginEndpoint":"https://login.ze-cloud.example.com/"}}`,
+			expectedError: "missing authentication.audiences",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(c.status)
+				fmt.Fprint(w, c.response)
+			}))
+			defer server.Close()
+
+			cache := newCloudMetadataCache(server.Client())
+			for range 2 {
+				_, err := cache.get(context.Background(), server.URL+"/metadata/endpoints?api-version=2019-05-01", time.Minute)
+				if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+					t.Errorf("expected error containing %q, got %v", c.expectedError, err)
+				}
+			}
+
+			if requests.Load() != 2 {
+				t.Errorf("expected failures not to be cached, got %d requests", requests.Load())
+			}
+		})
+	}
+}
+
+func TestCloudMetadataCacheTimeout(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ze-hanging/metadata/endpoints" {
+			<-r.Context().Done()
+			return
+		}
+
+		fmt.Fprint(w, `{"authentication":{"loginEndpoint":"https://login.ze-cloud.example.com/","audiences":["https://management.core.ze-cloud.example.com/"]}}`)
+	}))
+	defer server.Close()
+
+	cache := newCloudMetadataCache(server.Client())
+
+	hanging := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := cache.get(ctx, server.URL+"/ze-hanging/metadata/endpoints", time.Second)
+		hanging <- err
+	}()
+
+	// Another endpoint isn't blocked by the hanging fetch.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, err := cache.get(ctx, server.URL+"/metadata/endpoints", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = <-hanging
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestCloudMetadataCacheCanceledRequest(t *testing.T) {
+	var requests atomic.Int32
+	started := make(chan struct{})
+	respond := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			close(started)
+		}
+		<-respond
+		fmt.Fprint(w, `{"authentication":{"loginEndpoint":"https://login.ze-cloud.example.com/","audiences":["https://management.core.ze-cloud.example.com/"]}}`)
+	}))
+	defer server.Close()
+
+	cache := newCloudMetadataCache(server.Client())
+	metadataURL := server.URL + "/metadata/endpoints"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := cache.get(ctx, metadataURL, time.Minute)
+		canceled <- err
+	}()
+	<-started
+
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := cache.get(context.Background(), metadataURL, time.Minute)
+		waiting <- err
+	}()
+
+	// The request starting the fetch stops waiting, without failing the fetch
+	// shared with the other request.
+	cancel()
+	err := <-canceled
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled error, got %v", err)
+	}
+
+	close(respond)
+	err = <-waiting
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected the fetch to be shared, got %d requests", requests.Load())
+	}
+}
+
+func TestEphemeralCredentialCloudMetadata(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	err := os.WriteFile(tokenFilePath, []byte("ze-federated-token"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+
+	var metadataRequests atomic.Int32
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	entraHandler := testEntraHandler(t, server.URL, func(w http.ResponseWriter, r *http.Request) {
+		testEntraTokenResponse(w, "ze-metadata-cloud-token")
+	})
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metadata/endpoints" {
+			metadataRequests.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"authentication":{"loginEndpoint":"%s","audiences":["https://management.ze-cloud.example.com/"]}}`, server.URL)
+			return
+		}
+
+		entraHandler(w, r)
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), server.Client()),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "azidentity" {
+	cloud                      = "%[1]s/metadata/endpoints"
+	disable_instance_discovery = true
+}
+
+ephemeral "azidentity_workload_identity_credential" "this" {
+	tenant_id       = "ze-tenant"
+	client_id       = "ze-client"
+	token_file_path = %[2]q
+	scopes          = ["ze-scope-1"]
+}
+
+ephemeral "azidentity_workload_identity_credential" "that" {
+	cloud           = "%[1]s/metadata/endpoints"
+	tenant_id       = "ze-tenant"
+	client_id       = "ze-client"
+	token_file_path = %[2]q
+	scopes          = ["ze-scope-2"]
+}
+
+provider "echo" {
+  data = [
+    ephemeral.azidentity_workload_identity_credential.this.access_token,
+    ephemeral.azidentity_workload_identity_credential.that.access_token,
+  ]
+}
+
+resource "echo" "this" {}
+`, server.URL, tokenFilePath),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("ze-metadata-cloud-token"),
+							knownvalue.StringExact("ze-metadata-cloud-token"),
+						}),
+					),
+				},
+			},
+		},
+	})
+
+	if metadataRequests.Load() != 1 {
+		t.Errorf("expected the metadata to be requested once per provider instance, got %d requests", metadataRequests.Load())
+	}
+}
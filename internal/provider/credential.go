@@ -1,34 +1,71 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/lestrrat-go/jwx/v3/jwt"
 )
 
 type credentialType string
 
 const (
-	defaultCredential         credentialType = "DefaultCredential"
-	azureCLICredential        credentialType = "AzureCLICredential"
-	clientSecretCredential    credentialType = "ClientSecretCredential"
-	clientAssertionCredential credentialType = "ClientAssertionCredential"
+	defaultCredential           credentialType = "DefaultCredential"
+	azureCLICredential          credentialType = "AzureCLICredential"
+	clientSecretCredential      credentialType = "ClientSecretCredential"
+	clientAssertionCredential   credentialType = "ClientAssertionCredential"
+	managedIdentityCredential   credentialType = "ManagedIdentityCredential"
+	workloadIdentityCredential  credentialType = "WorkloadIdentityCredential"
+	clientCertificateCredential credentialType = "ClientCertificateCredential"
+	gitHubActionsCredential     credentialType = "GitHubActionsCredential"
+	azurePipelinesCredential    credentialType = "AzurePipelinesCredential"
+	azureDeveloperCLICredential credentialType = "AzureDeveloperCLICredential"
+	onBehalfOfCredential        credentialType = "OnBehalfOfCredential"
+	environmentCredential       credentialType = "EnvironmentCredential"
+	usernamePasswordCredential  credentialType = "UsernamePasswordCredential"
 )
 
 type credentialConfig struct {
 	CloudConfig                cloud.Configuration `json:"cloud_config"`
 	TenantID                   string              `json:"tenant_id"`
 	ClientID                   string              `json:"client_id"`
+	ObjectID                   string              `json:"object_id"`
+	ResourceID                 string              `json:"resource_id"`
 	ClientSecret               string              `json:"client_secret"`
+	Certificate                string              `json:"certificate"`
+	CertificatePassword        string              `json:"certificate_password"`
+	SendCertificateChain       bool                `json:"send_certificate_chain"`
 	Assertion                  string              `json:"client_assertion"`
+	UserAssertion              string              `json:"user_assertion"`
+	Username                   string              `json:"username"`
+	Password                   string              `json:"password"`
+	TokenFilePath              string              `json:"token_file_path"`
+	Audience                   string              `json:"audience"`
+	ServiceConnectionID        string              `json:"service_connection_id"`
+	SystemAccessToken          string              `json:"system_access_token"`
 	SubscriptionID             string              `json:"subscription_id"`
 	AdditionallyAllowedTenants []string            `json:"additionally_allowed_tenants"`
 	DisableInstanceDiscovery   bool                `json:"disable_instance_discovery"`
@@ -37,8 +74,18 @@ type credentialConfig struct {
 	Scopes                     []string            `json:"scopes"`
 	ContinueOnError            bool                `json:"continue_on_error"`
 	Timeout                    time.Duration       `json:"timeout"`
+	Retry                      retryConfig         `json:"retry"`
+	HTTPClient                 *http.Client        `json:"-"`
+	Attempts                   *retryAttempts      `json:"-"`
+	RunCmdFn                   runCommandFn        `json:"-"`
 }
 
+// errNoTenantID is returned by credentials requiring a tenant ID when neither
+// the resource nor the provider sets one.
+var errNoTenantID = errors.New("no tenant ID specified, set tenant_id on the resource or the provider")
+
+var errInvalidTenantID = errors.New("invalid tenant ID, it may only contain alphanumeric characters, '.' and '-'")
+
 type getCredentialFn func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error)
 
 func newGetCredentialFn() getCredentialFn {
@@ -52,6 +99,24 @@ func newGetCredentialFn() getCredentialFn {
 			return newClientSecretCredential(cfg)
 		case clientAssertionCredential:
 			return newClientAssertionCredential(cfg)
+		case managedIdentityCredential:
+			return newManagedIdentityCredential(cfg)
+		case workloadIdentityCredential:
+			return newWorkloadIdentityCredential(cfg)
+		case clientCertificateCredential:
+			return newClientCertificateCredential(cfg)
+		case gitHubActionsCredential:
+			return newGitHubActionsCredential(cfg)
+		case azurePipelinesCredential:
+			return newAzurePipelinesCredential(cfg)
+		case azureDeveloperCLICredential:
+			return newAzureDeveloperCLICredential(cfg)
+		case onBehalfOfCredential:
+			return newOnBehalfOfCredential(cfg)
+		case environmentCredential:
+			return newEnvironmentCredential(cfg)
+		case usernamePasswordCredential:
+			return newUsernamePasswordCredential(cfg)
 		default:
 			return nil, fmt.Errorf("unsupported credential type: %s", credType)
 		}
@@ -63,9 +128,7 @@ func newDefaultAzureCredential(cfg credentialConfig) (azcore.TokenCredential, er
 		TenantID:                   cfg.TenantID,
 		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
 		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
-		ClientOptions: azcore.ClientOptions{
-			Cloud: cfg.CloudConfig,
-		},
+		ClientOptions:              newClientOptions(cfg),
 	}
 
 	return azidentity.NewDefaultAzureCredential(options)
@@ -81,16 +144,172 @@ func newAzureCLICredential(cfg credentialConfig) (azcore.TokenCredential, error)
 	return azidentity.NewAzureCLICredential(options)
 }
 
+func newAzureDeveloperCLICredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	if cfg.RunCmdFn == nil {
+		return nil, errors.New("command runner is required to run the Azure Developer CLI")
+	}
+
+	if cfg.TenantID != "" && !validTenantID(cfg.TenantID) {
+		return nil, errInvalidTenantID
+	}
+
+	return &azureDeveloperCLITokenCredential{
+		runCmdFn:                   cfg.RunCmdFn,
+		tenantID:                   cfg.TenantID,
+		additionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+	}, nil
+}
+
+// azureDeveloperCLITokenCredential acquires tokens using 'azd auth token', the
+// same way as azidentity.AzureDeveloperCLICredential. It exists because the
+// SDK credential only lets its own tests replace how azd is executed, through
+// an unexported option, and we need to run the command through runCommandFn.
+// It validates tenants and scopes as the SDK credential does, so that neither
+// can inject arguments into the command.
+type azureDeveloperCLITokenCredential struct {
+	runCmdFn                   runCommandFn
+	tenantID                   string
+	additionallyAllowedTenants []string
+}
+
+func (c *azureDeveloperCLITokenCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(opts.Scopes) == 0 {
+		return azcore.AccessToken{}, errors.New("at least one scope is required to get a token from the Azure Developer CLI")
+	}
+
+	for _, scope := range opts.Scopes {
+		if !validScope(scope) {
+			return azcore.AccessToken{}, fmt.Errorf("invalid scope %q, scopes may only contain alphanumeric characters, '.', '-', '_', '/' and ':'", scope)
+		}
+	}
+
+	tenantID, err := c.resolveTenant(opts.TenantID)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+
+	args := []string{"auth", "token", "--output", "json", "--no-prompt"}
+	for _, scope := range opts.Scopes {
+		args = append(args, "--scope", scope)
+	}
+
+	if tenantID != "" {
+		args = append(args, "--tenant-id", tenantID)
+	}
+
+	if opts.Claims != "" {
+		args = append(args, "--claims", base64.StdEncoding.EncodeToString([]byte(opts.Claims)))
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	err = c.runCmdFn(ctx, &stdoutBuf, &stderrBuf, nil, "azd", args)
+	if err != nil {
+		msg := strings.TrimSpace(stderrBuf.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		if strings.Contains(msg, "azd auth login") {
+			return azcore.AccessToken{}, fmt.Errorf("not logged in to the Azure Developer CLI, run 'azd auth login' to authenticate: %s", msg)
+		}
+
+		return azcore.AccessToken{}, fmt.Errorf("failed to get token from the Azure Developer CLI: %s", msg)
+	}
+
+	var result struct {
+		Token     string `json:"token"`
+		ExpiresOn string `json:"expiresOn"`
+	}
+
+	err = json.Unmarshal(stdoutBuf.Bytes(), &result)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("failed to parse Azure Developer CLI token output: %w", err)
+	}
+
+	expiresOn, err := time.Parse(time.RFC3339, result.ExpiresOn)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("failed to parse Azure Developer CLI token expiration %q: %w", result.ExpiresOn, err)
+	}
+
+	return azcore.AccessToken{
+		Token:     result.Token,
+		ExpiresOn: expiresOn.UTC(),
+	}, nil
+}
+
+// resolveTenant returns the tenant to request a token for, following the
+// rules of the SDK credentials: a requested tenant other than the default one
+// has to be allowed by additionally_allowed_tenants, unless there is neither a
+// default tenant, or it is 'organizations', nor additionally allowed tenants.
+func (c *azureDeveloperCLITokenCredential) resolveTenant(requested string) (string, error) {
+	if requested == "" || requested == c.tenantID {
+		return c.tenantID, nil
+	}
+
+	if c.tenantID == "adfs" {
+		return "", errors.New("ADFS doesn't support tenants")
+	}
+
+	if !validTenantID(requested) {
+		return "", errInvalidTenantID
+	}
+
+	for _, tenant := range c.additionallyAllowedTenants {
+		if tenant == "*" || tenant == requested {
+			return requested, nil
+		}
+	}
+
+	if len(c.additionallyAllowedTenants) == 0 && (c.tenantID == "" || c.tenantID == "organizations") {
+		return requested, nil
+	}
+
+	return "", fmt.Errorf("tenant %q is not allowed, add it to additionally_allowed_tenants to allow acquiring tokens for it", requested)
+}
+
+// validTenantID reports whether tenantID only has the characters the SDK
+// credentials allow in a tenant.
+func validTenantID(tenantID string) bool {
+	if tenantID == "" {
+		return false
+	}
+
+	for _, r := range tenantID {
+		if !isAlphanumeric(r) && r != '.' && r != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validScope reports whether scope only has the characters the SDK
+// credentials allow in a scope passed to a developer tool.
+func validScope(scope string) bool {
+	for _, r := range scope {
+		if !isAlphanumeric(r) && !strings.ContainsRune("._-/:", r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isAlphanumeric(r rune) bool {
+	return ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
+}
+
 func newClientSecretCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
 	options := &azidentity.ClientSecretCredentialOptions{
 		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
 		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
-		ClientOptions: azcore.ClientOptions{
-			Cloud: cfg.CloudConfig,
-		},
+		ClientOptions:              newClientOptions(cfg),
 	}
 
 	tenantID := cfg.TenantID
+	if tenantID == "" {
+		return nil, errNoTenantID
+	}
 	clientID := cfg.ClientID
 	clientSecret := cfg.ClientSecret
 
@@ -101,12 +320,13 @@ func newClientAssertionCredential(cfg credentialConfig) (azcore.TokenCredential,
 	options := &azidentity.ClientAssertionCredentialOptions{
 		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
 		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
-		ClientOptions: azcore.ClientOptions{
-			Cloud: cfg.CloudConfig,
-		},
+		ClientOptions:              newClientOptions(cfg),
 	}
 
 	tenantID := cfg.TenantID
+	if tenantID == "" {
+		return nil, errNoTenantID
+	}
 	clientID := cfg.ClientID
 	getAssertionFn := func(context.Context) (string, error) {
 		return cfg.Assertion, nil
@@ -115,10 +335,373 @@ func newClientAssertionCredential(cfg credentialConfig) (azcore.TokenCredential,
 	return azidentity.NewClientAssertionCredential(tenantID, clientID, getAssertionFn, options)
 }
 
+func newClientCertificateCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.ClientCertificateCredentialOptions{
+		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
+		SendCertificateChain:       cfg.SendCertificateChain,
+		ClientOptions:              newClientOptions(cfg),
+	}
+
+	certs, key, err := parseCertificate(cfg.Certificate, cfg.CertificatePassword)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := cfg.TenantID
+	if tenantID == "" {
+		return nil, errNoTenantID
+	}
+	clientID := cfg.ClientID
+
+	return azidentity.NewClientCertificateCredential(tenantID, clientID, certs, key, options)
+}
+
+func newOnBehalfOfCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.OnBehalfOfCredentialOptions{
+		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
+		SendCertificateChain:       cfg.SendCertificateChain,
+		ClientOptions:              newClientOptions(cfg),
+	}
+
+	tenantID := cfg.TenantID
+	if tenantID == "" {
+		return nil, errNoTenantID
+	}
+
+	clientID := cfg.ClientID
+	userAssertion := cfg.UserAssertion
+
+	switch {
+	case cfg.ClientSecret != "":
+		return azidentity.NewOnBehalfOfCredentialWithSecret(tenantID, clientID, userAssertion, cfg.ClientSecret, options)
+	case cfg.Certificate != "":
+		certs, key, err := parseCertificate(cfg.Certificate, cfg.CertificatePassword)
+		if err != nil {
+			return nil, err
+		}
+
+		return azidentity.NewOnBehalfOfCredentialWithCertificate(tenantID, clientID, userAssertion, certs, key, options)
+	case cfg.Assertion != "":
+		getAssertionFn := func(ctx context.Context) (string, error) {
+			return cfg.Assertion, nil
+		}
+
+		return azidentity.NewOnBehalfOfCredentialWithClientAssertions(tenantID, clientID, userAssertion, getAssertionFn, options)
+	default:
+		return nil, errors.New("no client credential specified, set one of client_secret, certificate or client_assertion")
+	}
+}
+
+func newEnvironmentCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.EnvironmentCredentialOptions{
+		DisableInstanceDiscovery: cfg.DisableInstanceDiscovery,
+		ClientOptions:            newClientOptions(cfg),
+	}
+
+	return azidentity.NewEnvironmentCredential(options)
+}
+
+// newUsernamePasswordCredential uses the resource owner password credentials
+// (ROPC) flow, which Microsoft has deprecated as it doesn't support MFA. It's
+// kept for legacy automation accounts without any other option.
+func newUsernamePasswordCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.UsernamePasswordCredentialOptions{ // nolint:staticcheck
+		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
+		ClientOptions:              newClientOptions(cfg),
+	}
+
+	if cfg.TenantID == "" {
+		return nil, errNoTenantID
+	}
+
+	return azidentity.NewUsernamePasswordCredential(cfg.TenantID, cfg.ClientID, cfg.Username, cfg.Password, options) // nolint:staticcheck
+}
+
+const (
+	environmentCredentialModeSecret           = "secret"
+	environmentCredentialModeCertificate      = "certificate"
+	environmentCredentialModeUsernamePassword = "username_password"
+)
+
+// getEnvironmentCredentialMode returns the mode EnvironmentCredential selects
+// from the environment variables, with the same requirements and precedence as
+// azidentity.NewEnvironmentCredential: AZURE_TENANT_ID and AZURE_CLIENT_ID are
+// always required, then AZURE_CLIENT_SECRET selects the secret mode,
+// AZURE_CLIENT_CERTIFICATE_PATH the certificate mode, and AZURE_USERNAME with
+// AZURE_PASSWORD the username_password mode. It returns an empty string when
+// the environment variables don't configure a complete mode.
+func getEnvironmentCredentialMode() string {
+	if os.Getenv("AZURE_TENANT_ID") == "" || os.Getenv("AZURE_CLIENT_ID") == "" {
+		return ""
+	}
+
+	switch {
+	case os.Getenv("AZURE_CLIENT_SECRET") != "":
+		return environmentCredentialModeSecret
+	case os.Getenv("AZURE_CLIENT_CERTIFICATE_PATH") != "":
+		return environmentCredentialModeCertificate
+	case os.Getenv("AZURE_USERNAME") != "" && os.Getenv("AZURE_PASSWORD") != "":
+		return environmentCredentialModeUsernamePassword
+	default:
+		return ""
+	}
+}
+
+// parseCertificate parses either a PEM encoded certificate and private key or
+// a base64 encoded PKCS#12 archive.
+func parseCertificate(certificate string, password string) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	certData := []byte(certificate)
+	if !strings.Contains(certificate, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(certificate))
+		if err != nil {
+			return nil, nil, fmt.Errorf("certificate is neither PEM nor base64 encoded PKCS#12: %w", err)
+		}
+
+		certData = decoded
+	}
+
+	var passwordData []byte
+	if password != "" {
+		passwordData = []byte(password)
+	}
+
+	certs, key, err := azidentity.ParseCertificates(certData, passwordData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return certs, key, nil
+}
+
+func newManagedIdentityCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.ManagedIdentityCredentialOptions{
+		ClientOptions: newClientOptions(cfg),
+	}
+
+	switch {
+	case cfg.ClientID != "":
+		options.ID = azidentity.ClientID(cfg.ClientID)
+	case cfg.ObjectID != "":
+		options.ID = azidentity.ObjectID(cfg.ObjectID)
+	case cfg.ResourceID != "":
+		options.ID = azidentity.ResourceID(cfg.ResourceID)
+	}
+
+	return azidentity.NewManagedIdentityCredential(options)
+}
+
+func newWorkloadIdentityCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.ClientAssertionCredentialOptions{
+		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
+		ClientOptions:              newClientOptions(cfg),
+	}
+
+	tenantID := valueOrEnv(cfg.TenantID, "AZURE_TENANT_ID")
+	if tenantID == "" {
+		return nil, errors.New("no tenant ID specified, set tenant_id or the AZURE_TENANT_ID environment variable")
+	}
+
+	clientID := valueOrEnv(cfg.ClientID, "AZURE_CLIENT_ID")
+	if clientID == "" {
+		return nil, errors.New("no client ID specified, set client_id or the AZURE_CLIENT_ID environment variable")
+	}
+
+	tokenFilePath := valueOrEnv(cfg.TokenFilePath, "AZURE_FEDERATED_TOKEN_FILE")
+	if tokenFilePath == "" {
+		return nil, errors.New("no token file specified, set token_file_path or the AZURE_FEDERATED_TOKEN_FILE environment variable")
+	}
+
+	// The token file is read every time an assertion is requested, as the
+	// projected service account token is rotated by Kubernetes.
+	getAssertionFn := func(context.Context) (string, error) {
+		content, err := os.ReadFile(tokenFilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read federated token file: %w", err)
+		}
+
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	return azidentity.NewClientAssertionCredential(tenantID, clientID, getAssertionFn, options)
+}
+
+const defaultGitHubActionsAudience = "api://AzureADTokenExchange"
+
+func newGitHubActionsCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.ClientAssertionCredentialOptions{
+		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
+		ClientOptions:              newClientOptions(cfg),
+	}
+
+	tenantID := valueOrEnv(cfg.TenantID, "AZURE_TENANT_ID")
+	if tenantID == "" {
+		return nil, errors.New("no tenant ID specified, set tenant_id or the AZURE_TENANT_ID environment variable")
+	}
+
+	clientID := valueOrEnv(cfg.ClientID, "AZURE_CLIENT_ID")
+	if clientID == "" {
+		return nil, errors.New("no client ID specified, set client_id or the AZURE_CLIENT_ID environment variable")
+	}
+
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return nil, errors.New("ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN environment variables are required, make sure the workflow has the 'id-token: write' permission")
+	}
+
+	if cfg.HTTPClient == nil {
+		return nil, errors.New("HTTP client is required to request the GitHub Actions ID token")
+	}
+
+	audience := cfg.Audience
+	if audience == "" {
+		audience = defaultGitHubActionsAudience
+	}
+
+	assertion := &gitHubActionsAssertion{
+		httpClient:   cfg.HTTPClient,
+		requestURL:   requestURL,
+		requestToken: requestToken,
+		audience:     audience,
+	}
+
+	return azidentity.NewClientAssertionCredential(tenantID, clientID, assertion.get, options)
+}
+
+func newAzurePipelinesCredential(cfg credentialConfig) (azcore.TokenCredential, error) {
+	options := &azidentity.AzurePipelinesCredentialOptions{
+		AdditionallyAllowedTenants: cfg.AdditionallyAllowedTenants,
+		DisableInstanceDiscovery:   cfg.DisableInstanceDiscovery,
+		ClientOptions:              newClientOptions(cfg),
+	}
+
+	tenantID := valueOrEnv(cfg.TenantID, "AZURESUBSCRIPTION_TENANT_ID")
+	if tenantID == "" {
+		return nil, errors.New("no tenant ID specified, set tenant_id or the AZURESUBSCRIPTION_TENANT_ID environment variable")
+	}
+
+	clientID := valueOrEnv(cfg.ClientID, "AZURESUBSCRIPTION_CLIENT_ID")
+	if clientID == "" {
+		return nil, errors.New("no client ID specified, set client_id or the AZURESUBSCRIPTION_CLIENT_ID environment variable")
+	}
+
+	serviceConnectionID := valueOrEnv(cfg.ServiceConnectionID, "AZURESUBSCRIPTION_SERVICE_CONNECTION_ID")
+	if serviceConnectionID == "" {
+		return nil, errors.New("no service connection ID specified, set service_connection_id or the AZURESUBSCRIPTION_SERVICE_CONNECTION_ID environment variable")
+	}
+
+	systemAccessToken := valueOrEnv(cfg.SystemAccessToken, "SYSTEM_ACCESSTOKEN")
+	if systemAccessToken == "" {
+		return nil, errors.New("no system access token specified, set system_access_token or map $(System.AccessToken) to the SYSTEM_ACCESSTOKEN environment variable")
+	}
+
+	return azidentity.NewAzurePipelinesCredential(tenantID, clientID, serviceConnectionID, systemAccessToken, options)
+}
+
+// gitHubActionsAssertionExpiryMargin is how long before its expiry a GitHub
+// Actions ID token is considered expired and requested again.
+const gitHubActionsAssertionExpiryMargin = time.Minute
+
+// gitHubActionsAssertion requests ID tokens from the GitHub Actions OIDC
+// provider, reusing the last token until it is about to expire.
+type gitHubActionsAssertion struct {
+	httpClient   *http.Client
+	requestURL   string
+	requestToken string
+	audience     string
+
+	mu        sync.Mutex
+	idToken   string
+	expiresOn time.Time
+}
+
+func (a *gitHubActionsAssertion) get(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.idToken != "" && time.Now().Add(gitHubActionsAssertionExpiryMargin).Before(a.expiresOn) {
+		return a.idToken, nil
+	}
+
+	idToken, err := a.request(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	parsedToken, err := jwt.ParseString(idToken, jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse GitHub Actions ID token: %w", err)
+	}
+
+	// Tokens without an expiry are never reused.
+	expiresOn, _ := parsedToken.Expiration()
+
+	a.idToken = idToken
+	a.expiresOn = expiresOn
+
+	return idToken, nil
+}
+
+func (a *gitHubActionsAssertion) request(ctx context.Context) (string, error) {
+	reqURL, err := url.Parse(a.requestURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+
+	query := reqURL.Query()
+	query.Set("audience", a.audience)
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub Actions ID token request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.requestToken)
+
+	res, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request GitHub Actions ID token: %w", err)
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read GitHub Actions ID token response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d requesting GitHub Actions ID token: %s", res.StatusCode, body)
+	}
+
+	var payload struct {
+		Value string `json:"value"`
+	}
+
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal GitHub Actions ID token response: %w", err)
+	}
+
+	if payload.Value == "" {
+		return "", errors.New("GitHub Actions ID token response did not contain a token")
+	}
+
+	return payload.Value, nil
+}
+
 func getToken(ctx context.Context, credType credentialType, getCredFn getCredentialFn, cfg credentialConfig) (azcore.AccessToken, string, error) {
 	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
 	defer cancel()
 
+	cfg.Attempts = &retryAttempts{}
 	cred, err := getCredFn(credType, cfg)
 	if err != nil {
 		return azcore.AccessToken{}, "Error creating credential", err
@@ -127,12 +710,132 @@ func getToken(ctx context.Context, credType credentialType, getCredFn getCredent
 	tokenOpts := newTokenRequestOptions(cfg)
 	token, err := cred.GetToken(ctx, tokenOpts)
 	if err != nil {
-		return azcore.AccessToken{}, "Error getting token", err
+		return azcore.AccessToken{}, "Error getting token", withAttempts(ctx, err, cfg.Attempts)
+	}
+
+	if n := cfg.Attempts.last(); n > 0 {
+		tflog.Debug(ctx, fmt.Sprintf("Acquired token after %d attempt(s)", n))
 	}
 
 	return token, "", nil
 }
 
+// chainedCredentialLink is a single source of a credential chain. It records
+// the outcome of its token request, which ChainedTokenCredential doesn't
+// expose, so the links tried and the one that succeeded can be reported.
+type chainedCredentialLink struct {
+	name     string
+	credType credentialType
+	cfg      credentialConfig
+
+	cred      azcore.TokenCredential
+	err       error
+	succeeded bool
+}
+
+func (l *chainedCredentialLink) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := l.cred.GetToken(ctx, opts)
+	l.err = err
+	if err != nil {
+		l.err = withAttempts(ctx, err, l.cfg.Attempts)
+	}
+	l.succeeded = err == nil
+
+	return token, err
+}
+
+// requestConfig returns the configuration of the link with the token request
+// options of the chain.
+func (l *chainedCredentialLink) requestConfig(cfg credentialConfig) credentialConfig {
+	linkCfg := l.cfg
+	linkCfg.Claims = cfg.Claims
+	linkCfg.EnableCAE = cfg.EnableCAE
+	linkCfg.Scopes = cfg.Scopes
+	linkCfg.ContinueOnError = cfg.ContinueOnError
+	linkCfg.Timeout = cfg.Timeout
+	linkCfg.Retry = cfg.Retry
+
+	return linkCfg
+}
+
+// getChainedToken acquires a token from the first link able to provide one.
+// Links failing to create their credential, for example because required
+// environment variables are missing, are skipped. It returns the index of the
+// link that acquired the token.
+func getChainedToken(ctx context.Context, getCredFn getCredentialFn, links []*chainedCredentialLink, cfg credentialConfig) (azcore.AccessToken, int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	// The links are reused when the chain is renewed, so the outcome of an
+	// earlier request must not be reported again.
+	for _, link := range links {
+		link.cred = nil
+		link.err = nil
+		link.succeeded = false
+	}
+
+	sources := []azcore.TokenCredential{}
+	for _, link := range links {
+		// The retry policy is shared by all links of the chain.
+		link.cfg.Retry = cfg.Retry
+		link.cfg.Attempts = &retryAttempts{}
+		cred, err := getCredFn(link.credType, link.cfg)
+		if err != nil {
+			link.err = fmt.Errorf("failed to create credential: %w", err)
+			continue
+		}
+
+		// A managed identity only reports itself as unavailable, allowing the
+		// chain to continue, when it is a direct source of a chain.
+		if _, ok := cred.(*azidentity.ManagedIdentityCredential); ok {
+			cred, err = azidentity.NewChainedTokenCredential([]azcore.TokenCredential{cred}, nil)
+			if err != nil {
+				link.err = fmt.Errorf("failed to create credential: %w", err)
+				continue
+			}
+		}
+
+		link.cred = cred
+		sources = append(sources, link)
+	}
+
+	if len(sources) == 0 {
+		return azcore.AccessToken{}, -1, "Error creating credential", newChainedCredentialError(links)
+	}
+
+	chain, err := azidentity.NewChainedTokenCredential(sources, nil)
+	if err != nil {
+		return azcore.AccessToken{}, -1, "Error creating credential", err
+	}
+
+	tokenOpts := newTokenRequestOptions(cfg)
+	token, err := chain.GetToken(ctx, tokenOpts)
+	if err != nil {
+		return azcore.AccessToken{}, -1, "Error getting token", newChainedCredentialError(links)
+	}
+
+	for i, link := range links {
+		if link.succeeded {
+			return token, i, "", nil
+		}
+	}
+
+	return azcore.AccessToken{}, -1, "Error getting token", errors.New("credential chain returned a token without any link succeeding")
+}
+
+func newChainedCredentialError(links []*chainedCredentialLink) error {
+	msg := "no source in the credential chain acquired a token, attempted sources:"
+	for i, link := range links {
+		if link.err == nil {
+			continue
+		}
+
+		msg += fmt.Sprintf("\n\tsources[%d].%s: %s", i, link.name, strings.ReplaceAll(link.err.Error(), "\n", "\n\t\t"))
+	}
+
+	return errors.New(msg)
+}
+
 func newTokenRequestOptions(cfg credentialConfig) policy.TokenRequestOptions {
 	return policy.TokenRequestOptions{
 		Claims:    cfg.Claims,
@@ -173,15 +876,37 @@ func typesSetToStringSlice(input types.Set) []string {
 	return result
 }
 
-func getCloudConfig(input string) cloud.Configuration {
+func valueOrEnv(value string, key string) string {
+	if value != "" {
+		return value
+	}
+
+	return os.Getenv(key)
+}
+
+// supportedClouds are the names accepted by getCloudConfig.
+var supportedClouds = []string{"AzurePublic", "AzureChina", "AzureGovernment"}
+
+// newCloudValidator validates a cloud is either a supported name or the URL of
+// an ARM metadata endpoint.
+func newCloudValidator() validator.String {
+	return stringvalidator.Any(
+		stringvalidator.OneOf(supportedClouds...),
+		stringvalidator.RegexMatches(regexp.MustCompile(`^https://`), "must be the https URL of an ARM metadata endpoint"),
+	)
+}
+
+// getCloudConfig returns the configuration of a named cloud, AzurePublic when
+// the name is empty.
+func getCloudConfig(input string) (cloud.Configuration, error) {
 	switch input {
-	case "AzurePublic":
-		return cloud.AzurePublic
+	case "", "AzurePublic":
+		return cloud.AzurePublic, nil
 	case "AzureChina":
-		return cloud.AzureChina
+		return cloud.AzureChina, nil
 	case "AzureGovernment":
-		return cloud.AzureGovernment
+		return cloud.AzureGovernment, nil
 	default:
-		return cloud.AzurePublic
+		return cloud.Configuration{}, fmt.Errorf("unknown cloud %q, expected one of %s", input, strings.Join(supportedClouds, ", "))
 	}
 }
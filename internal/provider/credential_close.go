@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// closeCredential releases the hold of a closed credential resource on its
+// entry in the token cache of the provider. The entry, with its token and the
+// configuration kept for renewing it, stays cached for the resources opened
+// later, until its token expires. Failures are reported as warnings, as the
+// resource is gone either way.
+func closeCredential(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse, cache *tokenCache) {
+	state, ok, diags := getCredentialRenewState(ctx, req.Private)
+	appendAsWarnings(&resp.Diagnostics, diags)
+	if diags.HasError() || !ok || cache == nil {
+		return
+	}
+
+	cache.release(state.CacheKey)
+	tflog.Debug(ctx, "Released cached token of closed credential")
+}
+
+// appendAsWarnings appends diags to target, downgrading errors to warnings.
+func appendAsWarnings(target *diag.Diagnostics, diags diag.Diagnostics) {
+	for _, d := range diags {
+		target.AddWarning(d.Summary(), d.Detail())
+	}
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestEphemeralCredentialClose(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var requests atomic.Int32
+	s := testNewEphemeralServer(t, testNewCountingCredentialFn(&requests, time.Hour), nil)
+	typeName := "azidentity_client_secret_credential"
+
+	openResp, _ := s.open(typeName, testClientSecretCredentialConfig(nil))
+	otherOpenResp, result := s.open(typeName, testClientSecretCredentialConfig(nil))
+
+	var cacheHit bool
+	err := result["cache_hit"].As(&cacheHit)
+	if err != nil || !cacheHit {
+		t.Fatalf("expected the second open to hit the cache, got %t and %v", cacheHit, err)
+	}
+
+	// The token stays cached after the resources using it are closed, until it
+	// expires.
+	for _, private := range [][]byte{openResp.Private, otherOpenResp.Private} {
+		closeResp := s.close(typeName, private)
+		testRequireNoDiagnostics(t, closeResp.Diagnostics)
+	}
+
+	_, result = s.open(typeName, testClientSecretCredentialConfig(nil))
+	if token := testStringValue(t, result["access_token"]); token != "ze-token-1" {
+		t.Errorf("expected the cached token after closing, got %q", token)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 token request, got %d", requests.Load())
+	}
+}
+
+func TestEphemeralCredentialCloseWithoutPrivateData(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	s := testNewEphemeralServer(t, testNewTestCredentialFn(t), nil)
+
+	// A resource that failed with continue_on_error has no private data.
+	for _, typeName := range []string{"azidentity_client_secret_credential", "azidentity_chained_credential", "azidentity_environment_variable"} {
+		closeResp := s.close(typeName, nil)
+		testRequireNoDiagnostics(t, closeResp.Diagnostics)
+	}
+}
+
+func TestEphemeralCredentialCloseContinueOnError(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	s := testNewEphemeralServer(t, testNewTestCredentialFailureFn(t), nil)
+	typeName := "azidentity_client_secret_credential"
+	openResp, result := s.open(typeName, testClientSecretCredentialConfig(map[string]tftypes.Value{
+		"continue_on_error": tftypes.NewValue(tftypes.Bool, true),
+	}))
+
+	var success bool
+	err := result["success"].As(&success)
+	if err != nil || success {
+		t.Fatalf("expected the open to fail, got %t and %v", success, err)
+	}
+
+	closeResp := s.close(typeName, openResp.Private)
+	testRequireNoDiagnostics(t, closeResp.Diagnostics)
+}
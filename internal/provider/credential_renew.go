@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+)
+
+// defaultRenewMargin is how long before the expiry of their token the
+// credential resources are renewed, unless renew_margin is set.
+const defaultRenewMargin = 5 * time.Minute
+
+// credentialRenewPrivateKey is the private data key of the credential renew
+// state.
+const credentialRenewPrivateKey = "credential"
+
+// credentialRenewState is stashed in the private data of a credential resource
+// by Open, so Renew can refresh its token in the token cache of the provider.
+// Terraform only keeps the private data of ephemeral resources in memory, it's
+// never written to the plan or state, but it still leaves the provider, so it
+// only holds the key of the cached token. The configuration needed to request
+// a new token, including any secrets, stays in the token cache.
+type credentialRenewState struct {
+	CacheKey        string        `json:"cache_key"`
+	ContinueOnError bool          `json:"continue_on_error"`
+	RenewMargin     time.Duration `json:"renew_margin"`
+}
+
+// privateDataGetter is implemented by the private data of ephemeral resources,
+// whose type is internal to the framework.
+type privateDataGetter interface {
+	GetKey(ctx context.Context, key string) ([]byte, diag.Diagnostics)
+}
+
+// newRenewAt returns when a token expiring on expiresOn should be renewed,
+// margin before its expiry. Tokens expiring within the margin are renewed
+// halfway through their remaining lifetime, and expired tokens aren't renewed.
+func newRenewAt(expiresOn time.Time, margin time.Duration) time.Time {
+	now := time.Now()
+	if !expiresOn.After(now) {
+		return time.Time{}
+	}
+
+	renewAt := expiresOn.Add(-margin)
+	if renewAt.After(now) {
+		return renewAt
+	}
+
+	return now.Add(expiresOn.Sub(now) / 2)
+}
+
+// setCredentialRenewState stashes the renew state of a token acquired through
+// the token cache in the private data of the response and schedules the
+// renewal of the token.
+func setCredentialRenewState(ctx context.Context, resp *ephemeral.OpenResponse, cache *tokenCache, credType credentialType, cfg credentialConfig, renewMargin time.Duration, token azcore.AccessToken) diag.Diagnostics {
+	var diags diag.Diagnostics
+	key, err := newTokenCacheKey(credType, cfg)
+	if err != nil {
+		diags.AddError("Error Storing Renew State", err.Error())
+		return diags
+	}
+
+	return setRenewState(ctx, resp, cache, credentialRenewState{
+		CacheKey:        key,
+		ContinueOnError: cfg.ContinueOnError,
+		RenewMargin:     renewMargin,
+	}, token)
+}
+
+// setRenewState stashes state in the private data of the response and
+// schedules the renewal of the token. The token cache entry, held since the
+// token was acquired, is released right away when the resource has no private
+// data to release it on Close.
+func setRenewState(ctx context.Context, resp *ephemeral.OpenResponse, cache *tokenCache, state credentialRenewState, token azcore.AccessToken) diag.Diagnostics {
+	var diags diag.Diagnostics
+	release := func() {
+		if cache != nil {
+			cache.release(state.CacheKey)
+		}
+	}
+
+	if resp.Private == nil {
+		release()
+		return diags
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		release()
+		diags.AddError("Error Storing Renew State", err.Error())
+		return diags
+	}
+
+	diags.Append(resp.Private.SetKey(ctx, credentialRenewPrivateKey, b)...)
+	if diags.HasError() {
+		release()
+		return diags
+	}
+
+	resp.RenewAt = newRenewAt(token.ExpiresOn, state.RenewMargin)
+
+	return diags
+}
+
+// renewCredential requests a new token for the token cache entry Open stashed
+// the key of in the private data, valid for longer than the renew margin, and
+// schedules the next renewal. The result of an ephemeral resource can't change
+// after Open, so Terraform and the providers it already passed the token to
+// keep the token acquired by Open, but renewing keeps the token cache of the
+// provider fresh for the resources opened later in the same operation. An
+// entry missing from the cache, as after a restart of the provider, can't be
+// renewed without the configuration kept in it, which is reported as a
+// warning. Failures are reported as warnings when continue_on_error is set.
+func renewCredential(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse, cache *tokenCache) {
+	state, ok, diags := getCredentialRenewState(ctx, req.Private)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || !ok || cache == nil {
+		return
+	}
+
+	token, ok, errSummary, err := cache.renew(ctx, state.CacheKey, state.RenewMargin)
+	if !ok {
+		resp.Diagnostics.AddWarning(
+			"Cached Token Not Renewed",
+			"The token cache of the provider no longer holds the token of the resource, for example because the provider was restarted since the resource was opened. "+
+				"The configuration needed to request a new token is only kept in the token cache, so the token acquired when the resource was opened is used until it expires, "+
+				"and resources opened later request a new token.",
+		)
+		return
+	}
+
+	if err != nil && state.ContinueOnError {
+		resp.Diagnostics.AddWarning(errSummary, err.Error())
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError(errSummary, err.Error())
+		return
+	}
+
+	resp.RenewAt = newRenewAt(token.ExpiresOn, state.RenewMargin)
+}
+
+// getCredentialRenewState returns the renew state stashed by Open, if any.
+func getCredentialRenewState(ctx context.Context, private privateDataGetter) (credentialRenewState, bool, diag.Diagnostics) {
+	var state credentialRenewState
+	b, diags := private.GetKey(ctx, credentialRenewPrivateKey)
+	if diags.HasError() || len(b) == 0 {
+		return state, false, diags
+	}
+
+	err := json.Unmarshal(b, &state)
+	if err != nil {
+		diags.AddError("Error Reading Renew State", fmt.Sprintf("Failed to unmarshal the renew state: %s", err))
+		return state, false, diags
+	}
+
+	return state, true, diags
+}
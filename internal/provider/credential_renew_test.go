@@ -0,0 +1,501 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// testEphemeralServer drives the provider over the protocol, as Terraform only
+// renews ephemeral resources during operations outlasting their RenewAt and
+// doesn't report the diagnostics of Close.
+type testEphemeralServer struct {
+	t        *testing.T
+	provider *azidentityProvider
+	server   tfprotov6.ProviderServer
+	schemas  *tfprotov6.GetProviderSchemaResponse
+}
+
+func testNewEphemeralServer(t *testing.T, getCredFn getCredentialFn, providerConfig map[string]tftypes.Value) *testEphemeralServer {
+	t.Helper()
+
+	p, ok := testNew(t, getCredFn)().(*azidentityProvider)
+	if !ok {
+		t.Fatal("expected an azidentity provider")
+	}
+
+	server, err := providerserver.NewProtocol6WithError(p)()
+	if err != nil {
+		t.Fatalf("failed to create provider server: %s", err)
+	}
+
+	schemas, err := server.GetProviderSchema(t.Context(), &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("failed to get provider schema: %s", err)
+	}
+
+	s := &testEphemeralServer{
+		t:        t,
+		provider: p,
+		server:   server,
+		schemas:  schemas,
+	}
+
+	config := s.dynamicValue(schemas.Provider, providerConfig)
+	resp, err := server.ConfigureProvider(t.Context(), &tfprotov6.ConfigureProviderRequest{Config: &config})
+	if err != nil {
+		t.Fatalf("failed to configure provider: %s", err)
+	}
+	testRequireNoDiagnostics(t, resp.Diagnostics)
+
+	return s
+}
+
+// dynamicValue returns a value of the schema with the given attributes, all
+// other attributes are null.
+func (s *testEphemeralServer) dynamicValue(schema *tfprotov6.Schema, values map[string]tftypes.Value) tfprotov6.DynamicValue {
+	s.t.Helper()
+
+	objectType, ok := schema.ValueType().(tftypes.Object)
+	if !ok {
+		s.t.Fatalf("expected an object schema, got %s", schema.ValueType())
+	}
+
+	attributes := map[string]tftypes.Value{}
+	for name, attributeType := range objectType.AttributeTypes {
+		value, ok := values[name]
+		if !ok {
+			value = tftypes.NewValue(attributeType, nil)
+		}
+		attributes[name] = value
+	}
+
+	dv, err := tfprotov6.NewDynamicValue(objectType, tftypes.NewValue(objectType, attributes))
+	if err != nil {
+		s.t.Fatalf("failed to create dynamic value: %s", err)
+	}
+
+	return dv
+}
+
+func (s *testEphemeralServer) open(typeName string, values map[string]tftypes.Value) (*tfprotov6.OpenEphemeralResourceResponse, map[string]tftypes.Value) {
+	s.t.Helper()
+
+	schema := s.schemas.EphemeralResourceSchemas[typeName]
+	config := s.dynamicValue(schema, values)
+	resp, err := s.server.OpenEphemeralResource(s.t.Context(), &tfprotov6.OpenEphemeralResourceRequest{
+		TypeName: typeName,
+		Config:   &config,
+	})
+	if err != nil {
+		s.t.Fatalf("failed to open %s: %s", typeName, err)
+	}
+	testRequireNoDiagnostics(s.t, resp.Diagnostics)
+
+	result, err := resp.Result.Unmarshal(schema.ValueType())
+	if err != nil {
+		s.t.Fatalf("failed to unmarshal result: %s", err)
+	}
+
+	attributes := map[string]tftypes.Value{}
+	err = result.As(&attributes)
+	if err != nil {
+		s.t.Fatalf("failed to convert result: %s", err)
+	}
+
+	return resp, attributes
+}
+
+func (s *testEphemeralServer) renew(typeName string, private []byte) *tfprotov6.RenewEphemeralResourceResponse {
+	s.t.Helper()
+
+	resp, err := s.server.RenewEphemeralResource(s.t.Context(), &tfprotov6.RenewEphemeralResourceRequest{
+		TypeName: typeName,
+		Private:  private,
+	})
+	if err != nil {
+		s.t.Fatalf("failed to renew %s: %s", typeName, err)
+	}
+
+	return resp
+}
+
+func (s *testEphemeralServer) close(typeName string, private []byte) *tfprotov6.CloseEphemeralResourceResponse {
+	s.t.Helper()
+
+	resp, err := s.server.CloseEphemeralResource(s.t.Context(), &tfprotov6.CloseEphemeralResourceRequest{
+		TypeName: typeName,
+		Private:  private,
+	})
+	if err != nil {
+		s.t.Fatalf("failed to close %s: %s", typeName, err)
+	}
+
+	return resp
+}
+
+func testRequireNoDiagnostics(t *testing.T, diags []*tfprotov6.Diagnostic) {
+	t.Helper()
+
+	for _, diag := range diags {
+		t.Fatalf("unexpected diagnostic: %s: %s", diag.Summary, diag.Detail)
+	}
+}
+
+func testStringValue(t *testing.T, value tftypes.Value) string {
+	t.Helper()
+
+	var s string
+	err := value.As(&s)
+	if err != nil {
+		t.Fatalf("failed to convert value: %s", err)
+	}
+
+	return s
+}
+
+func testClientSecretCredentialConfig(values map[string]tftypes.Value) map[string]tftypes.Value {
+	config := map[string]tftypes.Value{
+		"tenant_id":     tftypes.NewValue(tftypes.String, "ze-tenant"),
+		"client_id":     tftypes.NewValue(tftypes.String, "ze-client"),
+		"client_secret": tftypes.NewValue(tftypes.String, "ze-secret"),
+		"scopes":        tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{tftypes.NewValue(tftypes.String, "ze-scope")}),
+	}
+	for k, v := range values {
+		config[k] = v
+	}
+
+	return config
+}
+
+func TestEphemeralCredentialRenewTestCredential(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var requests atomic.Int32
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		requests.Add(1)
+		if cfg.ClientSecret != "ze-secret" || cfg.TenantID != "ze-tenant" {
+			t.Errorf("unexpected renew parameters: %s/%s", cfg.TenantID, cfg.ClientSecret)
+		}
+		return testNewTestCredentialFn(t)(credType, cfg)
+	}
+
+	s := testNewEphemeralServer(t, getCredFn, nil)
+	typeName := "azidentity_client_secret_credential"
+	openResp, result := s.open(typeName, testClientSecretCredentialConfig(nil))
+
+	if token := testStringValue(t, result["access_token"]); token != "ze-token" {
+		t.Errorf("unexpected access token %q", token)
+	}
+
+	// The token of the test credential expired in 2022, so it isn't renewed
+	// automatically, but renewing with the stashed parameters still works.
+	if !openResp.RenewAt.IsZero() {
+		t.Errorf("expected no renewal of an expired token, got %s", openResp.RenewAt)
+	}
+
+	if len(openResp.Private) == 0 {
+		t.Fatalf("expected the renew state in the private data")
+	}
+
+	if bytes.Contains(openResp.Private, []byte("ze-secret")) || bytes.Contains(openResp.Private, []byte("ze-client")) {
+		t.Errorf("expected the private data not to contain the credential parameters, got %s", openResp.Private)
+	}
+
+	renewResp := s.renew(typeName, openResp.Private)
+	testRequireNoDiagnostics(t, renewResp.Diagnostics)
+
+	if !renewResp.RenewAt.IsZero() {
+		t.Errorf("expected no renewal of an expired token, got %s", renewResp.RenewAt)
+	}
+
+	if requests.Load() != 2 {
+		t.Errorf("expected 2 token requests, got %d", requests.Load())
+	}
+}
+
+func TestEphemeralCredentialRenew(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	cases := []struct {
+		name                  string
+		renewMargin           string
+		expectedRenewIn       time.Duration
+		expectedRenewRequests int32
+	}{
+		{
+			name:                  "default_margin",
+			expectedRenewIn:       time.Hour - defaultRenewMargin,
+			expectedRenewRequests: 1,
+		},
+		{
+			name:                  "custom_margin",
+			renewMargin:           "15m",
+			expectedRenewIn:       45 * time.Minute,
+			expectedRenewRequests: 1,
+		},
+		{
+			// A token expiring within the margin is renewed halfway through
+			// its lifetime, bypassing the token cache.
+			name:                  "margin_exceeding_lifetime",
+			renewMargin:           "2h",
+			expectedRenewIn:       30 * time.Minute,
+			expectedRenewRequests: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var requests atomic.Int32
+			providerConfig := map[string]tftypes.Value{}
+			if c.renewMargin != "" {
+				providerConfig["renew_margin"] = tftypes.NewValue(tftypes.String, c.renewMargin)
+			}
+
+			s := testNewEphemeralServer(t, testNewCountingCredentialFn(&requests, time.Hour), providerConfig)
+			typeName := "azidentity_client_secret_credential"
+			openResp, _ := s.open(typeName, testClientSecretCredentialConfig(nil))
+
+			testRequireRenewIn(t, openResp.RenewAt, c.expectedRenewIn)
+
+			renewResp := s.renew(typeName, openResp.Private)
+			testRequireNoDiagnostics(t, renewResp.Diagnostics)
+			testRequireRenewIn(t, renewResp.RenewAt, c.expectedRenewIn)
+
+			if requests.Load() != c.expectedRenewRequests {
+				t.Errorf("expected %d token requests, got %d", c.expectedRenewRequests, requests.Load())
+			}
+		})
+	}
+}
+
+func TestEphemeralChainedCredentialRenew(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var renewedCredType atomic.Value
+	var requests atomic.Int32
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		renewedCredType.Store(credType)
+		return testNewCountingCredentialFn(&requests, time.Hour)(credType, cfg)
+	}
+
+	s := testNewEphemeralServer(t, getCredFn, map[string]tftypes.Value{
+		"renew_margin": tftypes.NewValue(tftypes.String, "2h"),
+	})
+	typeName := "azidentity_chained_credential"
+	chainedType, ok := s.schemas.EphemeralResourceSchemas[typeName].ValueType().(tftypes.Object)
+	if !ok {
+		t.Fatalf("expected an object schema")
+	}
+	sourcesType, ok := chainedType.AttributeTypes["sources"].(tftypes.List)
+	if !ok {
+		t.Fatalf("expected sources to be a list")
+	}
+	sourceType, ok := sourcesType.ElementType.(tftypes.Object)
+	if !ok {
+		t.Fatalf("expected sources to be a list of objects")
+	}
+	clientSecretType, ok := sourceType.AttributeTypes["client_secret"].(tftypes.Object)
+	if !ok {
+		t.Fatalf("expected the client_secret source to be an object")
+	}
+
+	source := map[string]tftypes.Value{}
+	for name, attributeType := range sourceType.AttributeTypes {
+		source[name] = tftypes.NewValue(attributeType, nil)
+	}
+	clientSecret := map[string]tftypes.Value{}
+	for name, attributeType := range clientSecretType.AttributeTypes {
+		clientSecret[name] = tftypes.NewValue(attributeType, nil)
+	}
+	clientSecret["tenant_id"] = tftypes.NewValue(tftypes.String, "ze-tenant")
+	clientSecret["client_id"] = tftypes.NewValue(tftypes.String, "ze-client")
+	clientSecret["client_secret"] = tftypes.NewValue(tftypes.String, "ze-secret")
+	source["client_secret"] = tftypes.NewValue(clientSecretType, clientSecret)
+
+	openResp, result := s.open(typeName, map[string]tftypes.Value{
+		"scopes":  tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{tftypes.NewValue(tftypes.String, "ze-scope")}),
+		"sources": tftypes.NewValue(sourcesType, []tftypes.Value{tftypes.NewValue(sourceType, source)}),
+	})
+
+	if token := testStringValue(t, result["access_token"]); token != "ze-token-1" {
+		t.Errorf("unexpected access token %q", token)
+	}
+	testRequireRenewIn(t, openResp.RenewAt, 30*time.Minute)
+
+	// The token expires within the renew margin, so renewing tries the chain
+	// again and refreshes its token in the token cache.
+	renewResp := s.renew(typeName, openResp.Private)
+	testRequireNoDiagnostics(t, renewResp.Diagnostics)
+	testRequireRenewIn(t, renewResp.RenewAt, 30*time.Minute)
+
+	_, result = s.open(typeName, map[string]tftypes.Value{
+		"scopes":  tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{tftypes.NewValue(tftypes.String, "ze-scope")}),
+		"sources": tftypes.NewValue(sourcesType, []tftypes.Value{tftypes.NewValue(sourceType, source)}),
+	})
+
+	var cacheHit bool
+	err := result["cache_hit"].As(&cacheHit)
+	if err != nil || !cacheHit || testStringValue(t, result["access_token"]) != "ze-token-2" {
+		t.Errorf("expected the renewed token to be cached, got %s with cache hit %t and %v", testStringValue(t, result["access_token"]), cacheHit, err)
+	}
+
+	if requests.Load() != 2 {
+		t.Errorf("expected 2 token requests, got %d", requests.Load())
+	}
+
+	if credType := renewedCredType.Load(); credType != clientSecretCredential {
+		t.Errorf("expected the client secret source to be renewed, got %v", credType)
+	}
+}
+
+func TestEphemeralCredentialRenewFailure(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	cases := []struct {
+		name            string
+		continueOnError bool
+		expectedError   bool
+	}{
+		{
+			name:          "error",
+			expectedError: true,
+		},
+		{
+			name:            "continue_on_error",
+			continueOnError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var fail atomic.Bool
+			var requests atomic.Int32
+			getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+				if fail.Load() {
+					return testNewTestCredentialFailureFn(t)(credType, cfg)
+				}
+				return testNewCountingCredentialFn(&requests, time.Hour)(credType, cfg)
+			}
+
+			s := testNewEphemeralServer(t, getCredFn, map[string]tftypes.Value{
+				"renew_margin": tftypes.NewValue(tftypes.String, "2h"),
+			})
+			typeName := "azidentity_client_secret_credential"
+			openResp, _ := s.open(typeName, testClientSecretCredentialConfig(map[string]tftypes.Value{
+				"continue_on_error": tftypes.NewValue(tftypes.Bool, c.continueOnError),
+			}))
+
+			fail.Store(true)
+			renewResp := s.renew(typeName, openResp.Private)
+			if len(renewResp.Diagnostics) != 1 {
+				t.Fatalf("expected a single diagnostic, got %d", len(renewResp.Diagnostics))
+			}
+
+			diag := renewResp.Diagnostics[0]
+			expectedSeverity := tfprotov6.DiagnosticSeverityWarning
+			if c.expectedError {
+				expectedSeverity = tfprotov6.DiagnosticSeverityError
+			}
+
+			if diag.Severity != expectedSeverity || !strings.Contains(diag.Detail, "ze-get-token-error") {
+				t.Errorf("unexpected diagnostic %s: %s: %s", diag.Severity, diag.Summary, diag.Detail)
+			}
+		})
+	}
+}
+
+func TestEphemeralCredentialRenewAfterClose(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var requests atomic.Int32
+	s := testNewEphemeralServer(t, testNewCountingCredentialFn(&requests, time.Hour), map[string]tftypes.Value{
+		"renew_margin": tftypes.NewValue(tftypes.String, "2h"),
+	})
+	typeName := "azidentity_client_secret_credential"
+	openResp, _ := s.open(typeName, testClientSecretCredentialConfig(nil))
+
+	closeResp := s.close(typeName, openResp.Private)
+	testRequireNoDiagnostics(t, closeResp.Diagnostics)
+
+	// The entry outlives the closed resource until its token expires, so it's
+	// still renewed with the configuration kept in the cache.
+	renewResp := s.renew(typeName, openResp.Private)
+	testRequireNoDiagnostics(t, renewResp.Diagnostics)
+
+	if renewResp.RenewAt.IsZero() {
+		t.Error("expected the cached token to be renewed after closing")
+	}
+
+	if requests.Load() != 2 {
+		t.Errorf("expected 2 token requests, got %d", requests.Load())
+	}
+}
+
+func testRequireRenewIn(t *testing.T, renewAt time.Time, expected time.Duration) {
+	t.Helper()
+
+	renewIn := time.Until(renewAt)
+	if renewIn > expected || renewIn < expected-time.Minute {
+		t.Errorf("expected renewal in %s, got %s", expected, renewIn)
+	}
+}
+
+func TestEphemeralCredentialRenewInvalidMargin(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	server, err := providerserver.NewProtocol6WithError(testNew(t, testNewTestCredentialFn(t))())()
+	if err != nil {
+		t.Fatalf("failed to create provider server: %s", err)
+	}
+
+	schemas, err := server.GetProviderSchema(t.Context(), &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("failed to get provider schema: %s", err)
+	}
+
+	s := &testEphemeralServer{t: t, server: server, schemas: schemas}
+	config := s.dynamicValue(schemas.Provider, map[string]tftypes.Value{
+		"renew_margin": tftypes.NewValue(tftypes.String, "ze-invalid"),
+	})
+	resp, err := server.ConfigureProvider(t.Context(), &tfprotov6.ConfigureProviderRequest{Config: &config})
+	if err != nil {
+		t.Fatalf("failed to configure provider: %s", err)
+	}
+
+	if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Summary != "Invalid Renew Margin" {
+		t.Errorf("expected an invalid renew margin diagnostic, got %v", resp.Diagnostics)
+	}
+}
+
+func TestEphemeralCredentialRenewAfterRestart(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var requests atomic.Int32
+	s := testNewEphemeralServer(t, testNewCountingCredentialFn(&requests, time.Hour), nil)
+	typeName := "azidentity_client_secret_credential"
+	openResp, _ := s.open(typeName, testClientSecretCredentialConfig(nil))
+
+	// A restarted provider has an empty token cache, without the configuration
+	// needed to request a new token.
+	restarted := testNewEphemeralServer(t, testNewCountingCredentialFn(&requests, time.Hour), nil)
+	renewResp := restarted.renew(typeName, openResp.Private)
+	if len(renewResp.Diagnostics) != 1 || renewResp.Diagnostics[0].Severity != tfprotov6.DiagnosticSeverityWarning || renewResp.Diagnostics[0].Summary != "Cached Token Not Renewed" {
+		t.Fatalf("expected a warning for the missing cached token, got %v", renewResp.Diagnostics)
+	}
+
+	if !renewResp.RenewAt.IsZero() {
+		t.Errorf("expected no further renewal, got %s", renewResp.RenewAt)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 token request, got %d", requests.Load())
+	}
+}
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// retryConfig is the retry policy of the HTTP requests sent by a credential.
+// Zero values keep the defaults of the credential, MaxRetries uses -1 for no
+// retries as policy.RetryOptions does, and a non-nil empty StatusCodes
+// disables retrying on status codes.
+type retryConfig struct {
+	MaxRetries    int32         `json:"max_retries"`
+	RetryDelay    time.Duration `json:"retry_delay"`
+	MaxRetryDelay time.Duration `json:"max_retry_delay"`
+	StatusCodes   []int         `json:"status_codes"`
+}
+
+// newRetryConfig converts the retry attributes of a resource, already merged
+// with the provider defaults.
+func newRetryConfig(maxRetries types.Int64, retryDelay types.String, maxRetryDelay types.String, statusCodes types.Set) (retryConfig, error) {
+	var cfg retryConfig
+	if !maxRetries.IsNull() && !maxRetries.IsUnknown() {
+		cfg.MaxRetries = int32(maxRetries.ValueInt64())
+		if cfg.MaxRetries == 0 {
+			cfg.MaxRetries = -1
+		}
+	}
+
+	var err error
+	cfg.RetryDelay, err = parseRetryDelay("retry_delay", retryDelay)
+	if err != nil {
+		return retryConfig{}, err
+	}
+
+	cfg.MaxRetryDelay, err = parseRetryDelay("max_retry_delay", maxRetryDelay)
+	if err != nil {
+		return retryConfig{}, err
+	}
+
+	if !statusCodes.IsNull() && !statusCodes.IsUnknown() {
+		cfg.StatusCodes = []int{}
+		for _, v := range statusCodes.Elements() {
+			code, ok := v.(types.Int64)
+			if !ok {
+				continue
+			}
+			cfg.StatusCodes = append(cfg.StatusCodes, int(code.ValueInt64()))
+		}
+	}
+
+	return cfg, nil
+}
+
+func parseRetryDelay(name string, input types.String) (time.Duration, error) {
+	if input.IsNull() || input.IsUnknown() {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(input.ValueString())
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s %q as a duration: %w", name, input.ValueString(), err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("%s %q must be positive", name, input.ValueString())
+	}
+
+	return d, nil
+}
+
+func (c retryConfig) options() policy.RetryOptions {
+	return policy.RetryOptions{
+		MaxRetries:    c.MaxRetries,
+		RetryDelay:    c.RetryDelay,
+		MaxRetryDelay: c.MaxRetryDelay,
+		StatusCodes:   c.StatusCodes,
+	}
+}
+
+// newClientOptions returns the client options shared by the credentials
+// sending HTTP requests: the cloud, the provider transport, the retry policy
+// and the policies counting the attempts of each request.
+func newClientOptions(cfg credentialConfig) azcore.ClientOptions {
+	options := azcore.ClientOptions{
+		Cloud: cfg.CloudConfig,
+		Retry: cfg.Retry.options(),
+	}
+	if cfg.HTTPClient != nil {
+		options.Transport = cfg.HTTPClient
+	}
+	if cfg.Attempts != nil {
+		options.PerCallPolicies = []policy.Policy{&retryAttemptsPerCallPolicy{attempts: cfg.Attempts}}
+		options.PerRetryPolicies = []policy.Policy{&retryAttemptsPerRetryPolicy{attempts: cfg.Attempts}}
+	}
+
+	return options
+}
+
+// retryAttempts counts the tries of the HTTP requests sent by a credential,
+// so the attempts used by the last request can be reported when giving up.
+type retryAttempts struct {
+	mu    sync.Mutex
+	tries int
+}
+
+func (a *retryAttempts) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.tries = 0
+}
+
+func (a *retryAttempts) add() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.tries++
+
+	return a.tries
+}
+
+// last returns the number of tries of the last request, 0 when the credential
+// didn't send any request.
+func (a *retryAttempts) last() int {
+	if a == nil {
+		return 0
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.tries
+}
+
+// retryAttemptsPerCallPolicy runs once per request, before the retry policy.
+type retryAttemptsPerCallPolicy struct {
+	attempts *retryAttempts
+}
+
+func (p *retryAttemptsPerCallPolicy) Do(req *policy.Request) (*http.Response, error) {
+	p.attempts.reset()
+
+	return req.Next()
+}
+
+// retryAttemptsPerRetryPolicy runs for every try of a request.
+type retryAttemptsPerRetryPolicy struct {
+	attempts *retryAttempts
+}
+
+func (p *retryAttemptsPerRetryPolicy) Do(req *policy.Request) (*http.Response, error) {
+	attempt := p.attempts.add()
+	raw := req.Raw()
+	ctx := raw.Context()
+	endpoint := fmt.Sprintf("%s %s://%s%s", raw.Method, raw.URL.Scheme, raw.URL.Host, raw.URL.Path)
+
+	res, err := req.Next()
+	if err != nil {
+		tflog.Debug(ctx, fmt.Sprintf("Attempt %d of %s failed: %s", attempt, endpoint, err))
+		return res, err
+	}
+
+	tflog.Debug(ctx, fmt.Sprintf("Attempt %d of %s returned status code %d", attempt, endpoint, res.StatusCode))
+
+	return res, nil
+}
+
+// withAttempts adds the attempts used by the last request of a credential to
+// its error, when it sent any.
+func withAttempts(ctx context.Context, err error, attempts *retryAttempts) error {
+	n := attempts.last()
+	if n == 0 {
+		return err
+	}
+
+	tflog.Info(ctx, fmt.Sprintf("Giving up acquiring a token after %d attempt(s)", n))
+
+	return fmt.Errorf("giving up after %d attempt(s): %w", n, err)
+}
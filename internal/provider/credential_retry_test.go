@@ -0,0 +1,312 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestNewRetryConfig(t *testing.T) {
+	cases := []struct {
+		name          string
+		maxRetries    types.Int64
+		retryDelay    types.String
+		maxRetryDelay types.String
+		statusCodes   types.Set
+		expected      retryConfig
+		expectedError string
+	}{
+		{
+			name:          "unset",
+			maxRetries:    types.Int64Null(),
+			retryDelay:    types.StringNull(),
+			maxRetryDelay: types.StringNull(),
+			statusCodes:   types.SetNull(types.Int64Type),
+			expected:      retryConfig{},
+		},
+		{
+			name:          "set",
+			maxRetries:    types.Int64Value(5),
+			retryDelay:    types.StringValue("2s"),
+			maxRetryDelay: types.StringValue("1m"),
+			statusCodes:   types.SetValueMust(types.Int64Type, []attr.Value{types.Int64Value(429)}),
+			expected: retryConfig{
+				MaxRetries:    5,
+				RetryDelay:    2 * time.Second,
+				MaxRetryDelay: time.Minute,
+				StatusCodes:   []int{429},
+			},
+		},
+		{
+			name:          "no_retries",
+			maxRetries:    types.Int64Value(0),
+			retryDelay:    types.StringNull(),
+			maxRetryDelay: types.StringNull(),
+			statusCodes:   types.SetValueMust(types.Int64Type, []attr.Value{}),
+			expected: retryConfig{
+				MaxRetries:  -1,
+				StatusCodes: []int{},
+			},
+		},
+		{
+			name:          "invalid_retry_delay",
+			maxRetries:    types.Int64Null(),
+			retryDelay:    types.StringValue("ze-invalid"),
+			maxRetryDelay: types.StringNull(),
+			statusCodes:   types.SetNull(types.Int64Type),
+			expectedError: `failed to parse retry_delay "ze-invalid" as a duration`,
+		},
+		{
+			name:          "negative_max_retry_delay",
+			maxRetries:    types.Int64Null(),
+			retryDelay:    types.StringNull(),
+			maxRetryDelay: types.StringValue("-1s"),
+			statusCodes:   types.SetNull(types.Int64Type),
+			expectedError: `max_retry_delay "-1s" must be positive`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg, err := newRetryConfig(c.maxRetries, c.retryDelay, c.maxRetryDelay, c.statusCodes)
+			if c.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", c.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if fmt.Sprintf("%#v", cfg) != fmt.Sprintf("%#v", c.expected) {
+				t.Errorf("expected %#v, got %#v", c.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestGetTokenRetry(t *testing.T) {
+	var tokenRequests atomic.Int32
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if tokenRequests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":"temporarily_unavailable"}`)
+			return
+		}
+
+		testEntraTokenResponse(w, "ze-retried-token")
+	})
+
+	token, _, err := getToken(t.Context(), clientSecretCredential, newGetCredentialFn(), credentialConfig{
+		TenantID:     "ze-tenant",
+		ClientID:     "ze-client",
+		ClientSecret: "ze-secret",
+		Scopes:       []string{"ze-scope"},
+		Timeout:      defaultTimeout,
+		Retry: retryConfig{
+			MaxRetries: 3,
+			RetryDelay: time.Millisecond,
+		},
+		HTTPClient: testNewRedirectHttpClient(t, server.URL),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if token.Token != "ze-retried-token" {
+		t.Errorf("expected token %q, got %q", "ze-retried-token", token.Token)
+	}
+
+	if tokenRequests.Load() != 3 {
+		t.Errorf("expected 3 token requests, got %d", tokenRequests.Load())
+	}
+}
+
+func TestGetTokenRetryGivingUp(t *testing.T) {
+	cases := []struct {
+		name          string
+		retry         retryConfig
+		expectedError string
+	}{
+		{
+			name: "max_retries",
+			retry: retryConfig{
+				MaxRetries: 2,
+				RetryDelay: time.Millisecond,
+			},
+			expectedError: "giving up after 3 attempt(s)",
+		},
+		{
+			name: "no_retries",
+			retry: retryConfig{
+				MaxRetries: -1,
+			},
+			expectedError: "giving up after 1 attempt(s)",
+		},
+		{
+			name: "status_codes",
+			retry: retryConfig{
+				RetryDelay:  time.Millisecond,
+				StatusCodes: []int{http.StatusInternalServerError},
+			},
+			expectedError: "giving up after 1 attempt(s)",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprint(w, `{"error":"too_many_requests"}`)
+			})
+
+			_, errSummary, err := getToken(t.Context(), clientSecretCredential, newGetCredentialFn(), credentialConfig{
+				TenantID:     "ze-tenant",
+				ClientID:     "ze-client",
+				ClientSecret: "ze-secret",
+				Scopes:       []string{"ze-scope"},
+				Timeout:      defaultTimeout,
+				Retry:        c.retry,
+				HTTPClient:   testNewRedirectHttpClient(t, server.URL),
+			})
+			if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+				t.Fatalf("expected error containing %q, got %v", c.expectedError, err)
+			}
+
+			if errSummary != "Error getting token" {
+				t.Errorf("expected error summary %q, got %q", "Error getting token", errSummary)
+			}
+		})
+	}
+}
+
+func TestEphemeralClientSecretCredentialRetry(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":"temporarily_unavailable"}`)
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+provider "azidentity" {
+	max_retries        = 1
+	retry_delay        = "1ms"
+	retry_status_codes = [503]
+}
+
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id         = "ze-tenant"
+	client_id         = "ze-client"
+	client_secret     = "ze-secret"
+	scopes            = ["ze-scope"]
+	continue_on_error = true
+}
+
+ephemeral "azidentity_client_secret_credential" "that" {
+	tenant_id         = "ze-tenant"
+	client_id         = "ze-client"
+	client_secret     = "ze-secret"
+	scopes            = ["ze-scope"]
+	max_retries       = 0
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = [
+    ephemeral.azidentity_client_secret_credential.this.error,
+    ephemeral.azidentity_client_secret_credential.that.error,
+  ]
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringRegexp(regexp.MustCompile(`^giving up after 2 attempt\(s\): `)),
+							knownvalue.StringRegexp(regexp.MustCompile(`^giving up after 1 attempt\(s\): `)),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralCredentialRetryInvalid(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+	retry_delay   = "ze-invalid"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this.access_token
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`failed to parse retry_delay "ze-invalid" as a duration`),
+			},
+			{
+				Config: `
+provider "azidentity" {
+	max_retry_delay = "-1s"
+}
+
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this.access_token
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Retry Configuration`),
+			},
+		},
+	})
+}
@@ -1,8 +1,16 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -92,3 +100,332 @@ func testNewTestCredentialFailureFn(t *testing.T) getCredentialFn {
 		}, nil
 	}
 }
+
+// testNewEntraServer starts a minimal stand-in for the Microsoft Entra ID
+// token service. Requests from the SDK are expected to be sent to it using
+// testNewRedirectHttpClient, which is why all endpoints returned in the
+// discovery documents point at login.microsoftonline.com. Token requests are
+// passed to tokenHandler.
+func testNewEntraServer(t *testing.T, tokenHandler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(testEntraHandler(t, "https://login.microsoftonline.com", tokenHandler))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// testNewEntraAuthorityServer starts a Microsoft Entra stand-in acting as the
+// authority host itself, for credentials configured with a custom authority
+// host. Use the client of the returned server, which trusts its certificate.
+func testNewEntraAuthorityServer(t *testing.T, tokenHandler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	server.Config.Handler = testEntraHandler(t, server.URL, tokenHandler)
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func testEntraHandler(t *testing.T, authorityHost string, tokenHandler http.HandlerFunc) http.HandlerFunc {
+	t.Helper()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		tenant := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+		authority := fmt.Sprintf("%s/%s", authorityHost, tenant)
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/discovery/instance"):
+			fmt.Fprintf(w, `{"tenant_discovery_endpoint":"%s/v2.0/.well-known/openid-configuration","api-version":"1.1","metadata":[{"preferred_network":"login.microsoftonline.com","preferred_cache":"login.windows.net","aliases":["login.microsoftonline.com","login.windows.net"]}]}`, authority)
+		case strings.HasSuffix(r.URL.Path, "/.well-known/openid-configuration"):
+			fmt.Fprintf(w, `{"authorization_endpoint":"%[1]s/oauth2/v2.0/authorize","token_endpoint":"%[1]s/oauth2/v2.0/token","issuer":"%[1]s/v2.0"}`, authority)
+		case strings.HasPrefix(r.URL.Path, "/common/UserRealm/"):
+			fmt.Fprint(w, `{"account_type":"Managed","domain_name":"example.com","cloud_instance_name":"microsoftonline.com","cloud_audience_urn":"urn:federation:MicrosoftOnline"}`)
+		case strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token"):
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("failed to parse token request form: %s", err)
+			}
+
+			tokenHandler(w, r)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func testEntraTokenResponse(w http.ResponseWriter, token string) {
+	fmt.Fprintf(w, `{"access_token":"%s","expires_in":3600,"token_type":"Bearer"}`, token)
+}
+
+func TestNewWorkloadIdentityCredentialReadsTokenFileOnEveryRequest(t *testing.T) {
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	assertions := []string{}
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertions = append(assertions, r.PostForm.Get("client_assertion"))
+		testEntraTokenResponse(w, "ze-workload-token")
+	})
+
+	cred, err := newWorkloadIdentityCredential(credentialConfig{
+		TenantID:      "ze-tenant",
+		ClientID:      "ze-client",
+		TokenFilePath: tokenFilePath,
+		HTTPClient:    testNewRedirectHttpClient(t, server.URL),
+	})
+	if err != nil {
+		t.Fatalf("failed to create credential: %s", err)
+	}
+
+	for i, scope := range []string{"ze-scope-1", "ze-scope-2"} {
+		err := os.WriteFile(tokenFilePath, []byte(fmt.Sprintf("ze-federated-token-%d", i)), 0o600)
+		if err != nil {
+			t.Fatalf("failed to write token file: %s", err)
+		}
+
+		_, err = cred.GetToken(t.Context(), policy.TokenRequestOptions{Scopes: []string{scope}})
+		if err != nil {
+			t.Fatalf("failed to get token: %s", err)
+		}
+	}
+
+	expected := []string{"ze-federated-token-0", "ze-federated-token-1"}
+	if strings.Join(assertions, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected assertions %v, got %v", expected, assertions)
+	}
+}
+
+func TestGitHubActionsAssertion(t *testing.T) {
+	cases := []struct {
+		name             string
+		expiresOn        time.Time
+		expectedRequests int
+	}{
+		{
+			name:             "reuses valid token",
+			expiresOn:        time.Now().Add(time.Hour),
+			expectedRequests: 1,
+		},
+		{
+			name:             "requests expired token again",
+			expiresOn:        time.Now().Add(-time.Hour),
+			expectedRequests: 3,
+		},
+		{
+			name:             "requests token about to expire again",
+			expiresOn:        time.Now().Add(gitHubActionsAssertionExpiryMargin / 2),
+			expectedRequests: 3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			idToken := testNewJWT(t, c.expiresOn)
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				fmt.Fprintf(w, `{"value":"%s"}`, idToken)
+			}))
+
+			defer server.Close()
+
+			assertion := &gitHubActionsAssertion{
+				httpClient:   server.Client(),
+				requestURL:   server.URL,
+				requestToken: "ze-request-token",
+				audience:     defaultGitHubActionsAudience,
+			}
+
+			for range 3 {
+				got, err := assertion.get(t.Context())
+				if err != nil {
+					t.Fatalf("failed to get assertion: %s", err)
+				}
+
+				if got != idToken {
+					t.Fatalf("expected assertion %q, got %q", idToken, got)
+				}
+			}
+
+			if requests != c.expectedRequests {
+				t.Fatalf("expected %d requests, got %d", c.expectedRequests, requests)
+			}
+		})
+	}
+}
+
+// testNewJWT returns an unsigned JWT expiring at expiresOn, for use where only
+// the claims of the token are inspected.
+func testNewJWT(t *testing.T, expiresOn time.Time) string {
+	t.Helper()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, `{"aud":"api://AzureADTokenExchange","exp":%d,"iss":"https://token.actions.githubusercontent.com","sub":"ze-subject"}`, expiresOn.Unix()))
+	signature := base64.RawURLEncoding.EncodeToString([]byte("ze-signature"))
+
+	return strings.Join([]string{header, payload, signature}, ".")
+}
+
+func TestAzureDeveloperCLITokenCredentialResolveTenant(t *testing.T) {
+	cases := []struct {
+		name                       string
+		tenantID                   string
+		additionallyAllowedTenants []string
+		requested                  string
+		expectedTenantID           string
+		expectedErr                bool
+	}{
+		{
+			name: "no tenant",
+		},
+		{
+			name:             "default tenant",
+			tenantID:         "ze-tenant",
+			expectedTenantID: "ze-tenant",
+		},
+		{
+			name:             "requested tenant without default",
+			requested:        "ze-other-tenant",
+			expectedTenantID: "ze-other-tenant",
+		},
+		{
+			name:             "requested default tenant",
+			tenantID:         "ze-tenant",
+			requested:        "ze-tenant",
+			expectedTenantID: "ze-tenant",
+		},
+		{
+			name:                       "requested additionally allowed tenant",
+			tenantID:                   "ze-tenant",
+			additionallyAllowedTenants: []string{"ze-other-tenant"},
+			requested:                  "ze-other-tenant",
+			expectedTenantID:           "ze-other-tenant",
+		},
+		{
+			name:                       "requested tenant with wildcard",
+			tenantID:                   "ze-tenant",
+			additionallyAllowedTenants: []string{"*"},
+			requested:                  "ze-other-tenant",
+			expectedTenantID:           "ze-other-tenant",
+		},
+		{
+			name:             "requested tenant with organizations default",
+			tenantID:         "organizations",
+			requested:        "ze-other-tenant",
+			expectedTenantID: "ze-other-tenant",
+		},
+		{
+			name:        "requested tenant not allowed",
+			tenantID:    "ze-tenant",
+			requested:   "ze-other-tenant",
+			expectedErr: true,
+		},
+		{
+			name:                       "requested tenant not additionally allowed",
+			additionallyAllowedTenants: []string{"ze-tenant"},
+			requested:                  "ze-other-tenant",
+			expectedErr:                true,
+		},
+		{
+			name:        "requested invalid tenant",
+			requested:   "ze-tenant --debug",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cred := &azureDeveloperCLITokenCredential{
+				tenantID:                   c.tenantID,
+				additionallyAllowedTenants: c.additionallyAllowedTenants,
+			}
+
+			tenantID, err := cred.resolveTenant(c.requested)
+			if c.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got tenant %q", tenantID)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if tenantID != c.expectedTenantID {
+				t.Fatalf("expected tenant %q, got %q", c.expectedTenantID, tenantID)
+			}
+		})
+	}
+}
+
+func TestAzureDeveloperCLITokenCredentialValidation(t *testing.T) {
+	runCmdFn := func(ctx context.Context, stdout *bytes.Buffer, stderr *bytes.Buffer, extraEnv []string, name string, args []string) error {
+		t.Errorf("unexpected command %s %v", name, args)
+		return nil
+	}
+
+	_, err := newAzureDeveloperCLICredential(credentialConfig{TenantID: "ze-tenant/..", RunCmdFn: runCmdFn})
+	if err == nil {
+		t.Error("expected error for invalid tenant")
+	}
+
+	cred, err := newAzureDeveloperCLICredential(credentialConfig{TenantID: "ze-tenant", RunCmdFn: runCmdFn})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = cred.GetToken(t.Context(), policy.TokenRequestOptions{Scopes: []string{"ze-scope --debug"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid scope") {
+		t.Errorf("expected invalid scope error, got %v", err)
+	}
+}
+
+func TestGetChainedTokenReusedLinks(t *testing.T) {
+	var requests atomic.Int32
+	countingFn := testNewCountingCredentialFn(&requests, time.Hour)
+	var workloadIdentityFails atomic.Bool
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		if credType == workloadIdentityCredential && workloadIdentityFails.Load() {
+			return nil, fmt.Errorf("ze-workload-identity-error")
+		}
+
+		return countingFn(credType, cfg)
+	}
+
+	links := []*chainedCredentialLink{
+		{name: "workload_identity", credType: workloadIdentityCredential},
+		{name: "client_secret", credType: clientSecretCredential},
+	}
+	cfg := credentialConfig{Scopes: []string{"ze-scope"}, Timeout: defaultTimeout}
+
+	_, index, _, err := getChainedToken(t.Context(), getCredFn, links, cfg)
+	if err != nil || index != 0 {
+		t.Fatalf("expected a token from source 0, got source %d and %v", index, err)
+	}
+
+	// The first link is skipped when the chain is renewed, so it must not be
+	// reported as the one that succeeded earlier.
+	workloadIdentityFails.Store(true)
+	_, index, _, err = getChainedToken(t.Context(), getCredFn, links, cfg)
+	if err != nil || index != 1 {
+		t.Fatalf("expected a token from source 1, got source %d and %v", index, err)
+	}
+
+	// The error of the first link is from the last request only.
+	workloadIdentityFails.Store(false)
+	_, index, _, err = getChainedToken(t.Context(), getCredFn, links, cfg)
+	if err != nil || index != 0 {
+		t.Fatalf("expected a token from source 0, got source %d and %v", index, err)
+	}
+
+	for i, link := range links {
+		if link.err != nil {
+			t.Errorf("expected no error for source %d, got %s", i, link.err)
+		}
+	}
+}
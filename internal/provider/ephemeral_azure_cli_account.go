@@ -14,6 +14,7 @@ import (
 )
 
 var _ ephemeral.EphemeralResource = &ephemeralAzureCLIAccount{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralAzureCLIAccount{}
 
 func newEphemeralAzureCLIAccount() ephemeral.EphemeralResource {
 	return &ephemeralAzureCLIAccount{}
@@ -193,6 +194,10 @@ func (r *ephemeralAzureCLIAccount) Open(ctx context.Context, req ephemeral.OpenR
 	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
 }
 
+// Close is a no-op, the resource keeps nothing beyond its result.
+func (r *ephemeralAzureCLIAccount) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+}
+
 func compactJSON(input string) (string, error) {
 	var data interface{}
 	if err := json.Unmarshal([]byte(input), &data); err != nil {
@@ -3,7 +3,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
@@ -11,13 +10,17 @@ import (
 )
 
 var _ ephemeral.EphemeralResource = &ephemeralAzureCLICredential{}
+var _ ephemeral.EphemeralResourceWithRenew = &ephemeralAzureCLICredential{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralAzureCLICredential{}
 
 func newEphemeralAzureCLICredential() ephemeral.EphemeralResource {
 	return &ephemeralAzureCLICredential{}
 }
 
 type ephemeralAzureCLICredential struct {
-	getCredFn getCredentialFn
+	getCredFn  getCredentialFn
+	defaults   providerDefaults
+	tokenCache *tokenCache
 }
 
 type ephemeralAzureCLICredentialModel struct {
@@ -29,22 +32,22 @@ type ephemeralAzureCLICredentialModel struct {
 	Scopes                     types.Set    `tfsdk:"scopes"`
 	ContinueOnError            types.Bool   `tfsdk:"continue_on_error"`
 	Timeout                    types.String `tfsdk:"timeout"`
-	AccessToken                types.String `tfsdk:"access_token"`
-	ExpiresOn                  types.String `tfsdk:"expires_on"`
-	Success                    types.Bool   `tfsdk:"success"`
-	Error                      types.String `tfsdk:"error"`
+	tokenModel
+	CacheHit types.Bool   `tfsdk:"cache_hit"`
+	Success  types.Bool   `tfsdk:"success"`
+	Error    types.String `tfsdk:"error"`
 }
 
-func (r *ephemeralAzureCLICredentialModel) newCredentialConfig(ctx context.Context) credentialConfig {
+func (r *ephemeralAzureCLICredentialModel) newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error) {
 	return credentialConfig{
-		TenantID:                   r.TenantID.ValueString(),
+		TenantID:                   defaults.configuredTenantID(ctx, r.TenantID).ValueString(),
 		SubscriptionID:             r.SubscriptionID.ValueString(),
-		AdditionallyAllowedTenants: typesSetToStringSlice(r.AdditionallyAllowedTenants),
+		AdditionallyAllowedTenants: typesSetToStringSlice(defaults.additionallyAllowedTenants(ctx, r.AdditionallyAllowedTenants)),
 		Claims:                     r.Claims.ValueString(),
 		Scopes:                     typesSetToStringSlice(r.Scopes),
 		ContinueOnError:            r.ContinueOnError.ValueBool(),
-		Timeout:                    parseTimeout(ctx, r.Timeout),
-	}
+		Timeout:                    parseTimeout(ctx, defaults.timeout(ctx, r.Timeout)),
+	}, nil
 }
 
 func (r *ephemeralAzureCLICredential) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
@@ -54,9 +57,9 @@ func (r *ephemeralAzureCLICredential) Metadata(_ context.Context, req ephemeral.
 func (r *ephemeralAzureCLICredential) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		MarkdownDescription: "The `azidentity_azure_cli_credential` resource provides authentication using an active **Azure CLI session**. This allows Terraform to acquire tokens from the CLI without requiring stored credentials.",
-		Attributes: map[string]schema.Attribute{
+		Attributes: withTokenSchemaAttributes(map[string]schema.Attribute{
 			"tenant_id": schema.StringAttribute{
-				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure CLI and workload identity. The default is empty, use 'organizations' or 'common' if you can't provide one but required to use one.",
+				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure CLI and workload identity. The default is the `tenant_id` configured on the provider, not its AZURE_TENANT_ID fallback, otherwise empty, use 'organizations' or 'common' if you can't provide one but required to use one.",
 				Optional:            true,
 			},
 			"subscription_id": schema.StringAttribute{
@@ -64,7 +67,7 @@ func (r *ephemeralAzureCLICredential) Schema(ctx context.Context, _ ephemeral.Sc
 				Optional:            true,
 			},
 			"additionally_allowed_tenants": schema.SetAttribute{
-				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. This value can also be set as a semicolon delimited list of tenants in the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS. The default is an empty list.",
+				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. This value can also be set as a semicolon delimited list of tenants in the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS. The default is the provider `additionally_allowed_tenants`, or an empty list.",
 				Optional:            true,
 				ElementType:         types.StringType,
 			},
@@ -86,16 +89,11 @@ func (r *ephemeralAzureCLICredential) Schema(ctx context.Context, _ ephemeral.Sc
 				Optional:            true,
 			},
 			"timeout": schema.StringAttribute{
-				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is 30 seconds ('30s').",
+				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is the provider `timeout`, or 30 seconds ('30s').",
 				Optional:            true,
 			},
-			"access_token": schema.StringAttribute{
-				MarkdownDescription: "The issued access token.",
-				Computed:            true,
-				Sensitive:           true,
-			},
-			"expires_on": schema.StringAttribute{
-				MarkdownDescription: "When the issued access token expires in RFC3339 format.",
+			"cache_hit": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if the access token was reused from the token cache of the provider instead of being requested.",
 				Computed:            true,
 			},
 			"success": schema.BoolAttribute{
@@ -106,7 +104,7 @@ func (r *ephemeralAzureCLICredential) Schema(ctx context.Context, _ ephemeral.Sc
 				MarkdownDescription: "Error message if acquiring a token failed.",
 				Computed:            true,
 			},
-		},
+		}),
 	}
 }
 
@@ -125,6 +123,8 @@ func (p *ephemeralAzureCLICredential) Configure(ctx context.Context, req ephemer
 	}
 
 	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.defaults = provider.defaults
 }
 
 func (r *ephemeralAzureCLICredential) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
@@ -135,8 +135,13 @@ func (r *ephemeralAzureCLICredential) Open(ctx context.Context, req ephemeral.Op
 		return
 	}
 
-	cfg := data.newCredentialConfig(ctx)
-	token, errSummary, err := getToken(ctx, azureCLICredential, r.getCredFn, cfg)
+	cfg, err := data.newCredentialConfig(ctx, r.defaults)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Credential Configuration", err.Error())
+		return
+	}
+
+	token, cacheHit, errSummary, err := getCachedToken(ctx, r.tokenCache, azureCLICredential, r.getCredFn, cfg)
 	if err != nil && cfg.ContinueOnError {
 		data.Error = types.StringValue(err.Error())
 		data.Success = types.BoolValue(false)
@@ -149,9 +154,19 @@ func (r *ephemeralAzureCLICredential) Open(ctx context.Context, req ephemeral.Op
 		return
 	}
 
-	data.AccessToken = types.StringValue(token.Token)
-	data.ExpiresOn = types.StringValue(token.ExpiresOn.Format(time.RFC3339))
+	data.setToken(token)
+	data.CacheHit = types.BoolValue(cacheHit)
 	data.Success = types.BoolValue(true)
 
+	resp.Diagnostics.Append(setCredentialRenewState(ctx, resp, r.tokenCache, azureCLICredential, cfg, r.defaults.RenewMargin, token)...)
+
 	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
 }
+
+func (r *ephemeralAzureCLICredential) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	renewCredential(ctx, req, resp, r.tokenCache)
+}
+
+func (r *ephemeralAzureCLICredential) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	closeCredential(ctx, req, resp, r.tokenCache)
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ ephemeral.EphemeralResource = &ephemeralAzureDeveloperCLICredential{}
+var _ ephemeral.EphemeralResourceWithRenew = &ephemeralAzureDeveloperCLICredential{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralAzureDeveloperCLICredential{}
+
+func newEphemeralAzureDeveloperCLICredential() ephemeral.EphemeralResource {
+	return &ephemeralAzureDeveloperCLICredential{}
+}
+
+type ephemeralAzureDeveloperCLICredential struct {
+	getCredFn  getCredentialFn
+	defaults   providerDefaults
+	runCmdFn   runCommandFn
+	tokenCache *tokenCache
+}
+
+type ephemeralAzureDeveloperCLICredentialModel struct {
+	TenantID                   types.String `tfsdk:"tenant_id"`
+	AdditionallyAllowedTenants types.Set    `tfsdk:"additionally_allowed_tenants"`
+	Scopes                     types.Set    `tfsdk:"scopes"`
+	ContinueOnError            types.Bool   `tfsdk:"continue_on_error"`
+	Timeout                    types.String `tfsdk:"timeout"`
+	tokenModel
+	CacheHit types.Bool   `tfsdk:"cache_hit"`
+	Success  types.Bool   `tfsdk:"success"`
+	Error    types.String `tfsdk:"error"`
+}
+
+func (r *ephemeralAzureDeveloperCLICredentialModel) newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error) {
+	return credentialConfig{
+		TenantID:                   defaults.configuredTenantID(ctx, r.TenantID).ValueString(),
+		AdditionallyAllowedTenants: typesSetToStringSlice(defaults.additionallyAllowedTenants(ctx, r.AdditionallyAllowedTenants)),
+		Scopes:                     typesSetToStringSlice(r.Scopes),
+		ContinueOnError:            r.ContinueOnError.ValueBool(),
+		Timeout:                    parseTimeout(ctx, defaults.timeout(ctx, r.Timeout)),
+	}, nil
+}
+
+func (r *ephemeralAzureDeveloperCLICredential) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_azure_developer_cli_credential"
+}
+
+func (r *ephemeralAzureDeveloperCLICredential) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "The `azidentity_azure_developer_cli_credential` resource provides authentication using an active **Azure Developer CLI session**, as created by `azd auth login`. Tokens are acquired by running `azd auth token`, so the `azd` executable has to be available in the path.",
+		Attributes: withTokenSchemaAttributes(map[string]schema.Attribute{
+			"tenant_id": schema.StringAttribute{
+				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure Developer CLI. The default is the `tenant_id` configured on the provider, not its AZURE_TENANT_ID fallback, otherwise empty, which uses the tenant the Azure Developer CLI is logged in to.",
+				Optional:            true,
+			},
+			"additionally_allowed_tenants": schema.SetAttribute{
+				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. The default is the provider `additionally_allowed_tenants`, or an empty list.",
+				Optional:            true,
+				ElementType:         types.StringType,
+			},
+			"scopes": schema.SetAttribute{
+				MarkdownDescription: "Scopes contains the list of permission scopes required for the token. E.g. https://management.azure.com/.default for Azure Resource Manager or https://graph.microsoft.com/.default for Microsoft Graph.",
+				Required:            true,
+				ElementType:         types.StringType,
+			},
+			"continue_on_error": schema.BoolAttribute{
+				MarkdownDescription: "ContinueOnError indicates whether to continue on error when acquiring a token. The default is false.",
+				Optional:            true,
+			},
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is the provider `timeout`, or 30 seconds ('30s').",
+				Optional:            true,
+			},
+			"cache_hit": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if the access token was reused from the token cache of the provider instead of being requested.",
+				Computed:            true,
+			},
+			"success": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if a token was successfully acquired.",
+				Computed:            true,
+			},
+			"error": schema.StringAttribute{
+				MarkdownDescription: "Error message if acquiring a token failed.",
+				Computed:            true,
+			},
+		}),
+	}
+}
+
+func (p *ephemeralAzureDeveloperCLICredential) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*azidentityProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected ProviderData Type",
+			fmt.Sprintf("Expected *azidentityProvider, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+
+	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.defaults = provider.defaults
+	p.runCmdFn = provider.runCmdFn
+}
+
+func (r *ephemeralAzureDeveloperCLICredential) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data ephemeralAzureDeveloperCLICredentialModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	cfg, err := data.newCredentialConfig(ctx, r.defaults)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Credential Configuration", err.Error())
+		return
+	}
+
+	cfg.RunCmdFn = r.runCmdFn
+	token, cacheHit, errSummary, err := getCachedToken(ctx, r.tokenCache, azureDeveloperCLICredential, r.getCredFn, cfg)
+	if err != nil && cfg.ContinueOnError {
+		data.Error = types.StringValue(err.Error())
+		data.Success = types.BoolValue(false)
+		resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError(errSummary, err.Error())
+		return
+	}
+
+	data.setToken(token)
+	data.CacheHit = types.BoolValue(cacheHit)
+	data.Success = types.BoolValue(true)
+
+	resp.Diagnostics.Append(setCredentialRenewState(ctx, resp, r.tokenCache, azureDeveloperCLICredential, cfg, r.defaults.RenewMargin, token)...)
+
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *ephemeralAzureDeveloperCLICredential) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	renewCredential(ctx, req, resp, r.tokenCache)
+}
+
+func (r *ephemeralAzureDeveloperCLICredential) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	closeCredential(ctx, req, resp, r.tokenCache)
+}
@@ -0,0 +1,272 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralAzureDeveloperCLICredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoRunCommand(t, testNewAzdRunCmdFn(t, []string{"--scope", "ze-scope-1"})),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralAzureDeveloperCLICredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-azd-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("additionally_allowed_tenants"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralAzureDeveloperCLICredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoRunCommand(t, testNewAzdRunCmdFn(t, []string{"--scope", "ze-scope-1", "--tenant-id", "ze-tenant"})),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_azure_developer_cli_credential" "this" {
+	tenant_id                    = "ze-tenant"
+	additionally_allowed_tenants = ["ze-additional-tenant-1", "ze-additional-tenant-2"]
+	scopes                       = ["ze-scope-1"]
+	continue_on_error            = true
+	timeout                      = "1s"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_developer_cli_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-azd-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.StringExact("ze-tenant"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("additionally_allowed_tenants"),
+						knownvalue.SetExact([]knownvalue.Check{
+							knownvalue.StringExact("ze-additional-tenant-1"),
+							knownvalue.StringExact("ze-additional-tenant-2"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.StringExact("1s"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralAzureDeveloperCLICredentialNotLoggedIn(t *testing.T) {
+	runCmdFn := func(ctx context.Context, stdout *bytes.Buffer, stderr *bytes.Buffer, extraEnv []string, name string, arg []string) error {
+		fmt.Fprint(stderr, "ERROR: not logged in, run `azd auth login` to login\n")
+		return fmt.Errorf("exit status 1")
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoRunCommand(t, runCmdFn),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralAzureDeveloperCLICredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`not logged in to the Azure Developer CLI, run 'azd auth login'`),
+			},
+		},
+	})
+}
+
+func TestEphemeralAzureDeveloperCLICredentialFailContinueOnError(t *testing.T) {
+	runCmdFn := func(ctx context.Context, stdout *bytes.Buffer, stderr *bytes.Buffer, extraEnv []string, name string, arg []string) error {
+		return fmt.Errorf("ze-error")
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoRunCommand(t, runCmdFn),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_azure_developer_cli_credential" "this" {
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_developer_cli_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("failed to get token from the Azure Developer CLI: ze-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralAzureDeveloperCLICredentialTimeout(t *testing.T) {
+	runCmdFn := func(ctx context.Context, stdout *bytes.Buffer, stderr *bytes.Buffer, extraEnv []string, name string, arg []string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoRunCommand(t, runCmdFn),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_azure_developer_cli_credential" "this" {
+	scopes  = ["ze-scope-1"]
+	timeout = "10ms"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_developer_cli_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`context deadline exceeded`),
+			},
+		},
+	})
+}
+
+func testEphemeralAzureDeveloperCLICredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_azure_developer_cli_credential" "this" {
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_developer_cli_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+// testNewAzdRunCmdFn returns a fake azd, verifying it is called to get a token
+// with the expected extra arguments.
+func testNewAzdRunCmdFn(t *testing.T, expectedExtraArgs []string) runCommandFn {
+	t.Helper()
+
+	expectedArgs := append([]string{"auth", "token", "--output", "json", "--no-prompt"}, expectedExtraArgs...)
+
+	return func(ctx context.Context, stdout *bytes.Buffer, stderr *bytes.Buffer, extraEnv []string, name string, arg []string) error {
+		if name != "azd" {
+			t.Errorf("expected azd to be run, got %q", name)
+		}
+
+		if !slices.Equal(arg, expectedArgs) {
+			t.Errorf("expected arguments %q, got %q", expectedArgs, arg)
+		}
+
+		fmt.Fprint(stdout, `{"token":"ze-azd-token","expiresOn":"2022-01-02T03:04:05Z"}`)
+		return nil
+	}
+}
@@ -0,0 +1,441 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralAzurePipelinesCredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralAzurePipelinesCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("service_connection_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("system_access_token"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralAzurePipelinesCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_azure_pipelines_credential" "this" {
+	tenant_id                    = "ze-tenant"
+	client_id                    = "ze-client"
+	service_connection_id        = "ze-service-connection"
+	system_access_token          = "ze-system-access-token"
+	additionally_allowed_tenants = ["ze-additional-tenant-1", "ze-additional-tenant-2"]
+	claims                       = "ze-claims"
+	enable_cae                   = true
+	scopes                       = ["ze-scope-1", "ze-scope-2"]
+	continue_on_error            = true
+	timeout                      = "1s"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_pipelines_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.StringExact("ze-tenant"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.StringExact("ze-client"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("service_connection_id"),
+						knownvalue.StringExact("ze-service-connection"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.StringExact("1s"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralAzurePipelinesCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralAzurePipelinesCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralAzurePipelinesCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralAzurePipelinesCredentialConfigContinueOnError(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralAzurePipelinesCredentialOIDC(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		setEnv func(t *testing.T)
+	}{
+		{
+			name: "explicit configuration",
+			config: `
+	tenant_id             = "ze-tenant"
+	client_id             = "ze-client"
+	service_connection_id = "ze-service-connection"
+	system_access_token   = "ze-system-access-token"
+`,
+			setEnv: func(t *testing.T) {},
+		},
+		{
+			name: "environment variables",
+			setEnv: func(t *testing.T) {
+				t.Setenv("AZURESUBSCRIPTION_TENANT_ID", "ze-tenant")
+				t.Setenv("AZURESUBSCRIPTION_CLIENT_ID", "ze-client")
+				t.Setenv("AZURESUBSCRIPTION_SERVICE_CONNECTION_ID", "ze-service-connection")
+				t.Setenv("SYSTEM_ACCESSTOKEN", "ze-system-access-token")
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			oidcToken := testNewJWT(t, time.Now().Add(time.Hour))
+			pipelinesServer := testNewAzurePipelinesOIDCServer(t, oidcToken)
+			entraServer := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/ze-tenant/oauth2/v2.0/token" {
+					t.Errorf("unexpected token path %q", r.URL.Path)
+				}
+
+				if r.PostForm.Get("client_id") != "ze-client" {
+					t.Errorf("unexpected client_id %q", r.PostForm.Get("client_id"))
+				}
+
+				if r.PostForm.Get("client_assertion") != oidcToken {
+					t.Errorf("expected client_assertion to be the Azure Pipelines OIDC token, got %q", r.PostForm.Get("client_assertion"))
+				}
+
+				testEntraTokenResponse(w, "ze-pipelines-token")
+			})
+
+			t.Setenv("SYSTEM_OIDCREQUESTURI", pipelinesServer.URL+"/ze-oidc")
+			c.setEnv(t)
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, entraServer.URL)),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+ephemeral "azidentity_azure_pipelines_credential" "this" {
+	%s
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_pipelines_credential.this
+}
+
+resource "echo" "this" {}
+`, c.config),
+						ConfigStateChecks: []statecheck.StateCheck{
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("access_token"),
+								knownvalue.StringExact("ze-pipelines-token"),
+							),
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("success"),
+								knownvalue.Bool(true),
+							),
+						},
+					},
+				},
+			})
+		})
+	}
+}
+
+func TestEphemeralAzurePipelinesCredentialOIDCFailure(t *testing.T) {
+	pipelinesServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	defer pipelinesServer.Close()
+
+	t.Setenv("SYSTEM_OIDCREQUESTURI", pipelinesServer.URL+"/ze-oidc")
+
+	entraServer := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected token request")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, entraServer.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralAzurePipelinesCredentialConfigContinueOnError(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringRegexp(regexp.MustCompile(`401 Unauthorized response from the OIDC endpoint`)),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralAzurePipelinesCredentialMissingEnvironment(t *testing.T) {
+	cases := []struct {
+		name          string
+		config        string
+		expectedError string
+	}{
+		{
+			name:          "service connection",
+			config:        `system_access_token = "ze-system-access-token"`,
+			expectedError: `AZURESUBSCRIPTION_SERVICE_CONNECTION_ID`,
+		},
+		{
+			name:          "system access token",
+			config:        `service_connection_id = "ze-service-connection"`,
+			expectedError: `SYSTEM_ACCESSTOKEN`,
+		},
+		{
+			name: "OIDC request URI",
+			config: `
+	service_connection_id = "ze-service-connection"
+	system_access_token   = "ze-system-access-token"
+`,
+			expectedError: `SYSTEM_OIDCREQUESTURI`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("AZURESUBSCRIPTION_SERVICE_CONNECTION_ID", "")
+			t.Setenv("SYSTEM_ACCESSTOKEN", "")
+			t.Setenv("SYSTEM_OIDCREQUESTURI", "")
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+ephemeral "azidentity_azure_pipelines_credential" "this" {
+	tenant_id = "ze-tenant"
+	client_id = "ze-client"
+	%s
+	scopes    = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_pipelines_credential.this
+}
+
+resource "echo" "this" {}
+`, c.config),
+						ExpectError: regexp.MustCompile(c.expectedError),
+					},
+				},
+			})
+		})
+	}
+}
+
+func testEphemeralAzurePipelinesCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_azure_pipelines_credential" "this" {
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_pipelines_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+func testEphemeralAzurePipelinesCredentialConfigContinueOnError() string {
+	return `
+ephemeral "azidentity_azure_pipelines_credential" "this" {
+	tenant_id             = "ze-tenant"
+	client_id             = "ze-client"
+	service_connection_id = "ze-service-connection"
+	system_access_token   = "ze-system-access-token"
+	scopes                = ["ze-scope-1"]
+	continue_on_error     = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_azure_pipelines_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+// testNewAzurePipelinesOIDCServer starts a stand-in for the Azure Pipelines
+// OIDC endpoint, returning oidcToken for the expected service connection.
+func testNewAzurePipelinesOIDCServer(t *testing.T, oidcToken string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method %q", r.Method)
+		}
+
+		if r.URL.Path != "/ze-oidc" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+
+		if r.URL.Query().Get("serviceConnectionId") != "ze-service-connection" {
+			t.Errorf("unexpected serviceConnectionId %q", r.URL.Query().Get("serviceConnectionId"))
+		}
+
+		if r.Header.Get("Authorization") != "Bearer ze-system-access-token" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"oidcToken":"%s"}`, oidcToken)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
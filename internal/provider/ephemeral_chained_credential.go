@@ -0,0 +1,521 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+var _ ephemeral.EphemeralResource = &ephemeralChainedCredential{}
+var _ ephemeral.EphemeralResourceWithValidateConfig = &ephemeralChainedCredential{}
+var _ ephemeral.EphemeralResourceWithRenew = &ephemeralChainedCredential{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralChainedCredential{}
+
+func newEphemeralChainedCredential() ephemeral.EphemeralResource {
+	return &ephemeralChainedCredential{}
+}
+
+// credentialConfigModel is implemented by the models of the credential
+// resources usable as a source of azidentity_chained_credential.
+type credentialConfigModel interface {
+	newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error)
+}
+
+type chainedCredentialSourceType struct {
+	name        string
+	credType    credentialType
+	newResource func() ephemeral.EphemeralResource
+	newModel    func() credentialConfigModel
+}
+
+// chainedCredentialSourceTypes are the credentials usable as a source of
+// azidentity_chained_credential. Each source reuses the schema and model of
+// the azidentity_<name>_credential resource, except for the attributes in
+// chainedCredentialSharedAttributes.
+var chainedCredentialSourceTypes = []chainedCredentialSourceType{
+	{
+		name:        "azure_cli",
+		credType:    azureCLICredential,
+		newResource: newEphemeralAzureCLICredential,
+		newModel:    func() credentialConfigModel { return &ephemeralAzureCLICredentialModel{} },
+	},
+	{
+		name:        "azure_developer_cli",
+		credType:    azureDeveloperCLICredential,
+		newResource: newEphemeralAzureDeveloperCLICredential,
+		newModel:    func() credentialConfigModel { return &ephemeralAzureDeveloperCLICredentialModel{} },
+	},
+	{
+		name:        "azure_pipelines",
+		credType:    azurePipelinesCredential,
+		newResource: newEphemeralAzurePipelinesCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralAzurePipelinesCredentialModel{} },
+	},
+	{
+		name:        "client_assertion",
+		credType:    clientAssertionCredential,
+		newResource: newEphemeralClientAssertionCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralClientAssertionCredentialModel{} },
+	},
+	{
+		name:        "client_certificate",
+		credType:    clientCertificateCredential,
+		newResource: newEphemeralClientCertificateCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralClientCertificateCredentialModel{} },
+	},
+	{
+		name:        "client_secret",
+		credType:    clientSecretCredential,
+		newResource: newEphemeralClientSecretCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralClientSecretCredentialModel{} },
+	},
+	{
+		name:        "environment",
+		credType:    environmentCredential,
+		newResource: newEphemeralEnvironmentCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralEnvironmentCredentialModel{} },
+	},
+	{
+		name:        "github_actions",
+		credType:    gitHubActionsCredential,
+		newResource: newEphemeralGitHubActionsCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralGitHubActionsCredentialModel{} },
+	},
+	{
+		name:        "managed_identity",
+		credType:    managedIdentityCredential,
+		newResource: newEphemeralManagedIdentityCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralManagedIdentityCredentialModel{} },
+	},
+	{
+		name:        "on_behalf_of",
+		credType:    onBehalfOfCredential,
+		newResource: newEphemeralOnBehalfOfCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralOnBehalfOfCredentialModel{} },
+	},
+	{
+		name:        "username_password",
+		credType:    usernamePasswordCredential,
+		newResource: newEphemeralUsernamePasswordCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralUsernamePasswordCredentialModel{} },
+	},
+	{
+		name:        "workload_identity",
+		credType:    workloadIdentityCredential,
+		newResource: newEphemeralWorkloadIdentityCredential,
+		newModel:    func() credentialConfigModel { return &ephemeralWorkloadIdentityCredentialModel{} },
+	},
+}
+
+// chainedCredentialSharedAttributes are configured once for the whole chain,
+// or are results, and are left out of the source schemas.
+var chainedCredentialSharedAttributes = map[string]bool{
+	"claims":             true,
+	"enable_cae":         true,
+	"scopes":             true,
+	"continue_on_error":  true,
+	"timeout":            true,
+	"max_retries":        true,
+	"retry_delay":        true,
+	"max_retry_delay":    true,
+	"retry_status_codes": true,
+	"access_token":       true,
+	"expires_on":         true,
+	"cache_hit":          true,
+	"refresh_on":         true,
+	"token_type":         true,
+	"expires_in_seconds": true,
+	"token_tenant_id":    true,
+	"token_object_id":    true,
+	"token_app_id":       true,
+	"token_audience":     true,
+	"mode":               true,
+	"success":            true,
+	"error":              true,
+}
+
+type ephemeralChainedCredential struct {
+	getCredFn  getCredentialFn
+	tokenCache *tokenCache
+	defaults   providerDefaults
+	httpClient *http.Client
+	runCmdFn   runCommandFn
+}
+
+type ephemeralChainedCredentialModel struct {
+	Sources          types.List   `tfsdk:"sources"`
+	Claims           types.String `tfsdk:"claims"`
+	EnableCAE        types.Bool   `tfsdk:"enable_cae"`
+	Scopes           types.Set    `tfsdk:"scopes"`
+	ContinueOnError  types.Bool   `tfsdk:"continue_on_error"`
+	Timeout          types.String `tfsdk:"timeout"`
+	MaxRetries       types.Int64  `tfsdk:"max_retries"`
+	RetryDelay       types.String `tfsdk:"retry_delay"`
+	MaxRetryDelay    types.String `tfsdk:"max_retry_delay"`
+	RetryStatusCodes types.Set    `tfsdk:"retry_status_codes"`
+	tokenModel
+	SuccessfulSource      types.String `tfsdk:"successful_source"`
+	SuccessfulSourceIndex types.Int64  `tfsdk:"successful_source_index"`
+	CacheHit              types.Bool   `tfsdk:"cache_hit"`
+	Success               types.Bool   `tfsdk:"success"`
+	Error                 types.String `tfsdk:"error"`
+}
+
+func (r *ephemeralChainedCredentialModel) newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error) {
+	retry, err := defaults.retryConfig(ctx, r.MaxRetries, r.RetryDelay, r.MaxRetryDelay, r.RetryStatusCodes)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
+	return credentialConfig{
+		Claims:          r.Claims.ValueString(),
+		EnableCAE:       r.EnableCAE.ValueBool(),
+		Scopes:          typesSetToStringSlice(r.Scopes),
+		ContinueOnError: r.ContinueOnError.ValueBool(),
+		Timeout:         parseTimeout(ctx, defaults.timeout(ctx, r.Timeout)),
+		Retry:           retry,
+	}, nil
+}
+
+func (r *ephemeralChainedCredential) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_chained_credential"
+}
+
+func (r *ephemeralChainedCredential) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "The `azidentity_chained_credential` resource tries an ordered list of credentials, returning the token of the first one to acquire it. Sources whose credential can't be created, for example because required environment variables are missing, are skipped. As with the Azure SDK `ChainedTokenCredential`, the chain continues past sources that are unavailable, such as a managed identity outside of Azure, but stops at the first source failing to authenticate, such as a source with an invalid secret.",
+		Attributes: withTokenSchemaAttributes(map[string]schema.Attribute{
+			"sources": schema.ListNestedAttribute{
+				MarkdownDescription: "Sources is the ordered list of credentials to try. Each source configures exactly one credential.",
+				Required:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: credentialSourceAttributes(ctx),
+				},
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"claims": schema.StringAttribute{
+				MarkdownDescription: "Claims are any additional claims required for the token to satisfy a conditional access policy, such as a service may return in a claims challenge following an authorization failure. If a service returned the claims value base64 encoded, it must be decoded before setting this field. The default is an empty string.",
+				Optional:            true,
+			},
+			"enable_cae": schema.BoolAttribute{
+				MarkdownDescription: "EnableCAE indicates whether to enable Continuous Access Evaluation (CAE) for the requested token. When true, azidentity credentials request CAE tokens for resource APIs supporting CAE. Clients are responsible for handling CAE challenges. If a client that doesn't handle CAE challenges receives a CAE token, it may end up in a loop retrying an API call with a token that has been revoked due to CAE. The default is false.",
+				Optional:            true,
+			},
+			"scopes": schema.SetAttribute{
+				MarkdownDescription: "Scopes contains the list of permission scopes required for the token. E.g. https://management.azure.com/.default for Azure Resource Manager or https://graph.microsoft.com/.default for Microsoft Graph.",
+				Required:            true,
+				ElementType:         types.StringType,
+			},
+			"continue_on_error": schema.BoolAttribute{
+				MarkdownDescription: "ContinueOnError indicates whether to continue on error when acquiring a token. The default is false.",
+				Optional:            true,
+			},
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is the provider `timeout`, or 30 seconds ('30s').",
+				Optional:            true,
+			},
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "MaxRetries is the maximum number of times a failed HTTP request of the credential is retried, 0 disables retries. Retries are bounded by the timeout. The default is the provider `max_retries`, or 3.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+				},
+			},
+			"retry_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryDelay is the initial delay before retrying a failed HTTP request, increasing exponentially with each retry up to MaxRetryDelay. It's only used when the response doesn't contain a Retry-After header. The default is the provider `retry_delay`, or '800ms'.",
+				Optional:            true,
+			},
+			"max_retry_delay": schema.StringAttribute{
+				MarkdownDescription: "MaxRetryDelay is the maximum delay before retrying a failed HTTP request. The default is the provider `max_retry_delay`, or '60s'.",
+				Optional:            true,
+			},
+			"retry_status_codes": schema.SetAttribute{
+				MarkdownDescription: "RetryStatusCodes are the HTTP status codes retried, an empty set only retries on network errors. The default is the provider `retry_status_codes`, or 408, 429, 500, 502, 503 and 504.",
+				Optional:            true,
+				ElementType:         types.Int64Type,
+				Validators: []validator.Set{
+					setvalidator.ValueInt64sAre(int64validator.Between(100, 599)),
+				},
+			},
+			"successful_source": schema.StringAttribute{
+				MarkdownDescription: "The type of the source that acquired the token, e.g. `azure_cli`.",
+				Computed:            true,
+			},
+			"successful_source_index": schema.Int64Attribute{
+				MarkdownDescription: "The index in `sources` of the source that acquired the token.",
+				Computed:            true,
+			},
+			"cache_hit": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if the access token was reused from the token cache of the provider instead of being requested. The cache holds the token of the whole chain, so a cached token is reused as long as the sources are configured the same.",
+				Computed:            true,
+			},
+			"success": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if a token was successfully acquired.",
+				Computed:            true,
+			},
+			"error": schema.StringAttribute{
+				MarkdownDescription: "Error message if acquiring a token failed, listing each source tried and why it failed.",
+				Computed:            true,
+			},
+		}),
+	}
+}
+
+func (r *ephemeralChainedCredential) ValidateConfig(ctx context.Context, req ephemeral.ValidateConfigRequest, resp *ephemeral.ValidateConfigResponse) {
+	var sources types.List
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("sources"), &sources)...)
+	if resp.Diagnostics.HasError() || sources.IsNull() || sources.IsUnknown() {
+		return
+	}
+
+	for i, element := range sources.Elements() {
+		source, ok := element.(types.Object)
+		if !ok || source.IsNull() || source.IsUnknown() {
+			continue
+		}
+
+		configured, known := configuredCredentialSources(source)
+		if !known {
+			return
+		}
+
+		if configured != 1 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("sources").AtListIndex(i),
+				"Invalid Credential Source",
+				fmt.Sprintf("Each source has to configure exactly one credential, got %d.", configured),
+			)
+		}
+	}
+}
+
+func (p *ephemeralChainedCredential) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*azidentityProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected ProviderData Type",
+			fmt.Sprintf("Expected *azidentityProvider, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+
+	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.defaults = provider.defaults
+	p.httpClient = provider.httpClient
+	p.runCmdFn = provider.runCmdFn
+}
+
+func (r *ephemeralChainedCredential) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data ephemeralChainedCredentialModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	links := []*chainedCredentialLink{}
+	for _, element := range data.Sources.Elements() {
+		source, ok := element.(types.Object)
+		if !ok {
+			continue
+		}
+
+		for _, sourceType := range chainedCredentialSourceTypes {
+			value, ok := source.Attributes()[sourceType.name].(types.Object)
+			if !ok || value.IsNull() {
+				continue
+			}
+
+			cfg, diags := newChainedCredentialSourceConfig(ctx, sourceType, value, r.defaults)
+			resp.Diagnostics.Append(diags...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+
+			if sourceType.credType == usernamePasswordCredential {
+				addUsernamePasswordWarning(&resp.Diagnostics, "The username_password source of azidentity_chained_credential")
+			}
+
+			cfg.HTTPClient = r.httpClient
+			cfg.RunCmdFn = r.runCmdFn
+			links = append(links, &chainedCredentialLink{
+				name:     sourceType.name,
+				credType: sourceType.credType,
+				cfg:      cfg,
+			})
+		}
+	}
+
+	cfg, err := data.newCredentialConfig(ctx, r.defaults)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Credential Configuration", err.Error())
+		return
+	}
+
+	token, index, cacheHit, errSummary, err := getCachedChainedToken(ctx, r.tokenCache, r.getCredFn, links, cfg)
+	if err != nil && cfg.ContinueOnError {
+		data.Error = types.StringValue(err.Error())
+		data.Success = types.BoolValue(false)
+		resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError(errSummary, err.Error())
+		return
+	}
+
+	data.setToken(token)
+	data.SuccessfulSource = types.StringValue(links[index].name)
+	data.SuccessfulSourceIndex = types.Int64Value(int64(index))
+	data.CacheHit = types.BoolValue(cacheHit)
+	data.Success = types.BoolValue(true)
+
+	// Renewing tries the whole chain again, as the cached token is the token of
+	// the chain.
+	key, err := newChainedTokenCacheKey(links, cfg)
+	if err != nil {
+		resp.Diagnostics.AddError("Error Storing Renew State", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(setRenewState(ctx, resp, r.tokenCache, credentialRenewState{
+		CacheKey:        key,
+		ContinueOnError: cfg.ContinueOnError,
+		RenewMargin:     r.defaults.RenewMargin,
+	}, token)...)
+
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *ephemeralChainedCredential) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	renewCredential(ctx, req, resp, r.tokenCache)
+}
+
+func (r *ephemeralChainedCredential) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	closeCredential(ctx, req, resp, r.tokenCache)
+}
+
+// credentialSourceAttributes returns an optional attribute for each of the
+// chainedCredentialSourceTypes, of which exactly one is to be configured.
+func credentialSourceAttributes(ctx context.Context) map[string]schema.Attribute {
+	sourceAttributes := map[string]schema.Attribute{}
+	for _, sourceType := range chainedCredentialSourceTypes {
+		sourceSchema := chainedCredentialSourceSchema(ctx, sourceType)
+		attributes := map[string]schema.Attribute{}
+		for name, attribute := range sourceSchema.Attributes {
+			if chainedCredentialSharedAttributes[name] {
+				continue
+			}
+			attributes[name] = attribute
+		}
+
+		sourceAttributes[sourceType.name] = schema.SingleNestedAttribute{
+			MarkdownDescription: fmt.Sprintf("Authenticates using the arguments of the `azidentity_%s_credential` resource.", sourceType.name),
+			Optional:            true,
+			Attributes:          attributes,
+		}
+	}
+
+	return sourceAttributes
+}
+
+// configuredCredentialSources returns how many credentials of
+// credentialSourceAttributes an object configures, and false when that isn't
+// known yet.
+func configuredCredentialSources(source types.Object) (int, bool) {
+	configured := 0
+	for _, sourceType := range chainedCredentialSourceTypes {
+		value, ok := source.Attributes()[sourceType.name]
+		if !ok {
+			continue
+		}
+
+		if value.IsUnknown() {
+			return 0, false
+		}
+
+		if !value.IsNull() {
+			configured++
+		}
+	}
+
+	return configured, true
+}
+
+func chainedCredentialSourceSchema(ctx context.Context, sourceType chainedCredentialSourceType) schema.Schema {
+	var resp ephemeral.SchemaResponse
+	sourceType.newResource().Schema(ctx, ephemeral.SchemaRequest{}, &resp)
+
+	return resp.Schema
+}
+
+// newChainedCredentialSourceConfig decodes a source into the model of its
+// credential resource, leaving the shared attributes null, and returns the
+// credential config built by that model on top of the provider defaults.
+func newChainedCredentialSourceConfig(ctx context.Context, sourceType chainedCredentialSourceType, source types.Object, defaults providerDefaults) (credentialConfig, diag.Diagnostics) {
+	sourceSchema := chainedCredentialSourceSchema(ctx, sourceType)
+	var diags diag.Diagnostics
+	objectType, ok := sourceSchema.Type().(basetypes.ObjectType)
+	if !ok {
+		diags.AddError("Unexpected Schema Type", fmt.Sprintf("Expected basetypes.ObjectType for the %s source, got: %T. Please report this issue to the provider developers.", sourceType.name, sourceSchema.Type()))
+		return credentialConfig{}, diags
+	}
+
+	values := map[string]attr.Value{}
+	for name, attrType := range objectType.AttrTypes {
+		value, ok := source.Attributes()[name]
+		if ok {
+			values[name] = value
+			continue
+		}
+
+		nullValue, err := attrType.ValueFromTerraform(ctx, tftypes.NewValue(attrType.TerraformType(ctx), nil))
+		if err != nil {
+			diags.AddError("Failed to create null value", err.Error())
+			return credentialConfig{}, diags
+		}
+		values[name] = nullValue
+	}
+
+	object, objectDiags := types.ObjectValue(objectType.AttrTypes, values)
+	diags.Append(objectDiags...)
+	if diags.HasError() {
+		return credentialConfig{}, diags
+	}
+
+	model := sourceType.newModel()
+	diags.Append(object.As(ctx, model, basetypes.ObjectAsOptions{})...)
+	if diags.HasError() {
+		return credentialConfig{}, diags
+	}
+
+	cfg, err := model.newCredentialConfig(ctx, defaults)
+	if err != nil {
+		diags.AddError("Invalid Credential Configuration", fmt.Sprintf("Source %s: %s", sourceType.name, err))
+		return credentialConfig{}, diags
+	}
+
+	return cfg, diags
+}
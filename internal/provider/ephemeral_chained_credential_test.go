@@ -0,0 +1,438 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralChainedCredential(t *testing.T) {
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		switch credType {
+		case workloadIdentityCredential:
+			return nil, fmt.Errorf("ze-workload-identity-error")
+		case clientSecretCredential:
+			if cfg.TenantID != "ze-tenant" || cfg.ClientID != "ze-client" || cfg.ClientSecret != "ze-secret" {
+				t.Errorf("unexpected client secret credential config: %+v", cfg)
+			}
+
+			return &testCredential{t: t}, nil
+		default:
+			return &testCredentialFailure{t: t}, nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, getCredFn),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_chained_credential" "this" {
+	sources = [
+		{
+			workload_identity = {}
+		},
+		{
+			client_secret = {
+				tenant_id     = "ze-tenant"
+				client_id     = "ze-client"
+				client_secret = "ze-secret"
+			}
+		},
+		{
+			azure_cli = {}
+		},
+	]
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_chained_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("successful_source"),
+						knownvalue.StringExact("client_secret"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("successful_source_index"),
+						knownvalue.Int64Exact(1),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("cache_hit"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralChainedCredentialStopsAtFailure(t *testing.T) {
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		switch credType {
+		case workloadIdentityCredential:
+			return nil, fmt.Errorf("ze-workload-identity-error")
+		case clientSecretCredential:
+			return &testCredentialFailure{t: t}, nil
+		default:
+			return &testCredential{t: t}, nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, getCredFn),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_chained_credential" "this" {
+	sources = [
+		{
+			workload_identity = {}
+		},
+		{
+			client_secret = {
+				tenant_id     = "ze-tenant"
+				client_id     = "ze-client"
+				client_secret = "ze-secret"
+			}
+		},
+		{
+			azure_cli = {}
+		},
+	]
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_chained_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("successful_source"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("no source in the credential chain acquired a token, attempted sources:\n\tsources[0].workload_identity: failed to create credential: ze-workload-identity-error\n\tsources[1].client_secret: ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralChainedCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_chained_credential" "this" {
+	sources = [
+		{
+			managed_identity = {}
+		},
+		{
+			azure_cli = {}
+		},
+	]
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_chained_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`sources\[1\]\.azure_cli: failed to create credential:\s+ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralChainedCredentialInvalidSource(t *testing.T) {
+	cases := []struct {
+		name          string
+		source        string
+		expectedError string
+	}{
+		{
+			name:          "no credential",
+			source:        `{}`,
+			expectedError: `Each source has to configure exactly one credential, got 0`,
+		},
+		{
+			name: "multiple credentials",
+			source: `{
+			azure_cli        = {}
+			managed_identity = {}
+		}`,
+			expectedError: `Each source has to configure exactly one credential, got 2`,
+		},
+		{
+			name: "conflicting managed identity IDs",
+			source: `{
+			managed_identity = {
+				client_id = "ze-client"
+				object_id = "ze-object"
+			}
+		}`,
+			expectedError: `Invalid Attribute Combination`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+ephemeral "azidentity_chained_credential" "this" {
+	sources = [
+		%s,
+	]
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_chained_credential.this
+}
+
+resource "echo" "this" {}
+`, c.source),
+						ExpectError: regexp.MustCompile(c.expectedError),
+					},
+				},
+			})
+		})
+	}
+}
+
+func TestEphemeralChainedCredentialSkipsUnavailableManagedIdentity(t *testing.T) {
+	imdsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/identity/oauth2/token" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_request","error_description":"Identity not found"}`)) // nolint:errcheck
+	}))
+
+	defer imdsServer.Close()
+
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFilePath, []byte("ze-assertion"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+
+	entraServer := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.PostForm.Get("client_assertion") != "ze-assertion" {
+			t.Errorf("unexpected client_assertion %q", r.PostForm.Get("client_assertion"))
+		}
+
+		testEntraTokenResponse(w, "ze-chained-token")
+	})
+
+	imdsTransport := testNewRedirectHttpClient(t, imdsServer.URL).Transport
+	entraTransport := testNewRedirectHttpClient(t, entraServer.URL).Transport
+	httpClient := &http.Client{
+		Transport: testRoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Hostname() == "169.254.169.254" {
+				return imdsTransport.RoundTrip(req)
+			}
+
+			return entraTransport.RoundTrip(req)
+		}),
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), httpClient),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_chained_credential" "this" {
+	sources = [
+		{
+			managed_identity = {}
+		},
+		{
+			workload_identity = {
+				tenant_id       = "ze-tenant"
+				client_id       = "ze-client"
+				token_file_path = %q
+			}
+		},
+	]
+	scopes = ["https://ze-resource.example.com/.default"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_chained_credential.this
+}
+
+resource "echo" "this" {}
+`, tokenFilePath),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-chained-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("successful_source"),
+						knownvalue.StringExact("workload_identity"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("successful_source_index"),
+						knownvalue.Int64Exact(1),
+					),
+				},
+			},
+		},
+	})
+}
+
+type testRoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f testRoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestEphemeralChainedCredentialUsernamePassword(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		if credType != usernamePasswordCredential {
+			return nil, fmt.Errorf("unexpected credential type %s", credType)
+		}
+
+		if cfg.TenantID != "ze-tenant" || cfg.ClientID != "ze-client" || cfg.Username != "ze-user@example.com" || cfg.Password != "ze-password" {
+			t.Errorf("unexpected username password credential config: %s, %s, %s", cfg.TenantID, cfg.ClientID, cfg.Username)
+		}
+
+		return &testCredential{t: t}, nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, getCredFn),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_chained_credential" "this" {
+	sources = [
+		{
+			username_password = {
+				tenant_id = "ze-tenant"
+				client_id = "ze-client"
+				username  = "ze-user@example.com"
+				password  = "ze-password"
+			}
+		},
+	]
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_chained_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("successful_source"),
+						knownvalue.StringExact("username_password"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ ephemeral.EphemeralResource = &ephemeralClientCertificateCredential{}
+var _ ephemeral.EphemeralResourceWithRenew = &ephemeralClientCertificateCredential{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralClientCertificateCredential{}
+
+func newEphemeralClientCertificateCredential() ephemeral.EphemeralResource {
+	return &ephemeralClientCertificateCredential{}
+}
+
+type ephemeralClientCertificateCredential struct {
+	getCredFn  getCredentialFn
+	defaults   providerDefaults
+	httpClient *http.Client
+	tokenCache *tokenCache
+}
+
+type ephemeralClientCertificateCredentialModel struct {
+	Cloud                      types.String `tfsdk:"cloud"`
+	TenantID                   types.String `tfsdk:"tenant_id"`
+	ClientID                   types.String `tfsdk:"client_id"`
+	Certificate                types.String `tfsdk:"certificate"`
+	CertificatePassword        types.String `tfsdk:"certificate_password"`
+	SendCertificateChain       types.Bool   `tfsdk:"send_certificate_chain"`
+	AdditionallyAllowedTenants types.Set    `tfsdk:"additionally_allowed_tenants"`
+	DisableInstanceDiscovery   types.Bool   `tfsdk:"disable_instance_discovery"`
+	Claims                     types.String `tfsdk:"claims"`
+	EnableCAE                  types.Bool   `tfsdk:"enable_cae"`
+	Scopes                     types.Set    `tfsdk:"scopes"`
+	ContinueOnError            types.Bool   `tfsdk:"continue_on_error"`
+	Timeout                    types.String `tfsdk:"timeout"`
+	MaxRetries                 types.Int64  `tfsdk:"max_retries"`
+	RetryDelay                 types.String `tfsdk:"retry_delay"`
+	MaxRetryDelay              types.String `tfsdk:"max_retry_delay"`
+	RetryStatusCodes           types.Set    `tfsdk:"retry_status_codes"`
+	tokenModel
+	CacheHit types.Bool   `tfsdk:"cache_hit"`
+	Success  types.Bool   `tfsdk:"success"`
+	Error    types.String `tfsdk:"error"`
+}
+
+func (r *ephemeralClientCertificateCredentialModel) newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error) {
+	cloudConfig, err := defaults.cloudConfig(ctx, r.Cloud, r.Timeout)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
+	retry, err := defaults.retryConfig(ctx, r.MaxRetries, r.RetryDelay, r.MaxRetryDelay, r.RetryStatusCodes)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
+	return credentialConfig{
+		CloudConfig:                cloudConfig,
+		TenantID:                   defaults.tenantID(ctx, r.TenantID).ValueString(),
+		ClientID:                   r.ClientID.ValueString(),
+		Certificate:                r.Certificate.ValueString(),
+		CertificatePassword:        r.CertificatePassword.ValueString(),
+		SendCertificateChain:       r.SendCertificateChain.ValueBool(),
+		AdditionallyAllowedTenants: typesSetToStringSlice(defaults.additionallyAllowedTenants(ctx, r.AdditionallyAllowedTenants)),
+		DisableInstanceDiscovery:   defaults.disableInstanceDiscovery(ctx, r.DisableInstanceDiscovery).ValueBool(),
+		Claims:                     r.Claims.ValueString(),
+		EnableCAE:                  r.EnableCAE.ValueBool(),
+		Scopes:                     typesSetToStringSlice(r.Scopes),
+		ContinueOnError:            r.ContinueOnError.ValueBool(),
+		Timeout:                    parseTimeout(ctx, defaults.timeout(ctx, r.Timeout)),
+		Retry:                      retry,
+	}, nil
+}
+
+func (r *ephemeralClientCertificateCredential) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_client_certificate_credential"
+}
+
+func (r *ephemeralClientCertificateCredential) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "The `azidentity_client_certificate_credential` resource enables authentication via an Azure **Client ID** and a **Client Certificate**. It is intended for service principals that are only allowed to authenticate with certificates, and supports both PEM and PKCS#12 certificates.",
+		Attributes: withTokenSchemaAttributes(map[string]schema.Attribute{
+			"tenant_id": schema.StringAttribute{
+				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure CLI and workload identity. The default is the provider `tenant_id`, one of them is required. Use 'organizations' or 'common' if you can't provide one but required to use one.",
+				Optional:            true,
+			},
+			"client_id": schema.StringAttribute{
+				MarkdownDescription: "ClientID is the application ID of the client.",
+				Required:            true,
+			},
+			"certificate": schema.StringAttribute{
+				MarkdownDescription: "Certificate contains the certificate and its RSA private key, either PEM encoded or as a base64 encoded PKCS#12 (.pfx) archive. Encrypted PEM private keys aren't supported.",
+				Required:            true,
+				Sensitive:           true,
+			},
+			"certificate_password": schema.StringAttribute{
+				MarkdownDescription: "CertificatePassword is the password protecting the PKCS#12 archive. The default is empty.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"send_certificate_chain": schema.BoolAttribute{
+				MarkdownDescription: "SendCertificateChain controls whether the credential sends the public certificate chain in the x5c header of each token request's JWT. This is required for Subject Name/Issuer (SNI) authentication. The default is false.",
+				Optional:            true,
+			},
+			"cloud": schema.StringAttribute{
+				MarkdownDescription: "Cloud specifies a cloud for the client, either AzurePublic, AzureChina, AzureGovernment or the https URL of an ARM metadata endpoint, such as https://management.local.azurestack.external/metadata/endpoints. The default is the provider `cloud`, or AzurePublic.",
+				Optional:            true,
+				Validators: []validator.String{
+					newCloudValidator(),
+				},
+			},
+			"additionally_allowed_tenants": schema.SetAttribute{
+				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. This value can also be set as a semicolon delimited list of tenants in the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS. The default is the provider `additionally_allowed_tenants`, or an empty list.",
+				Optional:            true,
+				ElementType:         types.StringType,
+			},
+			"disable_instance_discovery": schema.BoolAttribute{
+				MarkdownDescription: "DisableInstanceDiscovery should be set true only by applications authenticating in disconnected clouds, or private clouds such as Azure Stack. It determines whether the credential requests Microsoft Entra instance metadata from https://login.microsoft.com before authenticating. Setting this to true will skip this request, making the application responsible for ensuring the configured authority is valid and trustworthy. The default is the provider `disable_instance_discovery`, or false.",
+				Optional:            true,
+			},
+			"claims": schema.StringAttribute{
+				MarkdownDescription: "Claims are any additional claims required for the token to satisfy a conditional access policy, such as a service may return in a claims challenge following an authorization failure. If a service returned the claims value base64 encoded, it must be decoded before setting this field. The default is an empty string.",
+				Optional:            true,
+			},
+			"enable_cae": schema.BoolAttribute{
+				MarkdownDescription: "EnableCAE indicates whether to enable Continuous Access Evaluation (CAE) for the requested token. When true, azidentity credentials request CAE tokens for resource APIs supporting CAE. Clients are responsible for handling CAE challenges. If a client that doesn't handle CAE challenges receives a CAE token, it may end up in a loop retrying an API call with a token that has been revoked due to CAE. The default is false.",
+				Optional:            true,
+			},
+			"scopes": schema.SetAttribute{
+				MarkdownDescription: "Scopes contains the list of permission scopes required for the token. E.g. https://management.azure.com/.default for Azure Resource Manager or https://graph.microsoft.com/.default for Microsoft Graph.",
+				Required:            true,
+				ElementType:         types.StringType,
+			},
+			"continue_on_error": schema.BoolAttribute{
+				MarkdownDescription: "ContinueOnError indicates whether to continue on error when acquiring a token. The default is false.",
+				Optional:            true,
+			},
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is the provider `timeout`, or 30 seconds ('30s').",
+				Optional:            true,
+			},
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "MaxRetries is the maximum number of times a failed HTTP request of the credential is retried, 0 disables retries. Retries are bounded by the timeout. The default is the provider `max_retries`, or 3.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+				},
+			},
+			"retry_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryDelay is the initial delay before retrying a failed HTTP request, increasing exponentially with each retry up to MaxRetryDelay. It's only used when the response doesn't contain a Retry-After header. The default is the provider `retry_delay`, or '800ms'.",
+				Optional:            true,
+			},
+			"max_retry_delay": schema.StringAttribute{
+				MarkdownDescription: "MaxRetryDelay is the maximum delay before retrying a failed HTTP request. The default is the provider `max_retry_delay`, or '60s'.",
+				Optional:            true,
+			},
+			"retry_status_codes": schema.SetAttribute{
+				MarkdownDescription: "RetryStatusCodes are the HTTP status codes retried, an empty set only retries on network errors. The default is the provider `retry_status_codes`, or 408, 429, 500, 502, 503 and 504.",
+				Optional:            true,
+				ElementType:         types.Int64Type,
+				Validators: []validator.Set{
+					setvalidator.ValueInt64sAre(int64validator.Between(100, 599)),
+				},
+			},
+			"cache_hit": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if the access token was reused from the token cache of the provider instead of being requested.",
+				Computed:            true,
+			},
+			"success": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if a token was successfully acquired.",
+				Computed:            true,
+			},
+			"error": schema.StringAttribute{
+				MarkdownDescription: "Error message if acquiring a token failed.",
+				Computed:            true,
+			},
+		}),
+	}
+}
+
+func (p *ephemeralClientCertificateCredential) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*azidentityProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected ProviderData Type",
+			fmt.Sprintf("Expected *azidentityProvider, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+
+	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.defaults = provider.defaults
+	p.httpClient = provider.httpClient
+}
+
+func (r *ephemeralClientCertificateCredential) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data ephemeralClientCertificateCredentialModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	cfg, err := data.newCredentialConfig(ctx, r.defaults)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Credential Configuration", err.Error())
+		return
+	}
+
+	cfg.HTTPClient = r.httpClient
+	token, cacheHit, errSummary, err := getCachedToken(ctx, r.tokenCache, clientCertificateCredential, r.getCredFn, cfg)
+	if err != nil && cfg.ContinueOnError {
+		data.Error = types.StringValue(err.Error())
+		data.Success = types.BoolValue(false)
+		resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError(errSummary, err.Error())
+		return
+	}
+
+	data.setToken(token)
+	data.CacheHit = types.BoolValue(cacheHit)
+	data.Success = types.BoolValue(true)
+
+	resp.Diagnostics.Append(setCredentialRenewState(ctx, resp, r.tokenCache, clientCertificateCredential, cfg, r.defaults.RenewMargin, token)...)
+
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *ephemeralClientCertificateCredential) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	renewCredential(ctx, req, resp, r.tokenCache)
+}
+
+func (r *ephemeralClientCertificateCredential) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	closeCredential(ctx, req, resp, r.tokenCache)
+}
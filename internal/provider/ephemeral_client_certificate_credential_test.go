@@ -0,0 +1,544 @@
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+// testCertificatePKCS12 is a self-signed certificate for CN=ze-certificate
+// with an RSA key, protected with the password "ze-password". It was created
+// using openssl with legacy (SHA1/3DES) encryption, as that is what the
+// PKCS#12 decoder used by the SDK supports.
+const testCertificatePKCS12 = `
+MIIJWQIBAzCCCR8GCSqGSIb3DQEHAaCCCRAEggkMMIIJCDCCA78GCSqGSIb3DQEHBqCCA7AwggOs
+AgEAMIIDpQYJKoZIhvcNAQcBMBwGCiqGSIb3DQEMAQMwDgQIYcX2hD7xsmMCAggAgIIDeNGimfHR
+E2sVEz85+cyOvPg3QSMGHF8UtqIhhoCtcKx2TQWUwE3UILEx2SmZAe5RfCeDNukaBflopzK0m82O
+YJE+bVfdsKjQ5ZI5YGdT8Z683esrwkpV2mbRc1TV38+yMqpWv8t8YKN8I6Jqldt5QQ7CCN5h9pVD
+idse+T5DlyB4Ug4QfRsvTnYib3248bClUiQuw3RQ6fijV9+db2tURUDlCEI3OFJVQbhBxaTKwK3g
+dB9Ps6ihZDhfh4dTtshZPp5hOfjgjuWbJXcZzBug9fJRz4aohW68IZ5yc4Aw1r2eCaTK69UUa42r
+WXv2cRPYhidpoYChPXFPr1370FvoVwWBdwVKGNIdU4SB84oWeP9vcXUIqoTeGa76RQY8BvtIGimA
+lVbPWyWFmbRJENxXX9j7fE+7XG6gu1b47RaXoONAV1WemFa75AFaNbHATmDLDawYn/qqVUSDrQJA
+KEFraOeJNamxA7yXVvTXWy5gCINogWofHveCEX2Bbm82gIs0WCoqpUeae1RxDY75/8FinTOdMide
+gldmfVqLhqMlbj6+nKtMm7sTuxFT8ED2OMwA/3zie2emzk4p54b16EeKWqq/0jU27cCJiKVvNlHm
+VpEsH0j7mdEJP/HjApTsMOVucz2/6Iar13TIzqkUi83s3NQnI0Kw0FT5qsc6dzALgHugBLiOFeZE
+hlq643NTeZ6ds9rbiH7Vn033rQMYC+uHiqCfZwDx9epqUzWkZPpJ9VcQwRvmdd2OHN+2X/5M5CXa
+qpQZCNBO8QAzgCclvk688RHh+UidUL0epXLRStX1jvwy8EDyx1auh6unqZ3otXhto8/v6tDtG9HY
+c6G5j7VtVXWAC8brr8RjkcShfXhpiDgKD657dMcaXv9s47pv0p8kUGKbGfcx5xmrXKBtRmOQbGGY
+4gLdGKQg6w9/vd4xgv6LLrVmr5Zv5TLi9MHEqi01gY3PBPgO7vWFqfAj7e0PfdnitRZb21I3JYW8
+YZbN458CauuGLpS0CvCYyw4PPGLNyBgZaaVfPGfllzRtq6UMw8/vMde1jOxvfJ0xS4aqDK94K+A6
+c/qqGR/vxCjjwJfzgRDkLl9KAh1M+Py2TUY1+AvJ1P3v5f0zCftVfj8YKVrRykbSucMrIFaFuybv
+F3zkuaYZQ2Bso7jfIFjXZ7HLnMfNtuVz4p+WjTCCBUEGCSqGSIb3DQEHAaCCBTIEggUuMIIFKjCC
+BSYGCyqGSIb3DQEMCgECoIIE7jCCBOowHAYKKoZIhvcNAQwBAzAOBAggp9f3yfJ9ZgICCAAEggTI
+lwvLjqJfF6HwuYrShd2pOD2cQszODaLg/GkvPQTlQem651k3eP5tJ19xd5HYH1Fqsd+ywEP6YPbE
+7o7M28W/wW6+yeVQZqLpXAHOrLymt4ywY2KTp8PKmAgd6KgqWwRmsGk7iNo43KfVZnhT+BrT3j3G
+MWIN7H9ufxkaFBPEaNj3PvDm4KAoN+bTBaerq7Y4WfANPqraT7zg5um3iHcvX0O6QXJOfB2NCfxt
+3DnJvj0gNobWpdSep6H/yQUAl12kp3hmK4IqdHuaM2LacIGGO73FhZo3nxcJk23eV9wu0ozd/IbG
+emig7NUlCQVZQNiJf26yHbodInJ2aTiXJ/W0rlBC+/hLf50vN3HAZkv0azHS9tMlj0rpJlWmed5t
+yMW/AT8caJl3TBqn48ioOqzTrKlGF+/JutfAFvLBG3bnchsxV4vB/U3VQ2I3quVdvV2YHYgaVsJj
+Vw7NTc/SFoDAzBDGAHry/q9dpDa6ikQrXo9lEBHsPnpLG3Ef8jXSD8eMwzCd10KrnX8gK5K50CUY
+RP0267NZC1NupjbXY3m1vJxdIGSm9PEhpggHXdF5HyT5qbelZs8oWfaT2j9HlWcc4QAsNQx364RY
+yv/is6qBHm344u/TICTt4M3FVhDS698sMTqVV0Hc5WMfk4gKnplTd90/jMXn05ASln50zHl6Tbda
+HZyPE35Y9ELJ1wDwkL0FtHal4U/9m3PB9BW8nL2jeu3iFbJmo3AQMM22S25jHeSbNshlurMc0Ldx
+LmfMWLel4jJDkpYPC+7TTPfbQZfCzSaEq2Bo43624csLEnrwrEtDy3AW1kjEYwXPyMSHSxGVEhHD
+FWMx+YKZOSd16a6zoerTQq+GRDOYXVDXVKMKlTLs/vueyyasXpMkgfdPdHo8vi2W6Rq4k/Bg8CHI
+4icA9ztSf9/JU6tf9vEviPDptmBITVpjKIESYj3gn5uNa/0nfp5hEBx6+W70TiaRb8Jy13axjSG+
+W3T6/G2wkX9khCZfuf6DFl2Roi30SC0qqY5hIeUq2V6HFRPyq7wOgmPK49v4ulpyFvYZr7spll42
+IQCx2799LwpUNBMvJtrrkQxZMX9XHPKLwwQmK0oeG6XrSH9HKDS9KJoBXH+T125tM9ajJSTcq8Y2
+1ve1dgA2f5vvP+oaxMaWNk4r3rcv/kbnsmsKb1+vh+GHjHmH3mjstJEIHBDW4huJWH0VdbCzPDeQ
+uYf1bgCq33a1IA+xex/F+mzc2lys2rtXvDWodUJoDeGU2HW3veubXGS3Z3y7ZAPP2cm84BpbKrgg
+3CYU8a+Ynj9hF9vYxQ9Sl8ogNQK4hIk5x2ojE+07SqmOvV6pmOAoNofvepNwYAqQSmvUH93bxaVY
+uR/HVAXu++8GGCeBwEuXrfv5L0Kb51rLaiJ9RLkhfztG/URf+qui/LK+aZ9hcB8G3b5dECTx3/KS
+ZVeC14O4jQK/2HEL8xtQZTiJhsW/tEiifsQZqfzDPFzaqUDKslgXBtPyfJkVZ72M8cJFuDHjaR4a
+Jgvem/CxImCHEs2OXLWGtEsNJpmdVGgeb1Dd9jYONDvhr5jbJCdGa9BVF9xZrmGcPaKGbsY+Lygr
+4RDEZ08hmDuvP4+E7Bekjj5V+NBDPeR8BLNxMSUwIwYJKoZIhvcNAQkVMRYEFCW/bvnMd6lt3q9J
+PrtVBodl855TMDEwITAJBgUrDgMCGgUABBQ7QPJAPjLTUCW6aZIX+MQpi4cDWQQIyAN9SUFmaLYC
+AggA
+`
+
+func TestEphemeralClientCertificateCredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralClientCertificateCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.StringExact("ze-tenant"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.StringExact("ze-client"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("certificate"),
+						knownvalue.StringExact("ze-certificate"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("certificate_password"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("send_certificate_chain"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("scopes"),
+						knownvalue.SetExact([]knownvalue.Check{
+							knownvalue.StringExact("ze-scope-1"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_client_certificate_credential" "this" {
+	tenant_id                    = "ze-tenant"
+	client_id                    = "ze-client"
+	certificate                  = "ze-certificate"
+	certificate_password         = "ze-password"
+	send_certificate_chain       = true
+	additionally_allowed_tenants = ["ze-additional-tenant-1", "ze-additional-tenant-2"]
+	claims                       = "ze-claims"
+	enable_cae                   = true
+	scopes                       = ["ze-scope-1", "ze-scope-2"]
+	continue_on_error            = true
+	timeout                      = "1s"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_certificate_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("certificate_password"),
+						knownvalue.StringExact("ze-password"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("send_certificate_chain"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.StringExact("1s"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralClientCertificateCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralClientCertificateCredentialConfigContinueOnError("ze-certificate"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredentialPEM(t *testing.T) {
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.PostForm.Get("client_id") != "ze-client" {
+			t.Errorf("expected client_id to be ze-client, got %q", r.PostForm.Get("client_id"))
+		}
+
+		header := testParseJWTHeader(t, r.PostForm.Get("client_assertion"))
+		if _, ok := header["x5t#S256"]; !ok {
+			t.Errorf("expected client assertion header to contain x5t#S256, got %v", header)
+		}
+
+		if _, ok := header["x5c"]; ok {
+			t.Errorf("expected client assertion header to not contain x5c, got %v", header)
+		}
+
+		testEntraTokenResponse(w, "ze-certificate-token")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_client_certificate_credential" "this" {
+	tenant_id   = "ze-tenant"
+	client_id   = "ze-client"
+	certificate = %q
+	scopes      = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_certificate_credential.this
+}
+
+resource "echo" "this" {}
+`, testNewCertificatePEM(t, false)),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-certificate-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredentialPKCS12SendCertificateChain(t *testing.T) {
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		header := testParseJWTHeader(t, r.PostForm.Get("client_assertion"))
+		if _, ok := header["x5c"]; !ok {
+			t.Errorf("expected client assertion header to contain x5c, got %v", header)
+		}
+
+		testEntraTokenResponse(w, "ze-certificate-token")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_client_certificate_credential" "this" {
+	tenant_id              = "ze-tenant"
+	client_id              = "ze-client"
+	certificate            = %q
+	certificate_password   = "ze-password"
+	send_certificate_chain = true
+	scopes                 = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_certificate_credential.this
+}
+
+resource "echo" "this" {}
+`, testCertificatePKCS12),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-certificate-token"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredentialPKCS12WrongPassword(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_client_certificate_credential" "this" {
+	tenant_id            = "ze-tenant"
+	client_id            = "ze-client"
+	certificate          = %q
+	certificate_password = "ze-wrong-password"
+	scopes               = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_certificate_credential.this
+}
+
+resource "echo" "this" {}
+`, testCertificatePKCS12),
+				ExpectError: regexp.MustCompile(`failed to parse certificate`),
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredentialInvalidCertificateContinueOnError(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralClientCertificateCredentialConfigContinueOnError("ze-not-a-certificate!"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringRegexp(regexp.MustCompile(`certificate is neither PEM nor base64 encoded PKCS#12`)),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralClientCertificateCredentialUnsupportedKeyType(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralClientCertificateCredentialConfigContinueOnError(testNewCertificatePEM(t, true)),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringRegexp(regexp.MustCompile(`key must be an RSA key`)),
+					),
+				},
+			},
+		},
+	})
+}
+
+func testEphemeralClientCertificateCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_client_certificate_credential" "this" {
+	tenant_id   = "ze-tenant"
+	client_id   = "ze-client"
+	certificate = "ze-certificate"
+	scopes      = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_certificate_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+func testEphemeralClientCertificateCredentialConfigContinueOnError(certificate string) string {
+	return fmt.Sprintf(`
+ephemeral "azidentity_client_certificate_credential" "this" {
+	tenant_id         = "ze-tenant"
+	client_id         = "ze-client"
+	certificate       = %q
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_certificate_credential.this
+}
+
+resource "echo" "this" {}
+`, certificate)
+}
+
+// testNewCertificatePEM returns a PEM encoded self-signed certificate and
+// private key, using an ECDSA key instead of an RSA key when useECDSA is set.
+func testNewCertificatePEM(t *testing.T, useECDSA bool) string {
+	t.Helper()
+
+	var privateKey any
+	var publicKey any
+	if useECDSA {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate ECDSA key: %s", err)
+		}
+		privateKey, publicKey = key, key.Public()
+	} else {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("failed to generate RSA key: %s", err)
+		}
+		privateKey, publicKey = key, key.Public()
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ze-certificate"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %s", err)
+	}
+
+	var sb strings.Builder
+	sb.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+	sb.Write(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+
+	return sb.String()
+}
+
+func testParseJWTHeader(t *testing.T, token string) map[string]any {
+	t.Helper()
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatalf("failed to decode JWT header: %s", err)
+	}
+
+	header := map[string]any{}
+	err = json.Unmarshal(rawHeader, &header)
+	if err != nil {
+		t.Fatalf("failed to unmarshal JWT header: %s", err)
+	}
+
+	return header
+}
@@ -3,9 +3,10 @@ package provider
 import (
 	"context"
 	"fmt"
-	"time"
+	"net/http"
 
-	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
@@ -13,13 +14,18 @@ import (
 )
 
 var _ ephemeral.EphemeralResource = &ephemeralClientSecretCredential{}
+var _ ephemeral.EphemeralResourceWithRenew = &ephemeralClientSecretCredential{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralClientSecretCredential{}
 
 func newEphemeralClientSecretCredential() ephemeral.EphemeralResource {
 	return &ephemeralClientSecretCredential{}
 }
 
 type ephemeralClientSecretCredential struct {
-	getCredFn getCredentialFn
+	getCredFn  getCredentialFn
+	defaults   providerDefaults
+	httpClient *http.Client
+	tokenCache *tokenCache
 }
 
 type ephemeralClientSecretCredentialModel struct {
@@ -34,26 +40,41 @@ type ephemeralClientSecretCredentialModel struct {
 	Scopes                     types.Set    `tfsdk:"scopes"`
 	ContinueOnError            types.Bool   `tfsdk:"continue_on_error"`
 	Timeout                    types.String `tfsdk:"timeout"`
-	AccessToken                types.String `tfsdk:"access_token"`
-	ExpiresOn                  types.String `tfsdk:"expires_on"`
-	Success                    types.Bool   `tfsdk:"success"`
-	Error                      types.String `tfsdk:"error"`
+	MaxRetries                 types.Int64  `tfsdk:"max_retries"`
+	RetryDelay                 types.String `tfsdk:"retry_delay"`
+	MaxRetryDelay              types.String `tfsdk:"max_retry_delay"`
+	RetryStatusCodes           types.Set    `tfsdk:"retry_status_codes"`
+	tokenModel
+	CacheHit types.Bool   `tfsdk:"cache_hit"`
+	Success  types.Bool   `tfsdk:"success"`
+	Error    types.String `tfsdk:"error"`
 }
 
-func (r *ephemeralClientSecretCredentialModel) newCredentialConfig(ctx context.Context) credentialConfig {
+func (r *ephemeralClientSecretCredentialModel) newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error) {
+	cloudConfig, err := defaults.cloudConfig(ctx, r.Cloud, r.Timeout)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
+	retry, err := defaults.retryConfig(ctx, r.MaxRetries, r.RetryDelay, r.MaxRetryDelay, r.RetryStatusCodes)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
 	return credentialConfig{
-		CloudConfig:                getCloudConfig(r.Cloud.ValueString()),
-		TenantID:                   r.TenantID.ValueString(),
+		CloudConfig:                cloudConfig,
+		TenantID:                   defaults.tenantID(ctx, r.TenantID).ValueString(),
 		ClientID:                   r.ClientID.ValueString(),
 		ClientSecret:               r.ClientSecret.ValueString(),
-		AdditionallyAllowedTenants: typesSetToStringSlice(r.AdditionallyAllowedTenants),
-		DisableInstanceDiscovery:   r.DisableInstanceDiscovery.ValueBool(),
+		AdditionallyAllowedTenants: typesSetToStringSlice(defaults.additionallyAllowedTenants(ctx, r.AdditionallyAllowedTenants)),
+		DisableInstanceDiscovery:   defaults.disableInstanceDiscovery(ctx, r.DisableInstanceDiscovery).ValueBool(),
 		Claims:                     r.Claims.ValueString(),
 		EnableCAE:                  r.EnableCAE.ValueBool(),
 		Scopes:                     typesSetToStringSlice(r.Scopes),
 		ContinueOnError:            r.ContinueOnError.ValueBool(),
-		Timeout:                    parseTimeout(ctx, r.Timeout),
-	}
+		Timeout:                    parseTimeout(ctx, defaults.timeout(ctx, r.Timeout)),
+		Retry:                      retry,
+	}, nil
 }
 
 func (r *ephemeralClientSecretCredential) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
@@ -63,10 +84,10 @@ func (r *ephemeralClientSecretCredential) Metadata(_ context.Context, req epheme
 func (r *ephemeralClientSecretCredential) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		MarkdownDescription: "The `azidentity_client_secret_credential` resource enables authentication via an Azure **Client ID** and **Client Secret**. It is intended for service principals that require a static secret for authentication.",
-		Attributes: map[string]schema.Attribute{
+		Attributes: withTokenSchemaAttributes(map[string]schema.Attribute{
 			"tenant_id": schema.StringAttribute{
-				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure CLI and workload identity. Use 'organizations' or 'common' if you can't provide one but required to use one.",
-				Required:            true,
+				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure CLI and workload identity. The default is the provider `tenant_id`, one of them is required. Use 'organizations' or 'common' if you can't provide one but required to use one.",
+				Optional:            true,
 			},
 			"client_id": schema.StringAttribute{
 				MarkdownDescription: "ClientID is the application ID of the client.",
@@ -78,23 +99,19 @@ func (r *ephemeralClientSecretCredential) Schema(ctx context.Context, _ ephemera
 				Sensitive:           true,
 			},
 			"cloud": schema.StringAttribute{
-				MarkdownDescription: "Cloud specifies a cloud for the client. The default is AzurePublic.",
+				MarkdownDescription: "Cloud specifies a cloud for the client, either AzurePublic, AzureChina, AzureGovernment or the https URL of an ARM metadata endpoint, such as https://management.local.azurestack.external/metadata/endpoints. The default is the provider `cloud`, or AzurePublic.",
 				Optional:            true,
 				Validators: []validator.String{
-					stringvalidator.OneOf(
-						"AzurePublic",
-						"AzureChina",
-						"AzureGovernment",
-					),
+					newCloudValidator(),
 				},
 			},
 			"additionally_allowed_tenants": schema.SetAttribute{
-				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. This value can also be set as a semicolon delimited list of tenants in the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS. The default is an empty list.",
+				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. This value can also be set as a semicolon delimited list of tenants in the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS. The default is the provider `additionally_allowed_tenants`, or an empty list.",
 				Optional:            true,
 				ElementType:         types.StringType,
 			},
 			"disable_instance_discovery": schema.BoolAttribute{
-				MarkdownDescription: "DisableInstanceDiscovery should be set true only by applications authenticating in disconnected clouds, or private clouds such as Azure Stack. It determines whether the credential requests Microsoft Entra instance metadata from https://login.microsoft.com before authenticating. Setting this to true will skip this request, making the application responsible for ensuring the configured authority is valid and trustworthy. The default is false.",
+				MarkdownDescription: "DisableInstanceDiscovery should be set true only by applications authenticating in disconnected clouds, or private clouds such as Azure Stack. It determines whether the credential requests Microsoft Entra instance metadata from https://login.microsoft.com before authenticating. Setting this to true will skip this request, making the application responsible for ensuring the configured authority is valid and trustworthy. The default is the provider `disable_instance_discovery`, or false.",
 				Optional:            true,
 			},
 			"claims": schema.StringAttribute{
@@ -115,16 +132,34 @@ func (r *ephemeralClientSecretCredential) Schema(ctx context.Context, _ ephemera
 				Optional:            true,
 			},
 			"timeout": schema.StringAttribute{
-				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is 30 seconds ('30s').",
+				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is the provider `timeout`, or 30 seconds ('30s').",
 				Optional:            true,
 			},
-			"access_token": schema.StringAttribute{
-				MarkdownDescription: "The issued access token.",
-				Computed:            true,
-				Sensitive:           true,
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "MaxRetries is the maximum number of times a failed HTTP request of the credential is retried, 0 disables retries. Retries are bounded by the timeout. The default is the provider `max_retries`, or 3.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+				},
+			},
+			"retry_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryDelay is the initial delay before retrying a failed HTTP request, increasing exponentially with each retry up to MaxRetryDelay. It's only used when the response doesn't contain a Retry-After header. The default is the provider `retry_delay`, or '800ms'.",
+				Optional:            true,
 			},
-			"expires_on": schema.StringAttribute{
-				MarkdownDescription: "When the issued access token expires in RFC3339 format.",
+			"max_retry_delay": schema.StringAttribute{
+				MarkdownDescription: "MaxRetryDelay is the maximum delay before retrying a failed HTTP request. The default is the provider `max_retry_delay`, or '60s'.",
+				Optional:            true,
+			},
+			"retry_status_codes": schema.SetAttribute{
+				MarkdownDescription: "RetryStatusCodes are the HTTP status codes retried, an empty set only retries on network errors. The default is the provider `retry_status_codes`, or 408, 429, 500, 502, 503 and 504.",
+				Optional:            true,
+				ElementType:         types.Int64Type,
+				Validators: []validator.Set{
+					setvalidator.ValueInt64sAre(int64validator.Between(100, 599)),
+				},
+			},
+			"cache_hit": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if the access token was reused from the token cache of the provider instead of being requested.",
 				Computed:            true,
 			},
 			"success": schema.BoolAttribute{
@@ -135,7 +170,7 @@ func (r *ephemeralClientSecretCredential) Schema(ctx context.Context, _ ephemera
 				MarkdownDescription: "Error message if acquiring a token failed.",
 				Computed:            true,
 			},
-		},
+		}),
 	}
 }
 
@@ -154,6 +189,9 @@ func (p *ephemeralClientSecretCredential) Configure(ctx context.Context, req eph
 	}
 
 	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.defaults = provider.defaults
+	p.httpClient = provider.httpClient
 }
 
 func (r *ephemeralClientSecretCredential) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
@@ -164,8 +202,14 @@ func (r *ephemeralClientSecretCredential) Open(ctx context.Context, req ephemera
 		return
 	}
 
-	cfg := data.newCredentialConfig(ctx)
-	token, errSummary, err := getToken(ctx, clientSecretCredential, r.getCredFn, cfg)
+	cfg, err := data.newCredentialConfig(ctx, r.defaults)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Credential Configuration", err.Error())
+		return
+	}
+
+	cfg.HTTPClient = r.httpClient
+	token, cacheHit, errSummary, err := getCachedToken(ctx, r.tokenCache, clientSecretCredential, r.getCredFn, cfg)
 	if err != nil && cfg.ContinueOnError {
 		data.Error = types.StringValue(err.Error())
 		data.Success = types.BoolValue(false)
@@ -178,9 +222,19 @@ func (r *ephemeralClientSecretCredential) Open(ctx context.Context, req ephemera
 		return
 	}
 
-	data.AccessToken = types.StringValue(token.Token)
-	data.ExpiresOn = types.StringValue(token.ExpiresOn.Format(time.RFC3339))
+	data.setToken(token)
+	data.CacheHit = types.BoolValue(cacheHit)
 	data.Success = types.BoolValue(true)
 
+	resp.Diagnostics.Append(setCredentialRenewState(ctx, resp, r.tokenCache, clientSecretCredential, cfg, r.defaults.RenewMargin, token)...)
+
 	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
 }
+
+func (r *ephemeralClientSecretCredential) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	renewCredential(ctx, req, resp, r.tokenCache)
+}
+
+func (r *ephemeralClientSecretCredential) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	closeCredential(ctx, req, resp, r.tokenCache)
+}
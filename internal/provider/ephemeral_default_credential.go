@@ -3,9 +3,10 @@ package provider
 import (
 	"context"
 	"fmt"
-	"time"
+	"net/http"
 
-	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
@@ -13,13 +14,18 @@ import (
 )
 
 var _ ephemeral.EphemeralResource = &ephemeralDefaultCredential{}
+var _ ephemeral.EphemeralResourceWithRenew = &ephemeralDefaultCredential{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralDefaultCredential{}
 
 func newEphemeralDefaultCredential() ephemeral.EphemeralResource {
 	return &ephemeralDefaultCredential{}
 }
 
 type ephemeralDefaultCredential struct {
-	getCredFn getCredentialFn
+	getCredFn  getCredentialFn
+	defaults   providerDefaults
+	httpClient *http.Client
+	tokenCache *tokenCache
 }
 
 type ephemeralDefaultCredentialModel struct {
@@ -32,24 +38,39 @@ type ephemeralDefaultCredentialModel struct {
 	Scopes                     types.Set    `tfsdk:"scopes"`
 	ContinueOnError            types.Bool   `tfsdk:"continue_on_error"`
 	Timeout                    types.String `tfsdk:"timeout"`
-	AccessToken                types.String `tfsdk:"access_token"`
-	ExpiresOn                  types.String `tfsdk:"expires_on"`
-	Success                    types.Bool   `tfsdk:"success"`
-	Error                      types.String `tfsdk:"error"`
+	MaxRetries                 types.Int64  `tfsdk:"max_retries"`
+	RetryDelay                 types.String `tfsdk:"retry_delay"`
+	MaxRetryDelay              types.String `tfsdk:"max_retry_delay"`
+	RetryStatusCodes           types.Set    `tfsdk:"retry_status_codes"`
+	tokenModel
+	CacheHit types.Bool   `tfsdk:"cache_hit"`
+	Success  types.Bool   `tfsdk:"success"`
+	Error    types.String `tfsdk:"error"`
 }
 
-func (r *ephemeralDefaultCredentialModel) newCredentialConfig(ctx context.Context) credentialConfig {
+func (r *ephemeralDefaultCredentialModel) newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error) {
+	cloudConfig, err := defaults.cloudConfig(ctx, r.Cloud, r.Timeout)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
+	retry, err := defaults.retryConfig(ctx, r.MaxRetries, r.RetryDelay, r.MaxRetryDelay, r.RetryStatusCodes)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
 	return credentialConfig{
-		CloudConfig:                getCloudConfig(r.Cloud.ValueString()),
-		TenantID:                   r.TenantID.ValueString(),
-		AdditionallyAllowedTenants: typesSetToStringSlice(r.AdditionallyAllowedTenants),
-		DisableInstanceDiscovery:   r.DisableInstanceDiscovery.ValueBool(),
+		CloudConfig:                cloudConfig,
+		TenantID:                   defaults.configuredTenantID(ctx, r.TenantID).ValueString(),
+		AdditionallyAllowedTenants: typesSetToStringSlice(defaults.additionallyAllowedTenants(ctx, r.AdditionallyAllowedTenants)),
+		DisableInstanceDiscovery:   defaults.disableInstanceDiscovery(ctx, r.DisableInstanceDiscovery).ValueBool(),
 		Claims:                     r.Claims.ValueString(),
 		EnableCAE:                  r.EnableCAE.ValueBool(),
 		Scopes:                     typesSetToStringSlice(r.Scopes),
 		ContinueOnError:            r.ContinueOnError.ValueBool(),
-		Timeout:                    parseTimeout(ctx, r.Timeout),
-	}
+		Timeout:                    parseTimeout(ctx, defaults.timeout(ctx, r.Timeout)),
+		Retry:                      retry,
+	}, nil
 }
 
 func (r *ephemeralDefaultCredential) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
@@ -59,29 +80,25 @@ func (r *ephemeralDefaultCredential) Metadata(_ context.Context, req ephemeral.M
 func (r *ephemeralDefaultCredential) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		MarkdownDescription: "The `azidentity_default_credential` resource provides temporary authentication tokens using the **DefaultAzureCredential** mechanism. It automatically selects an appropriate authentication method, such as environment variables, managed identities, or an Azure CLI session.",
-		Attributes: map[string]schema.Attribute{
+		Attributes: withTokenSchemaAttributes(map[string]schema.Attribute{
 			"cloud": schema.StringAttribute{
-				MarkdownDescription: "Cloud specifies a cloud for the client. The default is AzurePublic.",
+				MarkdownDescription: "Cloud specifies a cloud for the client, either AzurePublic, AzureChina, AzureGovernment or the https URL of an ARM metadata endpoint, such as https://management.local.azurestack.external/metadata/endpoints. The default is the provider `cloud`, or AzurePublic.",
 				Optional:            true,
 				Validators: []validator.String{
-					stringvalidator.OneOf(
-						"AzurePublic",
-						"AzureChina",
-						"AzureGovernment",
-					),
+					newCloudValidator(),
 				},
 			},
 			"tenant_id": schema.StringAttribute{
-				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure CLI and workload identity. The default is empty, use 'organizations' or 'common' if you can't provide one but required to use one.",
+				MarkdownDescription: "TenantID sets the default tenant for authentication via the Azure CLI and workload identity. The default is the `tenant_id` configured on the provider, not its AZURE_TENANT_ID fallback, otherwise empty, use 'organizations' or 'common' if you can't provide one but required to use one.",
 				Optional:            true,
 			},
 			"additionally_allowed_tenants": schema.SetAttribute{
-				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. This value can also be set as a semicolon delimited list of tenants in the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS. The default is an empty list.",
+				MarkdownDescription: "AdditionallyAllowedTenants specifies tenants to which the credential may authenticate, in addition to TenantID. When TenantID is empty, this option has no effect and the credential will authenticate to any requested tenant. Add the wildcard value '*' to allow the credential to authenticate to any tenant. This value can also be set as a semicolon delimited list of tenants in the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS. The default is the provider `additionally_allowed_tenants`, or an empty list.",
 				Optional:            true,
 				ElementType:         types.StringType,
 			},
 			"disable_instance_discovery": schema.BoolAttribute{
-				MarkdownDescription: "DisableInstanceDiscovery should be set true only by applications authenticating in disconnected clouds, or private clouds such as Azure Stack. It determines whether the credential requests Microsoft Entra instance metadata from https://login.microsoft.com before authenticating. Setting this to true will skip this request, making the application responsible for ensuring the configured authority is valid and trustworthy. The default is false.",
+				MarkdownDescription: "DisableInstanceDiscovery should be set true only by applications authenticating in disconnected clouds, or private clouds such as Azure Stack. It determines whether the credential requests Microsoft Entra instance metadata from https://login.microsoft.com before authenticating. Setting this to true will skip this request, making the application responsible for ensuring the configured authority is valid and trustworthy. The default is the provider `disable_instance_discovery`, or false.",
 				Optional:            true,
 			},
 			"claims": schema.StringAttribute{
@@ -102,16 +119,34 @@ func (r *ephemeralDefaultCredential) Schema(ctx context.Context, _ ephemeral.Sch
 				Optional:            true,
 			},
 			"timeout": schema.StringAttribute{
-				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is 30 seconds ('30s').",
+				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is the provider `timeout`, or 30 seconds ('30s').",
 				Optional:            true,
 			},
-			"access_token": schema.StringAttribute{
-				MarkdownDescription: "The issued access token.",
-				Computed:            true,
-				Sensitive:           true,
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "MaxRetries is the maximum number of times a failed HTTP request of the credential is retried, 0 disables retries. Retries are bounded by the timeout. The default is the provider `max_retries`, or 3.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+				},
+			},
+			"retry_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryDelay is the initial delay before retrying a failed HTTP request, increasing exponentially with each retry up to MaxRetryDelay. It's only used when the response doesn't contain a Retry-After header. The default is the provider `retry_delay`, or '800ms'.",
+				Optional:            true,
+			},
+			"max_retry_delay": schema.StringAttribute{
+				MarkdownDescription: "MaxRetryDelay is the maximum delay before retrying a failed HTTP request. The default is the provider `max_retry_delay`, or '60s'.",
+				Optional:            true,
+			},
+			"retry_status_codes": schema.SetAttribute{
+				MarkdownDescription: "RetryStatusCodes are the HTTP status codes retried, an empty set only retries on network errors. The default is the provider `retry_status_codes`, or 408, 429, 500, 502, 503 and 504.",
+				Optional:            true,
+				ElementType:         types.Int64Type,
+				Validators: []validator.Set{
+					setvalidator.ValueInt64sAre(int64validator.Between(100, 599)),
+				},
 			},
-			"expires_on": schema.StringAttribute{
-				MarkdownDescription: "When the issued access token expires in RFC3339 format.",
+			"cache_hit": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if the access token was reused from the token cache of the provider instead of being requested.",
 				Computed:            true,
 			},
 			"success": schema.BoolAttribute{
@@ -122,7 +157,7 @@ func (r *ephemeralDefaultCredential) Schema(ctx context.Context, _ ephemeral.Sch
 				MarkdownDescription: "Error message if acquiring a token failed.",
 				Computed:            true,
 			},
-		},
+		}),
 	}
 }
 
@@ -141,6 +176,9 @@ func (p *ephemeralDefaultCredential) Configure(ctx context.Context, req ephemera
 	}
 
 	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.defaults = provider.defaults
+	p.httpClient = provider.httpClient
 }
 
 func (r *ephemeralDefaultCredential) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
@@ -151,8 +189,14 @@ func (r *ephemeralDefaultCredential) Open(ctx context.Context, req ephemeral.Ope
 		return
 	}
 
-	cfg := data.newCredentialConfig(ctx)
-	token, errSummary, err := getToken(ctx, defaultCredential, r.getCredFn, cfg)
+	cfg, err := data.newCredentialConfig(ctx, r.defaults)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Credential Configuration", err.Error())
+		return
+	}
+
+	cfg.HTTPClient = r.httpClient
+	token, cacheHit, errSummary, err := getCachedToken(ctx, r.tokenCache, defaultCredential, r.getCredFn, cfg)
 	if err != nil && cfg.ContinueOnError {
 		data.Error = types.StringValue(err.Error())
 		data.Success = types.BoolValue(false)
@@ -165,9 +209,19 @@ func (r *ephemeralDefaultCredential) Open(ctx context.Context, req ephemeral.Ope
 		return
 	}
 
-	data.AccessToken = types.StringValue(token.Token)
-	data.ExpiresOn = types.StringValue(token.ExpiresOn.Format(time.RFC3339))
+	data.setToken(token)
+	data.CacheHit = types.BoolValue(cacheHit)
 	data.Success = types.BoolValue(true)
 
+	resp.Diagnostics.Append(setCredentialRenewState(ctx, resp, r.tokenCache, defaultCredential, cfg, r.defaults.RenewMargin, token)...)
+
 	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
 }
+
+func (r *ephemeralDefaultCredential) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	renewCredential(ctx, req, resp, r.tokenCache)
+}
+
+func (r *ephemeralDefaultCredential) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	closeCredential(ctx, req, resp, r.tokenCache)
+}
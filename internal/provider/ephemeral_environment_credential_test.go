@@ -0,0 +1,423 @@
+package provider
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralEnvironmentCredentialEmpty(t *testing.T) {
+	testClearEnvironmentCredentialEnv(t)
+	t.Setenv("AZURE_TENANT_ID", "ze-tenant")
+	t.Setenv("AZURE_CLIENT_ID", "ze-client")
+	t.Setenv("AZURE_CLIENT_SECRET", "ze-secret")
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralEnvironmentCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("mode"),
+						knownvalue.StringExact("secret"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("cloud"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("scopes"),
+						knownvalue.SetExact([]knownvalue.Check{
+							knownvalue.StringExact("ze-scope-1"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralEnvironmentCredentialMode(t *testing.T) {
+	cases := []struct {
+		name         string
+		env          map[string]string
+		expectedMode knownvalue.Check
+	}{
+		{
+			name:         "none",
+			expectedMode: knownvalue.Null(),
+		},
+		{
+			name: "secret",
+			env: map[string]string{
+				"AZURE_TENANT_ID":               "ze-tenant",
+				"AZURE_CLIENT_ID":               "ze-client",
+				"AZURE_CLIENT_SECRET":           "ze-secret",
+				"AZURE_CLIENT_CERTIFICATE_PATH": "ze-certificate-path",
+				"AZURE_USERNAME":                "ze-username",
+			},
+			expectedMode: knownvalue.StringExact("secret"),
+		},
+		{
+			name: "certificate",
+			env: map[string]string{
+				"AZURE_TENANT_ID":               "ze-tenant",
+				"AZURE_CLIENT_ID":               "ze-client",
+				"AZURE_CLIENT_CERTIFICATE_PATH": "ze-certificate-path",
+				"AZURE_USERNAME":                "ze-username",
+			},
+			expectedMode: knownvalue.StringExact("certificate"),
+		},
+		{
+			name: "username_password",
+			env: map[string]string{
+				"AZURE_TENANT_ID": "ze-tenant",
+				"AZURE_CLIENT_ID": "ze-client",
+				"AZURE_USERNAME":  "ze-username",
+				"AZURE_PASSWORD":  "ze-password",
+			},
+			expectedMode: knownvalue.StringExact("username_password"),
+		},
+		{
+			name: "incomplete",
+			env: map[string]string{
+				"AZURE_CLIENT_SECRET": "ze-secret",
+			},
+			expectedMode: knownvalue.Null(),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testClearEnvironmentCredentialEnv(t)
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+				Steps: []resource.TestStep{
+					{
+						Config: testEphemeralEnvironmentCredentialEmptyConfig(),
+						ConfigStateChecks: []statecheck.StateCheck{
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("mode"),
+								c.expectedMode,
+							),
+						},
+					},
+				},
+			})
+		})
+	}
+}
+
+func TestEphemeralEnvironmentCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralEnvironmentCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralEnvironmentCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralEnvironmentCredentialConfigContinueOnError(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralEnvironmentCredentialClientSecret(t *testing.T) {
+	testClearEnvironmentCredentialEnv(t)
+	t.Setenv("AZURE_TENANT_ID", "ze-tenant")
+	t.Setenv("AZURE_CLIENT_ID", "ze-client")
+	t.Setenv("AZURE_CLIENT_SECRET", "ze-secret")
+
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ze-tenant/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path %q", r.URL.Path)
+		}
+
+		if r.PostForm.Get("client_id") != "ze-client" {
+			t.Errorf("expected client_id to be ze-client, got %q", r.PostForm.Get("client_id"))
+		}
+
+		if r.PostForm.Get("client_secret") != "ze-secret" {
+			t.Errorf("expected client_secret to be ze-secret, got %q", r.PostForm.Get("client_secret"))
+		}
+
+		testEntraTokenResponse(w, "ze-environment-token")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralEnvironmentCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-environment-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("mode"),
+						knownvalue.StringExact("secret"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralEnvironmentCredentialMissingEnvironment(t *testing.T) {
+	testClearEnvironmentCredentialEnv(t)
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralEnvironmentCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`AZURE_TENANT_ID`),
+			},
+		},
+	})
+}
+
+func TestGetEnvironmentCredentialMode(t *testing.T) {
+	cases := []struct {
+		name         string
+		env          map[string]string
+		expectedMode string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "secret",
+			env: map[string]string{
+				"AZURE_TENANT_ID":     "ze-tenant",
+				"AZURE_CLIENT_ID":     "ze-client",
+				"AZURE_CLIENT_SECRET": "ze-secret",
+			},
+			expectedMode: environmentCredentialModeSecret,
+		},
+		{
+			name: "secret_before_certificate",
+			env: map[string]string{
+				"AZURE_TENANT_ID":               "ze-tenant",
+				"AZURE_CLIENT_ID":               "ze-client",
+				"AZURE_CLIENT_SECRET":           "ze-secret",
+				"AZURE_CLIENT_CERTIFICATE_PATH": "ze-certificate-path",
+				"AZURE_USERNAME":                "ze-username",
+				"AZURE_PASSWORD":                "ze-password",
+			},
+			expectedMode: environmentCredentialModeSecret,
+		},
+		{
+			name: "certificate_before_username_password",
+			env: map[string]string{
+				"AZURE_TENANT_ID":               "ze-tenant",
+				"AZURE_CLIENT_ID":               "ze-client",
+				"AZURE_CLIENT_CERTIFICATE_PATH": "ze-certificate-path",
+				"AZURE_USERNAME":                "ze-username",
+				"AZURE_PASSWORD":                "ze-password",
+			},
+			expectedMode: environmentCredentialModeCertificate,
+		},
+		{
+			name: "username_password",
+			env: map[string]string{
+				"AZURE_TENANT_ID": "ze-tenant",
+				"AZURE_CLIENT_ID": "ze-client",
+				"AZURE_USERNAME":  "ze-username",
+				"AZURE_PASSWORD":  "ze-password",
+			},
+			expectedMode: environmentCredentialModeUsernamePassword,
+		},
+		{
+			name: "missing_tenant_id",
+			env: map[string]string{
+				"AZURE_CLIENT_ID":     "ze-client",
+				"AZURE_CLIENT_SECRET": "ze-secret",
+			},
+		},
+		{
+			name: "missing_client_id",
+			env: map[string]string{
+				"AZURE_TENANT_ID":               "ze-tenant",
+				"AZURE_CLIENT_CERTIFICATE_PATH": "ze-certificate-path",
+			},
+		},
+		{
+			name: "missing_password",
+			env: map[string]string{
+				"AZURE_TENANT_ID": "ze-tenant",
+				"AZURE_CLIENT_ID": "ze-client",
+				"AZURE_USERNAME":  "ze-username",
+			},
+		},
+		{
+			name: "missing_username",
+			env: map[string]string{
+				"AZURE_TENANT_ID": "ze-tenant",
+				"AZURE_CLIENT_ID": "ze-client",
+				"AZURE_PASSWORD":  "ze-password",
+			},
+		},
+		{
+			name: "tenant_and_client_only",
+			env: map[string]string{
+				"AZURE_TENANT_ID": "ze-tenant",
+				"AZURE_CLIENT_ID": "ze-client",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testClearEnvironmentCredentialEnv(t)
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+
+			mode := getEnvironmentCredentialMode()
+			if mode != c.expectedMode {
+				t.Errorf("expected mode %q, got %q", c.expectedMode, mode)
+			}
+		})
+	}
+}
+
+func testClearEnvironmentCredentialEnv(t *testing.T) {
+	t.Helper()
+
+	for _, k := range []string{
+		"AZURE_TENANT_ID",
+		"AZURE_CLIENT_ID",
+		"AZURE_CLIENT_SECRET",
+		"AZURE_CLIENT_CERTIFICATE_PATH",
+		"AZURE_CLIENT_CERTIFICATE_PASSWORD",
+		"AZURE_CLIENT_SEND_CERTIFICATE_CHAIN",
+		"AZURE_USERNAME",
+		"AZURE_PASSWORD",
+		"AZURE_ADDITIONALLY_ALLOWED_TENANTS",
+	} {
+		t.Setenv(k, "")
+	}
+}
+
+func testEphemeralEnvironmentCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_environment_credential" "this" {
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_environment_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+func testEphemeralEnvironmentCredentialConfigContinueOnError() string {
+	return `
+ephemeral "azidentity_environment_credential" "this" {
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_environment_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
@@ -10,6 +10,7 @@ import (
 )
 
 var _ ephemeral.EphemeralResource = &ephemeralEnvironmentVariable{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralEnvironmentVariable{}
 
 func newEphemeralEnvironmentVariable() ephemeral.EphemeralResource {
 	return &ephemeralEnvironmentVariable{}
@@ -61,3 +62,7 @@ func (r *ephemeralEnvironmentVariable) Open(ctx context.Context, req ephemeral.O
 
 	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
 }
+
+// Close is a no-op, the resource keeps nothing beyond its result.
+func (r *ephemeralEnvironmentVariable) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+}
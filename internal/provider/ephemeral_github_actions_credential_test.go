@@ -0,0 +1,392 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralGitHubActionsCredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralGitHubActionsCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("audience"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralGitHubActionsCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_github_actions_credential" "this" {
+	tenant_id                    = "ze-tenant"
+	client_id                    = "ze-client"
+	audience                     = "ze-audience"
+	additionally_allowed_tenants = ["ze-additional-tenant-1", "ze-additional-tenant-2"]
+	claims                       = "ze-claims"
+	enable_cae                   = true
+	scopes                       = ["ze-scope-1", "ze-scope-2"]
+	continue_on_error            = true
+	timeout                      = "1s"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_github_actions_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.StringExact("ze-tenant"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.StringExact("ze-client"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("audience"),
+						knownvalue.StringExact("ze-audience"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.StringExact("1s"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralGitHubActionsCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralGitHubActionsCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralGitHubActionsCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralGitHubActionsCredentialConfigContinueOnError(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralGitHubActionsCredentialOIDC(t *testing.T) {
+	cases := []struct {
+		name             string
+		audienceAttr     string
+		expectedAudience string
+	}{
+		{
+			name:             "default audience",
+			expectedAudience: "api://AzureADTokenExchange",
+		},
+		{
+			name:             "custom audience",
+			audienceAttr:     `audience = "ze-audience"`,
+			expectedAudience: "ze-audience",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			idToken := testNewJWT(t, time.Now().Add(time.Hour))
+			gitHubServer := testNewGitHubActionsOIDCServer(t, c.expectedAudience, idToken)
+			entraServer := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/ze-tenant/oauth2/v2.0/token" {
+					t.Errorf("unexpected token path %q", r.URL.Path)
+				}
+
+				if r.PostForm.Get("client_assertion") != idToken {
+					t.Errorf("expected client_assertion to be the GitHub Actions ID token, got %q", r.PostForm.Get("client_assertion"))
+				}
+
+				testEntraTokenResponse(w, "ze-github-token")
+			})
+
+			t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", gitHubServer.URL+"/ze-oidc?api-version=2.0")
+			t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "ze-request-token")
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, entraServer.URL)),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+ephemeral "azidentity_github_actions_credential" "this" {
+	tenant_id = "ze-tenant"
+	client_id = "ze-client"
+	%s
+	scopes    = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_github_actions_credential.this
+}
+
+resource "echo" "this" {}
+`, c.audienceAttr),
+						ConfigStateChecks: []statecheck.StateCheck{
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("access_token"),
+								knownvalue.StringExact("ze-github-token"),
+							),
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("success"),
+								knownvalue.Bool(true),
+							),
+						},
+					},
+				},
+			})
+		})
+	}
+}
+
+func TestEphemeralGitHubActionsCredentialOIDCFailure(t *testing.T) {
+	gitHubServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`ze-forbidden`)) // nolint:errcheck
+	}))
+
+	defer gitHubServer.Close()
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", gitHubServer.URL+"/ze-oidc?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "ze-request-token")
+
+	entraServer := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected token request")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, entraServer.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralGitHubActionsCredentialConfigContinueOnError(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringRegexp(regexp.MustCompile(`unexpected status code 403 requesting GitHub Actions ID token: ze-forbidden`)),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralGitHubActionsCredentialMissingEnvironment(t *testing.T) {
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "")
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_github_actions_credential" "this" {
+	tenant_id = "ze-tenant"
+	client_id = "ze-client"
+	scopes    = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_github_actions_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN`),
+			},
+		},
+	})
+}
+
+func testEphemeralGitHubActionsCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_github_actions_credential" "this" {
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_github_actions_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+func testEphemeralGitHubActionsCredentialConfigContinueOnError() string {
+	return `
+ephemeral "azidentity_github_actions_credential" "this" {
+	tenant_id         = "ze-tenant"
+	client_id         = "ze-client"
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_github_actions_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+// testNewGitHubActionsOIDCServer starts a stand-in for the GitHub Actions
+// OIDC provider, returning idToken when requested with the expected audience.
+func testNewGitHubActionsOIDCServer(t *testing.T, expectedAudience string, idToken string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ze-oidc" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+
+		if r.URL.Query().Get("api-version") != "2.0" {
+			t.Errorf("expected api-version to be preserved, got %q", r.URL.Query().Get("api-version"))
+		}
+
+		if r.URL.Query().Get("audience") != expectedAudience {
+			t.Errorf("expected audience %q, got %q", expectedAudience, r.URL.Query().Get("audience"))
+		}
+
+		if r.Header.Get("Authorization") != "Bearer ze-request-token" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"count":1,"value":"%s"}`, idToken)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
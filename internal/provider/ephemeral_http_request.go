@@ -1,43 +1,81 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var _ ephemeral.EphemeralResource = &ephemeralHttpRequest{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralHttpRequest{}
+var _ ephemeral.EphemeralResourceWithValidateConfig = &ephemeralHttpRequest{}
 
 func newEphemeralHttpRequest() ephemeral.EphemeralResource {
 	return &ephemeralHttpRequest{}
 }
 
 type ephemeralHttpRequest struct {
-	httpClient *http.Client
+	getCredFn  getCredentialFn
+	tokenCache *tokenCache
+	// closeRequests keeps the close requests of the open resources.
+	closeRequests *httpCloseRequestStore
+	defaults      providerDefaults
+	httpClient    *http.Client
+	runCmdFn      runCommandFn
 }
 
 type ephemeralHttpRequestModel struct {
-	RequestURL         types.String `tfsdk:"request_url"`
-	RequestMethod      types.String `tfsdk:"request_method"`
-	RequestBody        types.String `tfsdk:"request_body"`
-	RequestHeaders     types.Map    `tfsdk:"request_headers"`
-	ResponseBody       types.String `tfsdk:"response_body"`
-	ResponseHeaders    types.Map    `tfsdk:"response_headers"`
-	ResponseStatusCode types.Int32  `tfsdk:"response_status_code"`
-	ContinueOnError    types.Bool   `tfsdk:"continue_on_error"`
-	Timeout            types.String `tfsdk:"timeout"`
-	Success            types.Bool   `tfsdk:"success"`
-	Error              types.String `tfsdk:"error"`
+	RequestURL          types.String  `tfsdk:"request_url"`
+	RequestMethod       types.String  `tfsdk:"request_method"`
+	RequestBody         types.String  `tfsdk:"request_body"`
+	RequestForm         types.Map     `tfsdk:"request_form"`
+	RequestMultipart    types.Map     `tfsdk:"request_multipart"`
+	RequestHeaders      types.Map     `tfsdk:"request_headers"`
+	ResponseBody        types.String  `tfsdk:"response_body"`
+	ResponseHeaders     types.Map     `tfsdk:"response_headers"`
+	ResponseStatusCode  types.Int32   `tfsdk:"response_status_code"`
+	ResponseJSONPaths   types.Map     `tfsdk:"response_json_paths"`
+	DecodeResponseJSON  types.Bool    `tfsdk:"decode_response_json"`
+	ResponseValues      types.Map     `tfsdk:"response_values"`
+	ResponseJSON        types.Dynamic `tfsdk:"response_json"`
+	ContinueOnError     types.Bool    `tfsdk:"continue_on_error"`
+	Timeout             types.String  `tfsdk:"timeout"`
+	RetryMaxAttempts    types.Int64   `tfsdk:"retry_max_attempts"`
+	RetryMinDelay       types.String  `tfsdk:"retry_min_delay"`
+	RetryMaxDelay       types.String  `tfsdk:"retry_max_delay"`
+	RetryOnStatusCodes  types.Set     `tfsdk:"retry_on_status_codes"`
+	ExpectedStatusCodes types.Set     `tfsdk:"expected_status_codes"`
+	AzureAuth           types.Object  `tfsdk:"azure_auth"`
+	CloseRequest        types.Object  `tfsdk:"close_request"`
+	Attempts            types.Int64   `tfsdk:"attempts"`
+	Success             types.Bool    `tfsdk:"success"`
+	Error               types.String  `tfsdk:"error"`
+}
+
+type ephemeralHttpCloseRequestModel struct {
+	URL     types.String `tfsdk:"url"`
+	Method  types.String `tfsdk:"method"`
+	Headers types.Map    `tfsdk:"headers"`
+	Body    types.String `tfsdk:"body"`
 }
 
 func (r *ephemeralHttpRequest) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
@@ -45,6 +83,17 @@ func (r *ephemeralHttpRequest) Metadata(_ context.Context, req ephemeral.Metadat
 }
 
 func (r *ephemeralHttpRequest) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	azureAuthAttributes := credentialSourceAttributes(ctx)
+	azureAuthAttributes["scopes"] = schema.SetAttribute{
+		MarkdownDescription: "Scopes contains the list of permission scopes required for the token. E.g. https://management.azure.com/.default for Azure Resource Manager or https://graph.microsoft.com/.default for Microsoft Graph.",
+		Required:            true,
+		ElementType:         types.StringType,
+	}
+	azureAuthAttributes["enable_cae"] = schema.BoolAttribute{
+		MarkdownDescription: "EnableCAE indicates whether to request a Continuous Access Evaluation (CAE) token, for APIs supporting CAE. A revoked CAE token is answered with a claims challenge, which is retried once. The default is false.",
+		Optional:            true,
+	}
+
 	resp.Schema = schema.Schema{
 		MarkdownDescription: "The `azidentity_http_request` resource performs HTTP requests within Terraform. This allows retrieval of external authentication tokens or metadata required for Terraform execution.",
 		Attributes: map[string]schema.Attribute{
@@ -57,23 +106,58 @@ func (r *ephemeralHttpRequest) Schema(ctx context.Context, _ ephemeral.SchemaReq
 				MarkdownDescription: "The HTTP method to use for the request.",
 				Required:            true,
 				Validators: []validator.String{
-					stringvalidator.OneOf(
-						http.MethodConnect,
-						http.MethodDelete,
-						http.MethodGet,
-						http.MethodHead,
-						http.MethodOptions,
-						http.MethodPatch,
-						http.MethodPost,
-						http.MethodPut,
-						http.MethodTrace,
-					),
+					newHttpMethodValidator(),
 				},
 			},
 			"request_body": schema.StringAttribute{
-				MarkdownDescription: "The body of the HTTP request. Defaults to an empty body.",
+				MarkdownDescription: "The body of the HTTP request. Conflicts with `request_form` and `request_multipart`. Defaults to an empty body.",
+				Optional:            true,
+				Sensitive:           true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(
+						path.MatchRelative().AtParent().AtName("request_form"),
+						path.MatchRelative().AtParent().AtName("request_multipart"),
+					),
+				},
+			},
+			"request_form": schema.MapAttribute{
+				MarkdownDescription: "RequestForm are the fields of a form-encoded request body, as expected by OAuth2 token endpoints, encoded and sent with the `Content-Type` header `application/x-www-form-urlencoded`, replacing any in `request_headers`. Conflicts with `request_body` and `request_multipart`. The default is no form.",
+				ElementType:         types.StringType,
 				Optional:            true,
 				Sensitive:           true,
+				Validators: []validator.Map{
+					mapvalidator.ConflictsWith(
+						path.MatchRelative().AtParent().AtName("request_body"),
+						path.MatchRelative().AtParent().AtName("request_multipart"),
+					),
+				},
+			},
+			"request_multipart": schema.MapNestedAttribute{
+				MarkdownDescription: "RequestMultipart are the parts of a multipart request body by field name, sent with the `Content-Type` header `multipart/form-data` and the boundary of the parts, replacing any in `request_headers`. Conflicts with `request_body` and `request_form`. The default is no multipart body.",
+				Optional:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"value": schema.StringAttribute{
+							MarkdownDescription: "The content of the part.",
+							Required:            true,
+							Sensitive:           true,
+						},
+						"filename": schema.StringAttribute{
+							MarkdownDescription: "The file name of the part, sending the part as a file. The default is no file name.",
+							Optional:            true,
+						},
+						"content_type": schema.StringAttribute{
+							MarkdownDescription: "The `Content-Type` header of the part. The default is no header, which is plain text.",
+							Optional:            true,
+						},
+					},
+				},
+				Validators: []validator.Map{
+					mapvalidator.ConflictsWith(
+						path.MatchRelative().AtParent().AtName("request_body"),
+						path.MatchRelative().AtParent().AtName("request_form"),
+					),
+				},
 			},
 			"request_headers": schema.MapAttribute{
 				MarkdownDescription: "The headers to include in the HTTP request.",
@@ -89,6 +173,80 @@ func (r *ephemeralHttpRequest) Schema(ctx context.Context, _ ephemeral.SchemaReq
 				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is 30 seconds ('30s').",
 				Optional:            true,
 			},
+			"retry_max_attempts": schema.Int64Attribute{
+				MarkdownDescription: "RetryMaxAttempts is the maximum number of attempts made to send the request, retrying on network errors and the status codes of `retry_on_status_codes`. Retries are bounded by the timeout. The default is 1, no retries.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 100),
+				},
+			},
+			"retry_min_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryMinDelay is the initial delay before retrying the request, increasing exponentially with each retry up to RetryMaxDelay. It's only used when the response doesn't contain a Retry-After header. The default is '1s'.",
+				Optional:            true,
+			},
+			"retry_max_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryMaxDelay is the maximum delay before retrying the request, also capping the delay requested by a Retry-After header. The default is '30s'.",
+				Optional:            true,
+			},
+			"retry_on_status_codes": schema.SetAttribute{
+				MarkdownDescription: "RetryOnStatusCodes are the HTTP status codes retried, an empty set only retries on network errors. The default is 408, 429, 500, 502, 503 and 504.",
+				Optional:            true,
+				ElementType:         types.Int64Type,
+				Validators: []validator.Set{
+					setvalidator.ValueInt64sAre(int64validator.Between(100, 599)),
+				},
+			},
+			"expected_status_codes": schema.SetAttribute{
+				MarkdownDescription: "ExpectedStatusCodes are the HTTP status codes expected in the response, such as 200, or classes of status codes, such as 2xx. Any other status code fails the request, with an error including the start of the response body, where anything looking like a token or secret is redacted. The default is any status code.",
+				Optional:            true,
+				ElementType:         types.StringType,
+				Validators: []validator.Set{
+					setvalidator.ValueStringsAre(stringvalidator.RegexMatches(httpStatusCodePattern, "must be a status code such as 200 or a class of status codes such as 2xx")),
+				},
+			},
+			"response_json_paths": schema.MapAttribute{
+				MarkdownDescription: "ResponseJSONPaths are JSONPath-style expressions extracting values from a JSON response body into `response_values`, by name. An expression consists of object keys and array indexes, such as `$.items[0].name`, `items[-1]` or `$['key.with.dots']`, where the leading `$` is optional and negative indexes count from the end. A failing expression fails the request. The default is no extracted values.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"decode_response_json": schema.BoolAttribute{
+				MarkdownDescription: "DecodeResponseJSON decodes the response body as JSON into `response_json`. The default is false, leaving `response_json` null.",
+				Optional:            true,
+			},
+			"azure_auth": schema.SingleNestedAttribute{
+				MarkdownDescription: "AzureAuth authenticates the request with a bearer token for `scopes`, acquired through the token cache of the provider by exactly one of the credentials, configured as with `azidentity_chained_credential` sources. The token is set as the `Authorization` header, replacing any in `request_headers`. A 401 response with a claims challenge in its `WWW-Authenticate` header is retried once, with a token satisfying the claims. The default is no authentication.",
+				Optional:            true,
+				Attributes:          azureAuthAttributes,
+			},
+			"close_request": schema.SingleNestedAttribute{
+				MarkdownDescription: "CloseRequest is an HTTP request sent when the ephemeral resource is closed, after a successful request, such as a request revoking or deleting what the request created. It's authenticated as the request when `azure_auth` is set. It's kept in the memory of the provider, never sent to Terraform, so it isn't sent when the provider was restarted since the request. A failing close request is reported as a warning. The default is no close request.",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"url": schema.StringAttribute{
+						MarkdownDescription: "The URL to send the close request to. The default is `request_url`.",
+						Optional:            true,
+						Sensitive:           true,
+					},
+					"method": schema.StringAttribute{
+						MarkdownDescription: "The HTTP method to use for the close request, e.g. DELETE.",
+						Required:            true,
+						Validators: []validator.String{
+							newHttpMethodValidator(),
+						},
+					},
+					"headers": schema.MapAttribute{
+						MarkdownDescription: "The headers to include in the close request.",
+						ElementType:         types.StringType,
+						Optional:            true,
+						Sensitive:           true,
+					},
+					"body": schema.StringAttribute{
+						MarkdownDescription: "The body of the close request. Defaults to an empty body.",
+						Optional:            true,
+						Sensitive:           true,
+					},
+				},
+			},
 			"response_body": schema.StringAttribute{
 				MarkdownDescription: "The body of the HTTP response.",
 				Sensitive:           true,
@@ -100,10 +258,25 @@ func (r *ephemeralHttpRequest) Schema(ctx context.Context, _ ephemeral.SchemaReq
 				Sensitive:           true,
 				Computed:            true,
 			},
+			"response_values": schema.MapAttribute{
+				MarkdownDescription: "The values extracted from the response body by `response_json_paths`. Strings are kept as is, JSON nulls are null and any other value is JSON encoded.",
+				ElementType:         types.StringType,
+				Sensitive:           true,
+				Computed:            true,
+			},
+			"response_json": schema.DynamicAttribute{
+				MarkdownDescription: "The response body decoded as JSON, as with `jsondecode`, when `decode_response_json` is true. Null otherwise, or if the response body isn't JSON.",
+				Sensitive:           true,
+				Computed:            true,
+			},
 			"response_status_code": schema.Int32Attribute{
 				MarkdownDescription: "The status code of the HTTP response.",
 				Computed:            true,
 			},
+			"attempts": schema.Int64Attribute{
+				MarkdownDescription: "The number of attempts made to send the HTTP request.",
+				Computed:            true,
+			},
 			"success": schema.BoolAttribute{
 				MarkdownDescription: "Indicates if the HTTP request was successful.",
 				Computed:            true,
@@ -116,6 +289,24 @@ func (r *ephemeralHttpRequest) Schema(ctx context.Context, _ ephemeral.SchemaReq
 	}
 }
 
+func (r *ephemeralHttpRequest) ValidateConfig(ctx context.Context, req ephemeral.ValidateConfigRequest, resp *ephemeral.ValidateConfigResponse) {
+	var azureAuth types.Object
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("azure_auth"), &azureAuth)...)
+	if resp.Diagnostics.HasError() || azureAuth.IsNull() || azureAuth.IsUnknown() {
+		return
+	}
+
+	configured, known := configuredCredentialSources(azureAuth)
+	if known && configured != 1 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("azure_auth"),
+			"Invalid Azure Auth",
+			fmt.Sprintf("azure_auth has to configure exactly one credential, got %d.", configured),
+		)
+	}
+}
+
 func (p *ephemeralHttpRequest) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
 	if req.ProviderData == nil {
 		return
@@ -135,7 +326,12 @@ func (p *ephemeralHttpRequest) Configure(ctx context.Context, req ephemeral.Conf
 		return
 	}
 
+	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.closeRequests = provider.httpCloseRequests
+	p.defaults = provider.defaults
 	p.httpClient = provider.httpClient
+	p.runCmdFn = provider.runCmdFn
 }
 
 func (r *ephemeralHttpRequest) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
@@ -146,59 +342,93 @@ func (r *ephemeralHttpRequest) Open(ctx context.Context, req ephemeral.OpenReque
 		return
 	}
 
-	reqTimeout := parseTimeout(ctx, data.Timeout)
-	reqCtx, cancel := context.WithTimeout(ctx, reqTimeout)
-	defer cancel()
-
-	reqMethod := data.RequestMethod.ValueString()
-	reqUrl := data.RequestURL.ValueString()
-	var reqBody io.ReadCloser = http.NoBody
-	if !data.RequestBody.IsNull() {
-		reqBody = io.NopCloser(strings.NewReader(data.RequestBody.ValueString()))
+	retry, err := newHttpRetryConfig(data.RetryMaxAttempts, data.RetryMinDelay, data.RetryMaxDelay, data.RetryOnStatusCodes)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Retry Configuration", err.Error())
+		return
 	}
 
-	httpReq, err := http.NewRequestWithContext(reqCtx, reqMethod, reqUrl, reqBody)
+	expectedStatusCodes, err := newHttpStatusCodeRanges(data.ExpectedStatusCodes)
 	if err != nil {
-		if data.ContinueOnError.ValueBool() {
-			data.Error = types.StringValue(err.Error())
-			data.Success = types.BoolValue(false)
-			resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+		resp.Diagnostics.AddError("Invalid Expected Status Codes", err.Error())
+		return
+	}
+
+	// The tokens of azure_auth are held until the resource is closed, or
+	// released right away when it fails to open.
+	var azureAuth *httpAzureAuth
+	azureAuthHeld := false
+	defer func() {
+		if azureAuth != nil && !azureAuthHeld {
+			azureAuth.release(r.tokenCache)
+		}
+	}()
+
+	if !data.AzureAuth.IsNull() {
+		var diags diag.Diagnostics
+		azureAuth, diags = newHttpAzureAuth(ctx, data.AzureAuth, r.defaults)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
 			return
 		}
 
-		resp.Diagnostics.AddError("Failed to create HTTP request", err.Error())
+		azureAuth.cfg.HTTPClient = r.httpClient
+		azureAuth.cfg.RunCmdFn = r.runCmdFn
+	}
+
+	reqTimeout := parseTimeout(ctx, data.Timeout)
+	reqCtx, cancel := context.WithTimeout(ctx, reqTimeout)
+	defer cancel()
+
+	reqMethod := data.RequestMethod.ValueString()
+	reqUrl := data.RequestURL.ValueString()
+	body, contentType, diags := newHttpRequestBody(ctx, data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	for k, v := range data.RequestHeaders.Elements() {
-		if v.IsNull() {
-			continue
+	newRequest := func() (*http.Request, error) {
+		var reqBody io.Reader = http.NoBody
+		if body != nil {
+			reqBody = bytes.NewReader(body)
 		}
 
-		vv, ok := v.(types.String)
-		if !ok {
-			continue
+		httpReq, err := http.NewRequestWithContext(reqCtx, reqMethod, reqUrl, reqBody)
+		if err != nil {
+			return nil, err
 		}
 
-		httpReq.Header.Set(k, vv.ValueString())
-	}
+		for k, v := range data.RequestHeaders.Elements() {
+			if v.IsNull() {
+				continue
+			}
 
-	httpRes, err := r.httpClient.Do(httpReq)
-	if err != nil {
-		if data.ContinueOnError.ValueBool() {
-			data.Error = types.StringValue(err.Error())
-			data.Success = types.BoolValue(false)
-			resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
-			return
+			vv, ok := v.(types.String)
+			if !ok {
+				continue
+			}
+
+			httpReq.Header.Set(k, vv.ValueString())
 		}
 
-		resp.Diagnostics.AddError("Failed to send HTTP request", err.Error())
-		return
-	}
+		if contentType != "" {
+			httpReq.Header.Set("Content-Type", contentType)
+		}
 
-	defer func() { _ = httpRes.Body.Close() }()
+		return httpReq, nil
+	}
 
-	resBody, err := io.ReadAll(httpRes.Body)
+	var httpRes *http.Response
+	var resBody []byte
+	var attempts int
+	var errSummary string
+	if azureAuth != nil {
+		httpRes, resBody, attempts, errSummary, err = azureAuth.do(reqCtx, r.tokenCache, r.getCredFn, retry, r.httpClient, newRequest)
+	} else {
+		httpRes, resBody, attempts, errSummary, err = retry.do(reqCtx, r.httpClient, newRequest)
+	}
+	data.Attempts = types.Int64Value(int64(attempts))
 	if err != nil {
 		if data.ContinueOnError.ValueBool() {
 			data.Error = types.StringValue(err.Error())
@@ -207,7 +437,7 @@ func (r *ephemeralHttpRequest) Open(ctx context.Context, req ephemeral.OpenReque
 			return
 		}
 
-		resp.Diagnostics.AddError("Failed to read HTTP response body", err.Error())
+		resp.Diagnostics.AddError(errSummary, err.Error())
 		return
 	}
 
@@ -233,7 +463,111 @@ func (r *ephemeralHttpRequest) Open(ctx context.Context, req ephemeral.OpenReque
 	}
 	data.ResponseHeaders = responseHeaders
 	data.ResponseStatusCode = types.Int32Value(int32(httpRes.StatusCode))
+
+	err = checkHttpStatusCode(expectedStatusCodes, data.ExpectedStatusCodes, httpRes.StatusCode, resBody)
+	if err != nil {
+		if data.ContinueOnError.ValueBool() {
+			data.Error = types.StringValue(err.Error())
+			data.Success = types.BoolValue(false)
+			resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+			return
+		}
+
+		resp.Diagnostics.AddError("Unexpected HTTP status code", err.Error())
+		return
+	}
+
+	// The response body is only decoded when used, as it may be large.
+	var doc any
+	var docErr error
+	if data.DecodeResponseJSON.ValueBool() || !data.ResponseJSONPaths.IsNull() {
+		doc, docErr = decodeJSON(resBody)
+	}
+
+	data.ResponseJSON = types.DynamicNull()
+	if data.DecodeResponseJSON.ValueBool() && docErr == nil {
+		responseJSON, diags := newJSONValue(ctx, doc)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.ResponseJSON = types.DynamicValue(responseJSON)
+	}
+
+	data.ResponseValues = types.MapNull(types.StringType)
+	if !data.ResponseJSONPaths.IsNull() {
+		values, errs := extractJSONValues(data.ResponseJSONPaths, doc, docErr)
+		responseValues, diags := types.MapValue(types.StringType, values)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.ResponseValues = responseValues
+
+		if len(errs) > 0 {
+			keys := slices.Sorted(maps.Keys(errs))
+			if data.ContinueOnError.ValueBool() {
+				extractErrs := []error{}
+				for _, k := range keys {
+					extractErrs = append(extractErrs, fmt.Errorf("failed to extract response_json_paths[%q]: %w", k, errs[k]))
+				}
+				data.Error = types.StringValue(errors.Join(extractErrs...).Error())
+				data.Success = types.BoolValue(false)
+				resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+				return
+			}
+
+			for _, k := range keys {
+				resp.Diagnostics.AddAttributeError(path.Root("response_json_paths").AtMapKey(k), "Failed to extract JSON value", errs[k].Error())
+			}
+			return
+		}
+	}
+
 	data.Success = types.BoolValue(true)
 
+	if !data.CloseRequest.IsNull() && resp.Private != nil {
+		resp.Diagnostics.Append(r.closeRequests.put(ctx, resp, data.CloseRequest, reqUrl, reqTimeout, azureAuth)...)
+	}
+
+	if azureAuth != nil && resp.Private != nil {
+		diags := azureAuth.setPrivate(ctx, resp)
+		resp.Diagnostics.Append(diags...)
+		azureAuthHeld = !diags.HasError()
+	}
+
 	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
 }
+
+// Close sends the close request, if any, and releases the tokens held by
+// azure_auth.
+func (r *ephemeralHttpRequest) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	defer releaseHttpAzureAuth(ctx, req, resp, r.tokenCache)
+
+	closeReq, ok := r.closeRequests.take(ctx, req, resp)
+	if !ok {
+		return
+	}
+
+	err := closeReq.send(ctx, r.tokenCache, r.getCredFn, r.httpClient)
+	if err != nil {
+		resp.Diagnostics.AddWarning("Failed to send HTTP close request", err.Error())
+		return
+	}
+
+	tflog.Debug(ctx, fmt.Sprintf("Sent HTTP close request: %s", closeReq.Method))
+}
+
+func newHttpMethodValidator() validator.String {
+	return stringvalidator.OneOf(
+		http.MethodConnect,
+		http.MethodDelete,
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodOptions,
+		http.MethodPatch,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodTrace,
+	)
+}
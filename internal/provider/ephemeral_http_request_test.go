@@ -1,14 +1,19 @@
 package provider
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
 	"github.com/hashicorp/terraform-plugin-testing/statecheck"
@@ -401,3 +406,741 @@ resource "echo" "this" {}
 		},
 	})
 }
+
+func TestEphemeralHttpRequestRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every open of the resource succeeds on its third attempt.
+		if requests.Add(1)%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`ze-body`)) // nolint:errcheck
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url        = "%s"
+	request_method     = "GET"
+	retry_max_attempts = 5
+	retry_min_delay    = "1ms"
+	retry_max_delay    = "10ms"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_body"),
+						knownvalue.StringExact("ze-body"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_status_code"),
+						knownvalue.Int32Exact(http.StatusOK),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("attempts"),
+						knownvalue.Int64Exact(3),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestRetryExhausted(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url           = "%s"
+	request_method        = "GET"
+	retry_max_attempts    = 2
+	retry_min_delay       = "1ms"
+	retry_on_status_codes = [429]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_status_code"),
+						knownvalue.Int32Exact(http.StatusTooManyRequests),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("attempts"),
+						knownvalue.Int64Exact(2),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestRetryTimeoutContinueOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url        = "%s"
+	request_method     = "GET"
+	timeout            = "100ms"
+	retry_max_attempts = 5
+	retry_min_delay    = "1ms"
+	continue_on_error  = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("attempts"),
+						knownvalue.Int64Exact(1),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringRegexp(regexp.MustCompile(`context deadline exceeded`)),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestRetryInvalid(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_http_request" "this" {
+	request_url     = "http://127.0.0.1:1"
+	request_method  = "GET"
+	retry_min_delay = "ze-invalid"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Retry Configuration`),
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestExpectedStatusCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"ze-error","access_token":"ze-token"}`)) // nolint:errcheck
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url           = "%s"
+	request_method        = "GET"
+	expected_status_codes = ["2xx", "304"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ExpectError: regexp.MustCompile(`(?s)Unexpected HTTP status code.*unexpected status code 401, expected 2xx,.*304.*ze-error.*\[REDACTED\]`),
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestExpectedStatusCodesContinueOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`ze-body`)) // nolint:errcheck
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url           = "%s"
+	request_method        = "GET"
+	expected_status_codes = ["200"]
+	continue_on_error     = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_status_code"),
+						knownvalue.Int32Exact(http.StatusInternalServerError),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact(`unexpected status code 500, expected 200, response body: "ze-body"`),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestExpectedStatusCodesInvalid(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_http_request" "this" {
+	request_url           = "http://127.0.0.1:1"
+	request_method        = "GET"
+	expected_status_codes = ["2xy"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Attribute Value Match`),
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestResponseJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"value": {"token": "ze-token", "expires_in": 3600}, "items": [{"name": "ze-first"}, {"name": "ze-last"}]}`)) // nolint:errcheck
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s"
+	request_method = "GET"
+	response_json_paths = {
+		token      = "$.value.token"
+		expires_in = "value.expires_in"
+		last       = "$.items[-1].name"
+	}
+	decode_response_json = true
+}
+
+provider "echo" {
+  data = {
+    response_values = ephemeral.azidentity_http_request.this.response_values
+    first_name      = ephemeral.azidentity_http_request.this.response_json.items[0].name
+  }
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_values"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"token":      knownvalue.StringExact("ze-token"),
+							"expires_in": knownvalue.StringExact("3600"),
+							"last":       knownvalue.StringExact("ze-last"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("first_name"),
+						knownvalue.StringExact("ze-first"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestResponseJSONNotDecoded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"value": "ze-value"}`)) // nolint:errcheck
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s"
+	request_method = "GET"
+	response_json_paths = {
+		value = "$.value"
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_json"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_values"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"value": knownvalue.StringExact("ze-value"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestResponseJSONNotJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`ze-body`)) // nolint:errcheck
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url          = "%s"
+	request_method       = "GET"
+	decode_response_json = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_json"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_values"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestResponseJSONPathsFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"value": "ze-value"}`)) // nolint:errcheck
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s"
+	request_method = "GET"
+	response_json_paths = {
+		value   = "$.value"
+		missing = "$.missing"
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ExpectError: regexp.MustCompile(`(?s)Failed to extract JSON value.*missing = "\$.missing".*\$ has no key "missing"`),
+			},
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url       = "%s"
+	request_method    = "GET"
+	continue_on_error = true
+	response_json_paths = {
+		value   = "$.value"
+		missing = "$.missing"
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact(`failed to extract response_json_paths["missing"]: $ has no key "missing"`),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_values"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"value": knownvalue.StringExact("ze-value"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestClose(t *testing.T) {
+	var opens, closes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			opens.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "ze-id"}`)) // nolint:errcheck
+		case http.MethodDelete:
+			reqBody, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("failed to read request body: %v", err)
+			}
+
+			if r.URL.Path != "/ze-path" || r.Header.Get("X-Ze-Header") != "ze-header" || string(reqBody) != "ze-body" {
+				t.Errorf("unexpected close request to %s with header %q and body %q", r.URL.Path, r.Header.Get("X-Ze-Header"), reqBody)
+			}
+
+			closes.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s/ze-path"
+	request_method = "POST"
+
+	close_request = {
+		method = "DELETE"
+		headers = {
+			"X-Ze-Header" = "ze-header"
+		}
+		body = "ze-body"
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this.response_status_code
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data"),
+						knownvalue.Int32Exact(http.StatusCreated),
+					),
+				},
+			},
+		},
+	})
+
+	if opens.Load() == 0 || opens.Load() != closes.Load() {
+		t.Errorf("expected a close request for every request, got %d requests and %d close requests", opens.Load(), closes.Load())
+	}
+}
+
+func TestEphemeralHttpRequestCloseFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	s := testNewEphemeralServer(t, testNewTestCredentialFn(t), nil)
+	typeName := "azidentity_http_request"
+
+	cases := []struct {
+		name          string
+		url           string
+		expectedError string
+	}{
+		{
+			name:          "status_code",
+			url:           server.URL,
+			expectedError: "unexpected status code 500",
+		},
+		{
+			name:          "unreachable",
+			url:           "http://127.0.0.1:1",
+			expectedError: "http://127.0.0.1:1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			openResp, _ := s.open(typeName, map[string]tftypes.Value{
+				"request_url":    tftypes.NewValue(tftypes.String, server.URL),
+				"request_method": tftypes.NewValue(tftypes.String, http.MethodPost),
+				"close_request":  testHttpCloseRequestValue(http.MethodDelete, &c.url, nil),
+			})
+
+			closeResp := s.close(typeName, openResp.Private)
+			if len(closeResp.Diagnostics) != 1 {
+				t.Fatalf("expected a single diagnostic, got %d", len(closeResp.Diagnostics))
+			}
+
+			diag := closeResp.Diagnostics[0]
+			if diag.Severity != tfprotov6.DiagnosticSeverityWarning || !strings.Contains(diag.Detail, c.expectedError) {
+				t.Errorf("expected a warning containing %q, got %s: %s: %s", c.expectedError, diag.Severity, diag.Summary, diag.Detail)
+			}
+		})
+	}
+}
+
+// testHttpCloseRequestValue returns a close_request value of
+// azidentity_http_request, with the given headers.
+func testHttpCloseRequestValue(method string, url *string, headers map[string]string) tftypes.Value {
+	closeRequestType := tftypes.Object{AttributeTypes: map[string]tftypes.Type{
+		"url":     tftypes.String,
+		"method":  tftypes.String,
+		"headers": tftypes.Map{ElementType: tftypes.String},
+		"body":    tftypes.String,
+	}}
+
+	headersValue := tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil)
+	if headers != nil {
+		values := map[string]tftypes.Value{}
+		for k, v := range headers {
+			values[k] = tftypes.NewValue(tftypes.String, v)
+		}
+		headersValue = tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, values)
+	}
+
+	var urlValue any
+	if url != nil {
+		urlValue = *url
+	}
+
+	return tftypes.NewValue(closeRequestType, map[string]tftypes.Value{
+		"url":     tftypes.NewValue(tftypes.String, urlValue),
+		"method":  tftypes.NewValue(tftypes.String, method),
+		"headers": headersValue,
+		"body":    tftypes.NewValue(tftypes.String, nil),
+	})
+}
+
+func TestEphemeralHttpRequestClosePrivateData(t *testing.T) {
+	var closes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			if r.Header.Get("X-Ze-Api-Key") != "ze-api-key" {
+				t.Errorf("unexpected X-Ze-Api-Key header %q", r.Header.Get("X-Ze-Api-Key"))
+			}
+			closes.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	s := testNewEphemeralServer(t, testNewTestCredentialFn(t), nil)
+	typeName := "azidentity_http_request"
+	closeURL := server.URL + "/ze-secret-path"
+	openResp, _ := s.open(typeName, map[string]tftypes.Value{
+		"request_url":    tftypes.NewValue(tftypes.String, server.URL),
+		"request_method": tftypes.NewValue(tftypes.String, http.MethodPost),
+		"close_request":  testHttpCloseRequestValue(http.MethodDelete, &closeURL, map[string]string{"X-Ze-Api-Key": "ze-api-key"}),
+	})
+
+	for _, secret := range []string{"ze-api-key", "ze-secret-path"} {
+		if bytes.Contains(openResp.Private, []byte(secret)) {
+			t.Errorf("expected the private data not to contain %q", secret)
+		}
+	}
+
+	// A restarted provider no longer has the close request.
+	restarted := testNewEphemeralServer(t, testNewTestCredentialFn(t), nil)
+	closeResp := restarted.close(typeName, openResp.Private)
+	if len(closeResp.Diagnostics) != 1 || closeResp.Diagnostics[0].Severity != tfprotov6.DiagnosticSeverityWarning || closeResp.Diagnostics[0].Summary != "HTTP close request not sent" {
+		t.Errorf("expected a warning for the lost close request, got %v", closeResp.Diagnostics)
+	}
+
+	closeResp = s.close(typeName, openResp.Private)
+	testRequireNoDiagnostics(t, closeResp.Diagnostics)
+
+	if closes.Load() != 1 {
+		t.Errorf("expected 1 close request, got %d", closes.Load())
+	}
+}
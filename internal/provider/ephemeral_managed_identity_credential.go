@@ -0,0 +1,223 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ ephemeral.EphemeralResource = &ephemeralManagedIdentityCredential{}
+var _ ephemeral.EphemeralResourceWithRenew = &ephemeralManagedIdentityCredential{}
+var _ ephemeral.EphemeralResourceWithClose = &ephemeralManagedIdentityCredential{}
+
+func newEphemeralManagedIdentityCredential() ephemeral.EphemeralResource {
+	return &ephemeralManagedIdentityCredential{}
+}
+
+type ephemeralManagedIdentityCredential struct {
+	getCredFn  getCredentialFn
+	defaults   providerDefaults
+	httpClient *http.Client
+	tokenCache *tokenCache
+}
+
+type ephemeralManagedIdentityCredentialModel struct {
+	ClientID         types.String `tfsdk:"client_id"`
+	ObjectID         types.String `tfsdk:"object_id"`
+	ResourceID       types.String `tfsdk:"resource_id"`
+	Scopes           types.Set    `tfsdk:"scopes"`
+	ContinueOnError  types.Bool   `tfsdk:"continue_on_error"`
+	Timeout          types.String `tfsdk:"timeout"`
+	MaxRetries       types.Int64  `tfsdk:"max_retries"`
+	RetryDelay       types.String `tfsdk:"retry_delay"`
+	MaxRetryDelay    types.String `tfsdk:"max_retry_delay"`
+	RetryStatusCodes types.Set    `tfsdk:"retry_status_codes"`
+	tokenModel
+	CacheHit types.Bool   `tfsdk:"cache_hit"`
+	Success  types.Bool   `tfsdk:"success"`
+	Error    types.String `tfsdk:"error"`
+}
+
+func (r *ephemeralManagedIdentityCredentialModel) newCredentialConfig(ctx context.Context, defaults providerDefaults) (credentialConfig, error) {
+	retry, err := defaults.retryConfig(ctx, r.MaxRetries, r.RetryDelay, r.MaxRetryDelay, r.RetryStatusCodes)
+	if err != nil {
+		return credentialConfig{}, err
+	}
+
+	return credentialConfig{
+		ClientID:        r.ClientID.ValueString(),
+		ObjectID:        r.ObjectID.ValueString(),
+		ResourceID:      r.ResourceID.ValueString(),
+		Scopes:          typesSetToStringSlice(r.Scopes),
+		ContinueOnError: r.ContinueOnError.ValueBool(),
+		Timeout:         parseTimeout(ctx, defaults.timeout(ctx, r.Timeout)),
+		Retry:           retry,
+	}, nil
+}
+
+func (r *ephemeralManagedIdentityCredential) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_managed_identity_credential"
+}
+
+func (r *ephemeralManagedIdentityCredential) Schema(ctx context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "The `azidentity_managed_identity_credential` resource authenticates an **Azure managed identity** in any hosting environment supporting managed identities, such as Azure VMs, App Service, Container Apps and AKS. It authenticates the system-assigned identity by default, set one of `client_id`, `object_id` or `resource_id` to use a user-assigned identity instead.",
+		Attributes: withTokenSchemaAttributes(map[string]schema.Attribute{
+			"client_id": schema.StringAttribute{
+				MarkdownDescription: "ClientID is the client ID of a user-assigned managed identity. Conflicts with `object_id` and `resource_id`. The default is empty, which selects the system-assigned identity.",
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(
+						path.MatchRelative().AtParent().AtName("object_id"),
+						path.MatchRelative().AtParent().AtName("resource_id"),
+					),
+				},
+			},
+			"object_id": schema.StringAttribute{
+				MarkdownDescription: "ObjectID is the object ID of a user-assigned managed identity. Conflicts with `client_id` and `resource_id`. The default is empty, which selects the system-assigned identity.",
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(
+						path.MatchRelative().AtParent().AtName("client_id"),
+						path.MatchRelative().AtParent().AtName("resource_id"),
+					),
+				},
+			},
+			"resource_id": schema.StringAttribute{
+				MarkdownDescription: "ResourceID is the Azure resource ID of a user-assigned managed identity. Conflicts with `client_id` and `object_id`. The default is empty, which selects the system-assigned identity.",
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(
+						path.MatchRelative().AtParent().AtName("client_id"),
+						path.MatchRelative().AtParent().AtName("object_id"),
+					),
+				},
+			},
+			"scopes": schema.SetAttribute{
+				MarkdownDescription: "Scopes contains the permission scope required for the token. Managed identity endpoints only support a single scope. E.g. https://management.azure.com/.default for Azure Resource Manager or https://graph.microsoft.com/.default for Microsoft Graph.",
+				Required:            true,
+				ElementType:         types.StringType,
+				Validators: []validator.Set{
+					setvalidator.SizeBetween(1, 1),
+				},
+			},
+			"continue_on_error": schema.BoolAttribute{
+				MarkdownDescription: "ContinueOnError indicates whether to continue on error when acquiring a token. The default is false.",
+				Optional:            true,
+			},
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout sets the maximum time allowed for the request to complete, the string is a possibly signed sequence of decimal numbers, each with optional fraction and a unit suffix, such as '300ms', '1.5h' or '2h45m'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'. The default is the provider `timeout`, or 30 seconds ('30s').",
+				Optional:            true,
+			},
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "MaxRetries is the maximum number of times a failed HTTP request of the credential is retried, 0 disables retries. Retries are bounded by the timeout. The default is the provider `max_retries`, or 6.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+				},
+			},
+			"retry_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryDelay is the initial delay before retrying a failed HTTP request, increasing exponentially with each retry up to MaxRetryDelay. It's only used when the response doesn't contain a Retry-After header. The default is the provider `retry_delay`, or '2s'.",
+				Optional:            true,
+			},
+			"max_retry_delay": schema.StringAttribute{
+				MarkdownDescription: "MaxRetryDelay is the maximum delay before retrying a failed HTTP request. The default is the provider `max_retry_delay`, or '25s'.",
+				Optional:            true,
+			},
+			"retry_status_codes": schema.SetAttribute{
+				MarkdownDescription: "RetryStatusCodes are the HTTP status codes retried, an empty set only retries on network errors. The default is the provider `retry_status_codes`, or 404, 410, 429 and 5xx.",
+				Optional:            true,
+				ElementType:         types.Int64Type,
+				Validators: []validator.Set{
+					setvalidator.ValueInt64sAre(int64validator.Between(100, 599)),
+				},
+			},
+			"cache_hit": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if the access token was reused from the token cache of the provider instead of being requested.",
+				Computed:            true,
+			},
+			"success": schema.BoolAttribute{
+				MarkdownDescription: "Indicates if a token was successfully acquired.",
+				Computed:            true,
+			},
+			"error": schema.StringAttribute{
+				MarkdownDescription: "Error message if acquiring a token failed.",
+				Computed:            true,
+			},
+		}),
+	}
+}
+
+func (p *ephemeralManagedIdentityCredential) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*azidentityProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected ProviderData Type",
+			fmt.Sprintf("Expected *azidentityProvider, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+
+	p.getCredFn = provider.getCredFn
+	p.tokenCache = provider.tokenCache
+	p.defaults = provider.defaults
+	p.httpClient = provider.httpClient
+}
+
+func (r *ephemeralManagedIdentityCredential) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data ephemeralManagedIdentityCredentialModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	cfg, err := data.newCredentialConfig(ctx, r.defaults)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Credential Configuration", err.Error())
+		return
+	}
+
+	cfg.HTTPClient = r.httpClient
+	token, cacheHit, errSummary, err := getCachedToken(ctx, r.tokenCache, managedIdentityCredential, r.getCredFn, cfg)
+	if err != nil && cfg.ContinueOnError {
+		data.Error = types.StringValue(err.Error())
+		data.Success = types.BoolValue(false)
+		resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError(errSummary, err.Error())
+		return
+	}
+
+	data.setToken(token)
+	data.CacheHit = types.BoolValue(cacheHit)
+	data.Success = types.BoolValue(true)
+
+	resp.Diagnostics.Append(setCredentialRenewState(ctx, resp, r.tokenCache, managedIdentityCredential, cfg, r.defaults.RenewMargin, token)...)
+
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *ephemeralManagedIdentityCredential) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	renewCredential(ctx, req, resp, r.tokenCache)
+}
+
+func (r *ephemeralManagedIdentityCredential) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	closeCredential(ctx, req, resp, r.tokenCache)
+}
@@ -0,0 +1,351 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralManagedIdentityCredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralManagedIdentityCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("object_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("resource_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("scopes"),
+						knownvalue.SetExact([]knownvalue.Check{
+							knownvalue.StringExact("ze-scope-1"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralManagedIdentityCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralManagedIdentityCredentialConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.StringExact("ze-client"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.StringExact("1s"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralManagedIdentityCredentialConflictingIDs(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_managed_identity_credential" "this" {
+	client_id = "ze-client"
+	object_id = "ze-object"
+	scopes    = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_managed_identity_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Attribute Combination`),
+			},
+		},
+	})
+}
+
+func TestEphemeralManagedIdentityCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralManagedIdentityCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralManagedIdentityCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_managed_identity_credential" "this" {
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_managed_identity_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralManagedIdentityCredentialIMDS(t *testing.T) {
+	cases := []struct {
+		name          string
+		identityAttr  string
+		queryParam    string
+		expectedValue string
+	}{
+		{
+			name: "system-assigned",
+		},
+		{
+			name:          "client_id",
+			identityAttr:  `client_id = "ze-client"`,
+			queryParam:    "client_id",
+			expectedValue: "ze-client",
+		},
+		{
+			name:          "object_id",
+			identityAttr:  `object_id = "ze-object"`,
+			queryParam:    "object_id",
+			expectedValue: "ze-object",
+		},
+		{
+			name:          "resource_id",
+			identityAttr:  `resource_id = "ze-resource"`,
+			queryParam:    "msi_res_id",
+			expectedValue: "ze-resource",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/metadata/identity/oauth2/token" {
+					t.Errorf("unexpected path %q", r.URL.Path)
+				}
+
+				if r.Header.Get("Metadata") != "true" {
+					t.Errorf("expected Metadata header to be true, got %q", r.Header.Get("Metadata"))
+				}
+
+				if r.URL.Query().Get("resource") != "https://ze-resource.example.com" {
+					t.Errorf("unexpected resource %q", r.URL.Query().Get("resource"))
+				}
+
+				for _, param := range []string{"client_id", "object_id", "msi_res_id"} {
+					expected := ""
+					if param == c.queryParam {
+						expected = c.expectedValue
+					}
+
+					if r.URL.Query().Get(param) != expected {
+						t.Errorf("expected query parameter %q to be %q, got %q", param, expected, r.URL.Query().Get(param))
+					}
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"access_token":"ze-imds-token","expires_on":"1641092645","resource":"https://ze-resource.example.com","token_type":"Bearer"}`)) // nolint:errcheck
+			}))
+
+			defer server.Close()
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+ephemeral "azidentity_managed_identity_credential" "this" {
+	%s
+	scopes = ["https://ze-resource.example.com/.default"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_managed_identity_credential.this
+}
+
+resource "echo" "this" {}
+`, c.identityAttr),
+						ConfigStateChecks: []statecheck.StateCheck{
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("access_token"),
+								knownvalue.StringExact("ze-imds-token"),
+							),
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("expires_on"),
+								knownvalue.StringExact("2022-01-02T03:04:05Z"),
+							),
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("success"),
+								knownvalue.Bool(true),
+							),
+						},
+					},
+				},
+			})
+		})
+	}
+}
+
+func testEphemeralManagedIdentityCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_managed_identity_credential" "this" {
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_managed_identity_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+func testEphemeralManagedIdentityCredentialConfig() string {
+	return `
+ephemeral "azidentity_managed_identity_credential" "this" {
+	client_id         = "ze-client"
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+	timeout           = "1s"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_managed_identity_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
@@ -0,0 +1,310 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralOnBehalfOfCredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralOnBehalfOfCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("certificate"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_assertion"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralOnBehalfOfCredentialMissingClientCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_on_behalf_of_credential" "this" {
+	tenant_id      = "ze-tenant"
+	client_id      = "ze-client"
+	user_assertion = "ze-user-assertion"
+	scopes         = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_on_behalf_of_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Attribute Combination`),
+			},
+		},
+	})
+}
+
+func TestEphemeralOnBehalfOfCredentialConflictingClientCredentials(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_on_behalf_of_credential" "this" {
+	tenant_id        = "ze-tenant"
+	client_id        = "ze-client"
+	user_assertion   = "ze-user-assertion"
+	client_secret    = "ze-secret"
+	client_assertion = "ze-client-assertion"
+	scopes           = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_on_behalf_of_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Attribute Combination`),
+			},
+		},
+	})
+}
+
+func TestEphemeralOnBehalfOfCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralOnBehalfOfCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralOnBehalfOfCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_on_behalf_of_credential" "this" {
+	tenant_id         = "ze-tenant"
+	client_id         = "ze-client"
+	user_assertion    = "ze-user-assertion"
+	client_secret     = "ze-secret"
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_on_behalf_of_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralOnBehalfOfCredentialLocalAuthority(t *testing.T) {
+	certificatePEM := testNewCertificatePEM(t, false)
+
+	cases := []struct {
+		name              string
+		clientCredential  string
+		checkTokenRequest func(t *testing.T, r *http.Request)
+	}{
+		{
+			name:             "client_secret",
+			clientCredential: `client_secret = "ze-secret"`,
+			checkTokenRequest: func(t *testing.T, r *http.Request) {
+				if r.PostForm.Get("client_secret") != "ze-secret" {
+					t.Errorf("unexpected client_secret %q", r.PostForm.Get("client_secret"))
+				}
+			},
+		},
+		{
+			name:             "certificate",
+			clientCredential: fmt.Sprintf(`certificate = %q`, certificatePEM),
+			checkTokenRequest: func(t *testing.T, r *http.Request) {
+				header := testParseJWTHeader(t, r.PostForm.Get("client_assertion"))
+				if _, ok := header["x5t#S256"]; !ok {
+					t.Errorf("expected client assertion header to contain x5t#S256, got %v", header)
+				}
+			},
+		},
+		{
+			name:             "client_assertion",
+			clientCredential: `client_assertion = "ze-client-assertion"`,
+			checkTokenRequest: func(t *testing.T, r *http.Request) {
+				if r.PostForm.Get("client_assertion") != "ze-client-assertion" {
+					t.Errorf("unexpected client_assertion %q", r.PostForm.Get("client_assertion"))
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := testNewEntraAuthorityServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/ze-tenant/oauth2/v2.0/token" {
+					t.Errorf("unexpected token path %q", r.URL.Path)
+				}
+
+				if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+					t.Errorf("unexpected grant_type %q", r.PostForm.Get("grant_type"))
+				}
+
+				if r.PostForm.Get("requested_token_use") != "on_behalf_of" {
+					t.Errorf("unexpected requested_token_use %q", r.PostForm.Get("requested_token_use"))
+				}
+
+				if r.PostForm.Get("assertion") != "ze-user-assertion" {
+					t.Errorf("expected assertion to be the user assertion, got %q", r.PostForm.Get("assertion"))
+				}
+
+				c.checkTokenRequest(t, r)
+				testEntraTokenResponse(w, "ze-obo-token")
+			})
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), server.Client()),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+provider "azidentity" {
+	active_directory_authority_host = %[2]q
+	disable_instance_discovery      = true
+}
+
+ephemeral "azidentity_on_behalf_of_credential" "this" {
+	tenant_id      = "ze-tenant"
+	client_id      = "ze-client"
+	user_assertion = "ze-user-assertion"
+	%[1]s
+	scopes         = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_on_behalf_of_credential.this
+}
+
+resource "echo" "this" {}
+`, c.clientCredential, server.URL),
+						ConfigStateChecks: []statecheck.StateCheck{
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("access_token"),
+								knownvalue.StringExact("ze-obo-token"),
+							),
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data").AtMapKey("success"),
+								knownvalue.Bool(true),
+							),
+						},
+					},
+				},
+			})
+		})
+	}
+}
+
+func testEphemeralOnBehalfOfCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_on_behalf_of_credential" "this" {
+	tenant_id      = "ze-tenant"
+	client_id      = "ze-client"
+	user_assertion = "ze-user-assertion"
+	client_secret  = "ze-secret"
+	scopes         = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_on_behalf_of_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralUsernamePasswordCredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralUsernamePasswordCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.StringExact("ze-tenant"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.StringExact("ze-client"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("username"),
+						knownvalue.StringExact("ze-user@example.com"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("password"),
+						knownvalue.StringExact("ze-password"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralUsernamePasswordCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralUsernamePasswordCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralUsernamePasswordCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_username_password_credential" "this" {
+	tenant_id         = "ze-tenant"
+	client_id         = "ze-client"
+	username          = "ze-user@example.com"
+	password          = "ze-password"
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_username_password_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralUsernamePasswordCredentialROPC(t *testing.T) {
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ze-tenant/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path %q", r.URL.Path)
+		}
+
+		for param, expected := range map[string]string{
+			"grant_type": "password",
+			"client_id":  "ze-client",
+			"username":   "ze-user@example.com",
+			"password":   "ze-password",
+		} {
+			if r.PostForm.Get(param) != expected {
+				t.Errorf("expected %s to be %q, got %q", param, expected, r.PostForm.Get(param))
+			}
+		}
+
+		testEntraTokenResponse(w, "ze-ropc-token")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralUsernamePasswordCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-ropc-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralUsernamePasswordCredentialDeprecationWarning(t *testing.T) {
+	ctx := context.Background()
+	r := &ephemeralUsernamePasswordCredential{getCredFn: testNewTestCredentialFn(t)}
+
+	schemaResp := &ephemeral.SchemaResponse{}
+	r.Schema(ctx, ephemeral.SchemaRequest{}, schemaResp)
+	objectType, ok := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	if !ok {
+		t.Fatalf("expected schema to be an object type")
+	}
+
+	values := map[string]tftypes.Value{}
+	for name, attrType := range objectType.AttributeTypes {
+		values[name] = tftypes.NewValue(attrType, nil)
+	}
+	values["tenant_id"] = tftypes.NewValue(tftypes.String, "ze-tenant")
+	values["client_id"] = tftypes.NewValue(tftypes.String, "ze-client")
+	values["username"] = tftypes.NewValue(tftypes.String, "ze-user@example.com")
+	values["password"] = tftypes.NewValue(tftypes.String, "ze-password")
+	values["scopes"] = tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{tftypes.NewValue(tftypes.String, "ze-scope-1")})
+
+	// Every open is expected to warn, not just the first one.
+	for i := range 2 {
+		req := ephemeral.OpenRequest{
+			Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objectType, values)},
+		}
+		resp := &ephemeral.OpenResponse{
+			Result: tfsdk.EphemeralResultData{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objectType, nil)},
+		}
+		r.Open(ctx, req, resp)
+
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("open %d: unexpected error: %v", i, resp.Diagnostics.Errors())
+		}
+
+		warnings := resp.Diagnostics.Warnings()
+		if len(warnings) != 1 {
+			t.Fatalf("open %d: expected 1 warning, got %d", i, len(warnings))
+		}
+
+		if warnings[0].Summary() != "Deprecated Authentication Flow" || !strings.Contains(warnings[0].Detail(), "ROPC") {
+			t.Errorf("open %d: unexpected warning %q: %q", i, warnings[0].Summary(), warnings[0].Detail())
+		}
+	}
+}
+
+func testEphemeralUsernamePasswordCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_username_password_credential" "this" {
+	tenant_id = "ze-tenant"
+	client_id = "ze-client"
+	username  = "ze-user@example.com"
+	password  = "ze-password"
+	scopes    = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_username_password_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
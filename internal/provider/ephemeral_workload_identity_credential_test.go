@@ -0,0 +1,380 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralWorkloadIdentityCredentialEmpty(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralWorkloadIdentityCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("expires_on"),
+						knownvalue.StringExact("2022-01-02T03:04:05Z"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("token_file_path"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("scopes"),
+						knownvalue.SetExact([]knownvalue.Check{
+							knownvalue.StringExact("ze-scope-1"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralWorkloadIdentityCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralWorkloadIdentityCredentialConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tenant_id"),
+						knownvalue.StringExact("ze-tenant"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("client_id"),
+						knownvalue.StringExact("ze-client"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("token_file_path"),
+						knownvalue.StringExact("/ze/token/file"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("additionally_allowed_tenants"),
+						knownvalue.SetExact([]knownvalue.Check{
+							knownvalue.StringExact("ze-additional-tenant-1"),
+							knownvalue.StringExact("ze-additional-tenant-2"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("claims"),
+						knownvalue.StringExact("ze-claims"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("enable_cae"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("continue_on_error"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("timeout"),
+						knownvalue.StringExact("1s"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralWorkloadIdentityCredentialFailGetCredential(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewGetCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config:      testEphemeralWorkloadIdentityCredentialEmptyConfig(),
+				ExpectError: regexp.MustCompile(`ze-get-credential-fn-error`),
+			},
+		},
+	})
+}
+
+func TestEphemeralWorkloadIdentityCredentialFailGetToken(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_workload_identity_credential" "this" {
+	scopes            = ["ze-scope-1"]
+	continue_on_error = true
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_workload_identity_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringExact("ze-get-token-error"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralWorkloadIdentityCredentialTokenFile(t *testing.T) {
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	err := os.WriteFile(tokenFilePath, []byte("ze-federated-token\n"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ze-tenant/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path %q", r.URL.Path)
+		}
+
+		if r.PostForm.Get("client_id") != "ze-client" {
+			t.Errorf("expected client_id to be ze-client, got %q", r.PostForm.Get("client_id"))
+		}
+
+		if r.PostForm.Get("client_assertion") != "ze-federated-token" {
+			t.Errorf("expected client_assertion to be ze-federated-token, got %q", r.PostForm.Get("client_assertion"))
+		}
+
+		testEntraTokenResponse(w, "ze-workload-token")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_workload_identity_credential" "this" {
+	tenant_id       = "ze-tenant"
+	client_id       = "ze-client"
+	token_file_path = %q
+	scopes          = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_workload_identity_credential.this
+}
+
+resource "echo" "this" {}
+`, tokenFilePath),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-workload-token"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(true),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralWorkloadIdentityCredentialEnvironmentDefaults(t *testing.T) {
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	err := os.WriteFile(tokenFilePath, []byte("ze-env-federated-token"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+
+	t.Setenv("AZURE_TENANT_ID", "ze-env-tenant")
+	t.Setenv("AZURE_CLIENT_ID", "ze-env-client")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFilePath)
+
+	server := testNewEntraServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ze-env-tenant/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path %q", r.URL.Path)
+		}
+
+		if r.PostForm.Get("client_id") != "ze-env-client" {
+			t.Errorf("expected client_id to be ze-env-client, got %q", r.PostForm.Get("client_id"))
+		}
+
+		if r.PostForm.Get("client_assertion") != "ze-env-federated-token" {
+			t.Errorf("expected client_assertion to be ze-env-federated-token, got %q", r.PostForm.Get("client_assertion"))
+		}
+
+		testEntraTokenResponse(w, "ze-workload-token")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), testNewRedirectHttpClient(t, server.URL)),
+		Steps: []resource.TestStep{
+			{
+				Config: testEphemeralWorkloadIdentityCredentialEmptyConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-workload-token"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralWorkloadIdentityCredentialMissingTokenFile(t *testing.T) {
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_workload_identity_credential" "this" {
+	tenant_id = "ze-tenant"
+	client_id = "ze-client"
+	scopes    = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_workload_identity_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`no token file specified`),
+			},
+		},
+	})
+}
+
+func testEphemeralWorkloadIdentityCredentialEmptyConfig() string {
+	return `
+ephemeral "azidentity_workload_identity_credential" "this" {
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_workload_identity_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
+
+func testEphemeralWorkloadIdentityCredentialConfig() string {
+	return `
+ephemeral "azidentity_workload_identity_credential" "this" {
+	tenant_id                    = "ze-tenant"
+	client_id                    = "ze-client"
+	token_file_path              = "/ze/token/file"
+	additionally_allowed_tenants = ["ze-additional-tenant-1", "ze-additional-tenant-2"]
+	claims                       = "ze-claims"
+	enable_cae                   = true
+	scopes                       = ["ze-scope-1", "ze-scope-2"]
+	continue_on_error            = true
+	timeout                      = "1s"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_workload_identity_credential.this
+}
+
+resource "echo" "this" {}
+`
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// httpAzureAuthPrivateKey is the private data key of the token cache entries
+// held by azure_auth.
+const httpAzureAuthPrivateKey = "azure_auth"
+
+// claimsChallengePattern matches the claims parameter of a WWW-Authenticate
+// header.
+var claimsChallengePattern = regexp.MustCompile(`(?i)\bclaims="([^"]*)"`)
+
+// httpAzureAuth authenticates the requests of azidentity_http_request with a
+// bearer token of one of the credentials.
+type httpAzureAuth struct {
+	credType credentialType
+	cfg      credentialConfig
+	// cacheKeys are the keys of the token cache entries held by the tokens of
+	// the requests, until the resource is closed.
+	cacheKeys []string
+}
+
+// httpAzureAuthState is stashed in the private data by Open, so Close releases
+// the token cache entries held by azure_auth. It only holds their keys.
+type httpAzureAuthState struct {
+	CacheKeys []string `json:"cache_keys"`
+}
+
+// newHttpAzureAuth returns the credential configured by azure_auth, using the
+// provider defaults as the credential resources do.
+func newHttpAzureAuth(ctx context.Context, value types.Object, defaults providerDefaults) (*httpAzureAuth, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	attributes := value.Attributes()
+
+	for _, sourceType := range chainedCredentialSourceTypes {
+		source, ok := attributes[sourceType.name].(types.Object)
+		if !ok || source.IsNull() {
+			continue
+		}
+
+		cfg, sourceDiags := newChainedCredentialSourceConfig(ctx, sourceType, source, defaults)
+		diags.Append(sourceDiags...)
+		if diags.HasError() {
+			return nil, diags
+		}
+
+		if sourceType.credType == usernamePasswordCredential {
+			addUsernamePasswordWarning(&diags, "The username_password credential of azure_auth")
+		}
+
+		scopes, _ := attributes["scopes"].(types.Set)
+		enableCAE, _ := attributes["enable_cae"].(types.Bool)
+		cfg.Scopes = typesSetToStringSlice(scopes)
+		cfg.EnableCAE = enableCAE.ValueBool()
+
+		return &httpAzureAuth{credType: sourceType.credType, cfg: cfg}, diags
+	}
+
+	diags.AddError("Invalid Credential Configuration", "azure_auth has to configure exactly one credential.")
+	return nil, diags
+}
+
+// do sends the request built by newRequest with a bearer token of the
+// credential, acquired through the token cache of the provider. A response
+// with a claims challenge is retried once, with a token satisfying the claims.
+// It returns the number of attempts of both requests.
+func (a *httpAzureAuth) do(ctx context.Context, cache *tokenCache, getCredFn getCredentialFn, retry httpRetryConfig, httpClient *http.Client, newRequest func() (*http.Request, error)) (*http.Response, []byte, int, string, error) {
+	cfg := a.cfg
+	attempts := 0
+	for {
+		token, _, errSummary, err := getCachedToken(ctx, cache, a.credType, getCredFn, cfg)
+		if err != nil {
+			return nil, nil, attempts, errSummary, err
+		}
+
+		if cache != nil {
+			key, err := newTokenCacheKey(a.credType, cfg)
+			if err != nil {
+				return nil, nil, attempts, "Error creating token cache key", err
+			}
+
+			a.cacheKeys = append(a.cacheKeys, key)
+		}
+
+		httpRes, resBody, n, errSummary, err := retry.do(ctx, httpClient, func() (*http.Request, error) {
+			httpReq, err := newRequest()
+			if err != nil {
+				return nil, err
+			}
+
+			httpReq.Header.Set("Authorization", tokenTypeBearer+" "+token.Token)
+
+			return httpReq, nil
+		})
+		attempts += n
+		if err != nil || cfg.Claims != "" {
+			return httpRes, resBody, attempts, errSummary, err
+		}
+
+		claims, ok := parseClaimsChallenge(httpRes)
+		if !ok {
+			return httpRes, resBody, attempts, errSummary, err
+		}
+
+		tflog.Debug(ctx, "Retrying HTTP request with a token satisfying the claims challenge of the response")
+		cfg.Claims = claims
+	}
+}
+
+// setPrivate stashes the keys of the token cache entries held by the requests
+// in the private data of the response, for Close to release them.
+func (a *httpAzureAuth) setPrivate(ctx context.Context, resp *ephemeral.OpenResponse) diag.Diagnostics {
+	var diags diag.Diagnostics
+	b, err := json.Marshal(httpAzureAuthState{CacheKeys: a.cacheKeys})
+	if err != nil {
+		diags.AddError("Error Storing Azure Auth State", err.Error())
+		return diags
+	}
+
+	diags.Append(resp.Private.SetKey(ctx, httpAzureAuthPrivateKey, b)...)
+
+	return diags
+}
+
+// release releases the token cache entries held by the requests.
+func (a *httpAzureAuth) release(cache *tokenCache) {
+	if cache == nil {
+		return
+	}
+
+	for _, key := range a.cacheKeys {
+		cache.release(key)
+	}
+	a.cacheKeys = nil
+}
+
+// releaseHttpAzureAuth releases the token cache entries held by the azure_auth
+// of a closed resource, as closeCredential does for the credential resources.
+func releaseHttpAzureAuth(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse, cache *tokenCache) {
+	b, diags := req.Private.GetKey(ctx, httpAzureAuthPrivateKey)
+	appendAsWarnings(&resp.Diagnostics, diags)
+	if diags.HasError() || len(b) == 0 || cache == nil {
+		return
+	}
+
+	var state httpAzureAuthState
+	err := json.Unmarshal(b, &state)
+	if err != nil {
+		resp.Diagnostics.AddWarning("Failed to read Azure auth state", err.Error())
+		return
+	}
+
+	for _, key := range state.CacheKeys {
+		cache.release(key)
+	}
+	tflog.Debug(ctx, "Released cached tokens of closed HTTP request")
+}
+
+// parseClaimsChallenge returns the claims of a 401 response challenging the
+// client to acquire a token with more claims, as sent by APIs supporting
+// Continuous Access Evaluation or enforcing conditional access policies. The
+// claims are base64 encoded in the WWW-Authenticate header.
+func parseClaimsChallenge(httpRes *http.Response) (string, bool) {
+	if httpRes.StatusCode != http.StatusUnauthorized {
+		return "", false
+	}
+
+	for _, value := range httpRes.Header.Values("WWW-Authenticate") {
+		match := claimsChallengePattern.FindStringSubmatch(value)
+		if match == nil || match[1] == "" {
+			continue
+		}
+
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			claims, err := encoding.DecodeString(match[1])
+			if err == nil {
+				return string(claims), true
+			}
+		}
+	}
+
+	return "", false
+}
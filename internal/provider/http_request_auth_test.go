@@ -0,0 +1,443 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+const testClaims = `{"access_token":{"nbf":{"essential":true,"value":"1700000000"}}}`
+
+// testClaimsCredential returns ze-claims-token for token requests with claims
+// and ze-token otherwise.
+type testClaimsCredential struct{}
+
+func (c *testClaimsCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token := "ze-token"
+	if options.Claims != "" {
+		token = "ze-claims-token"
+	}
+
+	return azcore.AccessToken{
+		Token:     token,
+		ExpiresOn: time.Now().Add(time.Hour),
+	}, nil
+}
+
+var _ azcore.TokenCredential = (*testClaimsCredential)(nil)
+
+func TestParseClaimsChallenge(t *testing.T) {
+	encodedClaims := base64.StdEncoding.EncodeToString([]byte(testClaims))
+	cases := []struct {
+		name            string
+		statusCode      int
+		wwwAuthenticate string
+		expectedClaims  string
+		expectedOk      bool
+	}{
+		{
+			name:            "claims_challenge",
+			statusCode:      http.StatusUnauthorized,
+			wwwAuthenticate: fmt.Sprintf(`Bearer realm="", authorization_uri="https://login.microsoftonline.com/common/oauth2/authorize", error="insufficient_claims", claims="%s"`, encodedClaims),
+			expectedClaims:  testClaims,
+			expectedOk:      true,
+		},
+		{
+			name:            "unpadded_claims",
+			statusCode:      http.StatusUnauthorized,
+			wwwAuthenticate: fmt.Sprintf(`Bearer error="insufficient_claims", claims="%s"`, base64.RawStdEncoding.EncodeToString([]byte(testClaims))),
+			expectedClaims:  testClaims,
+			expectedOk:      true,
+		},
+		{
+			name:            "no_claims",
+			statusCode:      http.StatusUnauthorized,
+			wwwAuthenticate: `Bearer error="invalid_token"`,
+		},
+		{
+			name:            "invalid_claims",
+			statusCode:      http.StatusUnauthorized,
+			wwwAuthenticate: `Bearer claims="ze-invalid!"`,
+		},
+		{
+			name:            "not_unauthorized",
+			statusCode:      http.StatusForbidden,
+			wwwAuthenticate: fmt.Sprintf(`Bearer claims="%s"`, encodedClaims),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			httpRes := &http.Response{StatusCode: c.statusCode, Header: http.Header{}}
+			httpRes.Header.Set("WWW-Authenticate", c.wwwAuthenticate)
+
+			claims, ok := parseClaimsChallenge(httpRes)
+			if claims != c.expectedClaims || ok != c.expectedOk {
+				t.Errorf("expected %q, %t, got %q, %t", c.expectedClaims, c.expectedOk, claims, ok)
+			}
+		})
+	}
+}
+
+func TestEphemeralHttpRequestAzureAuth(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer ze-claims-token":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`ze-body`)) // nolint:errcheck
+		case "Bearer ze-token":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_claims", claims="%s"`, base64.StdEncoding.EncodeToString([]byte(testClaims))))
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+
+	defer server.Close()
+
+	var mu sync.Mutex
+	credTypes := map[credentialType]bool{}
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		if cfg.ClientID != "ze-client" || len(cfg.Scopes) != 1 || cfg.Scopes[0] != "ze-scope" {
+			return nil, fmt.Errorf("unexpected credential config: %s, %v", cfg.ClientID, cfg.Scopes)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		credTypes[credType] = true
+
+		return &testClaimsCredential{}, nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, getCredFn),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s"
+	request_method = "GET"
+	request_headers = {
+		Authorization = "ze-replaced"
+	}
+	azure_auth = {
+		scopes = ["ze-scope"]
+		client_secret = {
+			tenant_id     = "ze-tenant"
+			client_id     = "ze-client"
+			client_secret = "ze-secret"
+		}
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_status_code"),
+						knownvalue.Int32Exact(http.StatusOK),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_body"),
+						knownvalue.StringExact("ze-body"),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("attempts"),
+						knownvalue.Int64Exact(2),
+					),
+				},
+			},
+		},
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(credTypes) != 1 || !credTypes[clientSecretCredential] {
+		t.Errorf("expected only %s to be used, got %v", clientSecretCredential, credTypes)
+	}
+}
+
+func TestEphemeralHttpRequestAzureAuthFailure(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFailureFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "http://127.0.0.1:1"
+	request_method = "GET"
+	azure_auth = {
+		scopes = ["ze-scope"]
+		client_secret = {
+			tenant_id     = "ze-tenant"
+			client_id     = "ze-client"
+			client_secret = "ze-secret"
+		}
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`ze-get-token-error`),
+			},
+			{
+				Config: `
+ephemeral "azidentity_http_request" "this" {
+	request_url       = "http://127.0.0.1:1"
+	request_method    = "GET"
+	continue_on_error = true
+	azure_auth = {
+		scopes = ["ze-scope"]
+		client_secret = {
+			tenant_id     = "ze-tenant"
+			client_id     = "ze-client"
+			client_secret = "ze-secret"
+		}
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("success"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("attempts"),
+						knownvalue.Int64Exact(0),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("error"),
+						knownvalue.StringRegexp(regexp.MustCompile(`ze-get-token-error`)),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestAzureAuthInvalid(t *testing.T) {
+	cases := []struct {
+		name      string
+		azureAuth string
+	}{
+		{
+			name: "no_credential",
+			azureAuth: `{
+		scopes = ["ze-scope"]
+	}`,
+		},
+		{
+			name: "two_credentials",
+			azureAuth: `{
+		scopes    = ["ze-scope"]
+		azure_cli = {}
+		client_secret = {
+			tenant_id     = "ze-tenant"
+			client_id     = "ze-client"
+			client_secret = "ze-secret"
+		}
+	}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "http://127.0.0.1:1"
+	request_method = "GET"
+	azure_auth     = %s
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, c.azureAuth),
+						ExpectError: regexp.MustCompile(`Invalid Azure Auth`),
+					},
+				},
+			})
+		})
+	}
+}
+
+func TestEphemeralHttpRequestAzureAuthClose(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var requests atomic.Int32
+	s := testNewEphemeralServer(t, testNewCountingCredentialFn(&requests, time.Hour), nil)
+	typeName := "azidentity_http_request"
+	config := map[string]tftypes.Value{
+		"request_url":    tftypes.NewValue(tftypes.String, server.URL),
+		"request_method": tftypes.NewValue(tftypes.String, http.MethodGet),
+		"azure_auth":     testHttpRequestAzureAuthValue(t, s),
+	}
+
+	refs := func() int {
+		cache := s.provider.tokenCache
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+
+		n := 0
+		for _, e := range cache.entries {
+			n += e.refs
+		}
+
+		return n
+	}
+
+	openResp, _ := s.open(typeName, config)
+	otherOpenResp, _ := s.open(typeName, config)
+	if n := refs(); n != 2 {
+		t.Errorf("expected the open resources to hold the token, got %d holds", n)
+	}
+
+	for _, private := range [][]byte{openResp.Private, otherOpenResp.Private} {
+		closeResp := s.close(typeName, private)
+		testRequireNoDiagnostics(t, closeResp.Diagnostics)
+	}
+
+	if n := refs(); n != 0 {
+		t.Errorf("expected closing to release the token, got %d holds", n)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 token request, got %d", requests.Load())
+	}
+}
+
+// testHttpRequestAzureAuthValue returns an azure_auth value of
+// azidentity_http_request with the client_secret credential.
+func testHttpRequestAzureAuthValue(t *testing.T, s *testEphemeralServer) tftypes.Value {
+	t.Helper()
+
+	requestType, ok := s.schemas.EphemeralResourceSchemas["azidentity_http_request"].ValueType().(tftypes.Object)
+	if !ok {
+		t.Fatalf("expected an object schema")
+	}
+	azureAuthType, ok := requestType.AttributeTypes["azure_auth"].(tftypes.Object)
+	if !ok {
+		t.Fatalf("expected azure_auth to be an object")
+	}
+	clientSecretType, ok := azureAuthType.AttributeTypes["client_secret"].(tftypes.Object)
+	if !ok {
+		t.Fatalf("expected the client_secret credential to be an object")
+	}
+
+	azureAuth := map[string]tftypes.Value{}
+	for name, attributeType := range azureAuthType.AttributeTypes {
+		azureAuth[name] = tftypes.NewValue(attributeType, nil)
+	}
+	clientSecret := map[string]tftypes.Value{}
+	for name, attributeType := range clientSecretType.AttributeTypes {
+		clientSecret[name] = tftypes.NewValue(attributeType, nil)
+	}
+	clientSecret["tenant_id"] = tftypes.NewValue(tftypes.String, "ze-tenant")
+	clientSecret["client_id"] = tftypes.NewValue(tftypes.String, "ze-client")
+	clientSecret["client_secret"] = tftypes.NewValue(tftypes.String, "ze-secret")
+	azureAuth["client_secret"] = tftypes.NewValue(clientSecretType, clientSecret)
+	azureAuth["scopes"] = tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{tftypes.NewValue(tftypes.String, "ze-scope")})
+
+	return tftypes.NewValue(azureAuthType, azureAuth)
+}
+
+func TestEphemeralHttpRequestAzureAuthCloseRequest(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var closes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ze-token-1" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+
+		if r.Method == http.MethodDelete {
+			closes.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var requests atomic.Int32
+	s := testNewEphemeralServer(t, testNewCountingCredentialFn(&requests, time.Hour), nil)
+	typeName := "azidentity_http_request"
+	openResp, _ := s.open(typeName, map[string]tftypes.Value{
+		"request_url":    tftypes.NewValue(tftypes.String, server.URL),
+		"request_method": tftypes.NewValue(tftypes.String, http.MethodPost),
+		"azure_auth":     testHttpRequestAzureAuthValue(t, s),
+		"close_request":  testHttpCloseRequestValue(http.MethodDelete, nil, nil),
+	})
+
+	closeResp := s.close(typeName, openResp.Private)
+	testRequireNoDiagnostics(t, closeResp.Diagnostics)
+
+	if closes.Load() != 1 {
+		t.Errorf("expected 1 close request, got %d", closes.Load())
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected the close request to reuse the cached token, got %d token requests", requests.Load())
+	}
+}
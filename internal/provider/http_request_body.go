@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const contentTypeForm = "application/x-www-form-urlencoded"
+
+// multipartQuoteEscaper escapes the quoted parameters of a Content-Disposition
+// header, as mime/multipart does.
+var multipartQuoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+type ephemeralHttpMultipartModel struct {
+	Value       types.String `tfsdk:"value"`
+	Filename    types.String `tfsdk:"filename"`
+	ContentType types.String `tfsdk:"content_type"`
+}
+
+// newHttpRequestBody returns the body of the request, from whichever of
+// request_body, request_form or request_multipart is set, and the content
+// type of form and multipart bodies. A nil body is an empty body.
+func newHttpRequestBody(ctx context.Context, data ephemeralHttpRequestModel) ([]byte, string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	switch {
+	case !data.RequestForm.IsNull():
+		form := url.Values{}
+		for k, v := range data.RequestForm.Elements() {
+			vv, ok := v.(types.String)
+			if !ok || vv.IsNull() {
+				continue
+			}
+
+			form.Set(k, vv.ValueString())
+		}
+
+		// Encode sorts the fields by name.
+		return []byte(form.Encode()), contentTypeForm, diags
+	case !data.RequestMultipart.IsNull():
+		parts := map[string]ephemeralHttpMultipartModel{}
+		diags.Append(data.RequestMultipart.ElementsAs(ctx, &parts, false)...)
+		if diags.HasError() {
+			return nil, "", diags
+		}
+
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for _, name := range slices.Sorted(maps.Keys(parts)) {
+			part := parts[name]
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, multipartQuoteEscaper.Replace(name)))
+			if !part.Filename.IsNull() {
+				header.Set("Content-Disposition", multipart.FileContentDisposition(name, part.Filename.ValueString()))
+			}
+			if !part.ContentType.IsNull() {
+				header.Set("Content-Type", part.ContentType.ValueString())
+			}
+
+			pw, err := w.CreatePart(header)
+			if err == nil {
+				_, err = pw.Write([]byte(part.Value.ValueString()))
+			}
+			if err != nil {
+				diags.AddError("Failed to create multipart request body", err.Error())
+				return nil, "", diags
+			}
+		}
+
+		err := w.Close()
+		if err != nil {
+			diags.AddError("Failed to create multipart request body", err.Error())
+			return nil, "", diags
+		}
+
+		return body.Bytes(), w.FormDataContentType(), diags
+	case !data.RequestBody.IsNull():
+		return []byte(data.RequestBody.ValueString()), "", diags
+	}
+
+	return nil, "", diags
+}
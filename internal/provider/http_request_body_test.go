@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestEphemeralHttpRequestForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("expected Content-Type header to be application/x-www-form-urlencoded, got %s", r.Header.Get("Content-Type"))
+		}
+
+		reqBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+
+		expected := "client_id=ze-client&client_secret=ze+secret%26%3D&grant_type=client_credentials"
+		if string(reqBody) != expected {
+			t.Errorf("expected request body to be %s, got %s", expected, reqBody)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s"
+	request_method = "POST"
+	request_headers = {
+		Content-Type = "text/plain"
+	}
+	request_form = {
+		grant_type    = "client_credentials"
+		client_id     = "ze-client"
+		client_secret = "ze secret&="
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_status_code"),
+						knownvalue.Int32Exact(http.StatusOK),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestMultipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseMultipartForm(1 << 20)
+		if err != nil {
+			t.Errorf("failed to parse multipart request body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if r.FormValue("grant_type") != "ze-grant" {
+			t.Errorf("expected grant_type to be ze-grant, got %s", r.FormValue("grant_type"))
+		}
+
+		file, header, err := r.FormFile("ze-file")
+		if err != nil {
+			t.Errorf("failed to read file part: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer func() { _ = file.Close() }()
+
+		content, err := io.ReadAll(file)
+		if err != nil {
+			t.Errorf("failed to read file part: %v", err)
+		}
+
+		if header.Filename != "ze-file.json" || header.Header.Get("Content-Type") != "application/json" || string(content) != `{"ze-key":"ze-value"}` {
+			t.Errorf("unexpected file part %s, %s: %s", header.Filename, header.Header.Get("Content-Type"), content)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s"
+	request_method = "POST"
+	request_multipart = {
+		grant_type = {
+			value = "ze-grant"
+		}
+		ze-file = {
+			value        = jsonencode({ "ze-key" = "ze-value" })
+			filename     = "ze-file.json"
+			content_type = "application/json"
+		}
+	}
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("response_status_code"),
+						knownvalue.Int32Exact(http.StatusOK),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralHttpRequestBodyConflicts(t *testing.T) {
+	cases := []struct {
+		name   string
+		bodies string
+	}{
+		{
+			name: "body_and_form",
+			bodies: `
+	request_body = "ze-body"
+	request_form = {
+		ze-key = "ze-value"
+	}`,
+		},
+		{
+			name: "body_and_multipart",
+			bodies: `
+	request_body = "ze-body"
+	request_multipart = {
+		ze-key = {
+			value = "ze-value"
+		}
+	}`,
+		},
+		{
+			name: "form_and_multipart",
+			bodies: `
+	request_form = {
+		ze-key = "ze-value"
+	}
+	request_multipart = {
+		ze-key = {
+			value = "ze-value"
+		}
+	}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "http://127.0.0.1:1"
+	request_method = "POST"
+	%s
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this
+}
+
+resource "echo" "this" {}
+`, c.bodies),
+						ExpectError: regexp.MustCompile(`Invalid Attribute Combination`),
+					},
+				},
+			})
+		})
+	}
+}
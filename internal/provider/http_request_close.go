@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+// httpCloseRequestPrivateKey is the private data key of the close request.
+const httpCloseRequestPrivateKey = "close_request"
+
+// httpCloseRequest is kept by Open and sent by Close, e.g. to revoke or delete
+// what the request created. It's authenticated as the request when azureAuth
+// is set.
+type httpCloseRequest struct {
+	URL       string
+	Method    string
+	Headers   map[string]string
+	Body      *string
+	Timeout   time.Duration
+	AzureAuth *httpAzureAuth
+}
+
+// httpCloseRequestState is stashed in the private data by Open. The URL,
+// headers and body of a close request may contain secrets, so the request is
+// kept in the memory of the provider and the private data only holds its ID.
+type httpCloseRequestState struct {
+	ID string `json:"id"`
+}
+
+// httpCloseRequestStore keeps the close requests of the open HTTP request
+// resources of a provider instance, by random IDs.
+type httpCloseRequestStore struct {
+	mu       sync.Mutex
+	requests map[string]httpCloseRequest
+}
+
+func newHttpCloseRequestStore() *httpCloseRequestStore {
+	return &httpCloseRequestStore{
+		requests: map[string]httpCloseRequest{},
+	}
+}
+
+// put keeps the close request configured by value until the resource is
+// closed, stashing its ID in the private data of the response. The close
+// request is sent to reqUrl unless it sets its own URL.
+func (s *httpCloseRequestStore) put(ctx context.Context, resp *ephemeral.OpenResponse, value types.Object, reqUrl string, reqTimeout time.Duration, azureAuth *httpAzureAuth) diag.Diagnostics {
+	var data ephemeralHttpCloseRequestModel
+	diags := value.As(ctx, &data, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return diags
+	}
+
+	closeReq := httpCloseRequest{
+		URL:     reqUrl,
+		Method:  data.Method.ValueString(),
+		Headers: map[string]string{},
+		Timeout: reqTimeout,
+	}
+	if !data.URL.IsNull() {
+		closeReq.URL = data.URL.ValueString()
+	}
+	if !data.Body.IsNull() {
+		body := data.Body.ValueString()
+		closeReq.Body = &body
+	}
+	for k, v := range data.Headers.Elements() {
+		vv, ok := v.(types.String)
+		if !ok || vv.IsNull() {
+			continue
+		}
+
+		closeReq.Headers[k] = vv.ValueString()
+	}
+	if azureAuth != nil {
+		closeReq.AzureAuth = &httpAzureAuth{credType: azureAuth.credType, cfg: azureAuth.cfg}
+	}
+
+	id := rand.Text()
+	b, err := json.Marshal(httpCloseRequestState{ID: id})
+	if err != nil {
+		diags.AddError("Failed to store HTTP close request", err.Error())
+		return diags
+	}
+
+	diags.Append(resp.Private.SetKey(ctx, httpCloseRequestPrivateKey, b)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[id] = closeReq
+
+	return diags
+}
+
+// take removes the close request of a closed resource, reporting false when it
+// has none. A close request lost to a restart of the provider is reported as a
+// warning.
+func (s *httpCloseRequestStore) take(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) (httpCloseRequest, bool) {
+	b, diags := req.Private.GetKey(ctx, httpCloseRequestPrivateKey)
+	appendAsWarnings(&resp.Diagnostics, diags)
+	if diags.HasError() || len(b) == 0 {
+		return httpCloseRequest{}, false
+	}
+
+	var state httpCloseRequestState
+	err := json.Unmarshal(b, &state)
+	if err != nil {
+		resp.Diagnostics.AddWarning("Failed to read HTTP close request", err.Error())
+		return httpCloseRequest{}, false
+	}
+
+	var closeReq httpCloseRequest
+	var ok bool
+	if s != nil {
+		s.mu.Lock()
+		closeReq, ok = s.requests[state.ID]
+		delete(s.requests, state.ID)
+		s.mu.Unlock()
+	}
+
+	if !ok {
+		resp.Diagnostics.AddWarning(
+			"HTTP close request not sent",
+			"The close request is kept in the memory of the provider, which was restarted since the request was sent.",
+		)
+		return httpCloseRequest{}, false
+	}
+
+	return closeReq, true
+}
+
+// send sends the close request, failing on error status codes. The token of
+// azure_auth is acquired through the token cache, where it's still held by
+// the closed resource.
+func (c httpCloseRequest) send(ctx context.Context, cache *tokenCache, getCredFn getCredentialFn, httpClient *http.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	newRequest := func() (*http.Request, error) {
+		var body io.Reader = http.NoBody
+		if c.Body != nil {
+			body = strings.NewReader(*c.Body)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, c.Method, c.URL, body)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range c.Headers {
+			httpReq.Header.Set(k, v)
+		}
+
+		return httpReq, nil
+	}
+
+	retry := httpRetryConfig{MaxAttempts: 1}
+	var httpRes *http.Response
+	var err error
+	if c.AzureAuth != nil {
+		httpRes, _, _, _, err = c.AzureAuth.do(ctx, cache, getCredFn, retry, httpClient, newRequest)
+		c.AzureAuth.release(cache)
+	} else {
+		httpRes, _, _, _, err = retry.do(ctx, httpClient, newRequest)
+	}
+	if err != nil {
+		return err
+	}
+
+	if httpRes.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", httpRes.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// jsonPathSegment is an object key or array index of a JSON path.
+type jsonPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s jsonPathSegment) String() string {
+	if s.isIndex {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+
+	return fmt.Sprintf("[%q]", s.key)
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as json.Number so large
+// numbers don't lose precision.
+func decodeJSON(b []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v any
+	err := d.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.Token()
+	if !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+
+	return v, nil
+}
+
+// parseJSONPath parses a JSONPath-style expression of object keys and array
+// indexes, such as `$.items[0].name`, `items[-1]` or `$['key.with.dots']`. The
+// leading `$` is optional, and an empty path selects the whole document.
+func parseJSONPath(expr string) ([]jsonPathSegment, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	segments := []jsonPathSegment{}
+
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '[' && i+1 < len(s) && (s[i+1] == '\'' || s[i+1] == '"'):
+			quote := s[i+1]
+			end := strings.IndexByte(s[i+2:], quote)
+			if end < 0 || i+2+end+1 >= len(s) || s[i+2+end+1] != ']' {
+				return nil, fmt.Errorf("unterminated key at position %d of %q", i, expr)
+			}
+			segments = append(segments, jsonPathSegment{key: s[i+2 : i+2+end]})
+			i += 2 + end + 2
+		case s[i] == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index at position %d of %q", i, expr)
+			}
+			index, err := strconv.Atoi(s[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q at position %d of %q", s[i+1:i+end], i, expr)
+			}
+			segments = append(segments, jsonPathSegment{index: index, isIndex: true})
+			i += end + 1
+		case s[i] == '.' || i == 0:
+			if s[i] == '.' {
+				i++
+			}
+			end := strings.IndexAny(s[i:], ".[")
+			if end < 0 {
+				end = len(s) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key at position %d of %q", i, expr)
+			}
+			segments = append(segments, jsonPathSegment{key: s[i : i+end]})
+			i += end
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d of %q", s[i], i, expr)
+		}
+	}
+
+	return segments, nil
+}
+
+// evalJSONPath returns the value at the path of a document decoded by
+// decodeJSON. Negative indexes count from the end of arrays.
+func evalJSONPath(doc any, segments []jsonPathSegment) (any, error) {
+	v := doc
+	at := "$"
+	for _, s := range segments {
+		switch vv := v.(type) {
+		case map[string]any:
+			if s.isIndex {
+				return nil, fmt.Errorf("%s is an object, not an array", at)
+			}
+			value, ok := vv[s.key]
+			if !ok {
+				return nil, fmt.Errorf("%s has no key %q", at, s.key)
+			}
+			v = value
+		case []any:
+			if !s.isIndex {
+				return nil, fmt.Errorf("%s is an array, not an object", at)
+			}
+			index := s.index
+			if index < 0 {
+				index += len(vv)
+			}
+			if index < 0 || index >= len(vv) {
+				return nil, fmt.Errorf("index %d is out of range of %s with %d elements", s.index, at, len(vv))
+			}
+			v = vv[index]
+		default:
+			return nil, fmt.Errorf("%s is a %s, not an object or array", at, jsonTypeName(v))
+		}
+		at += s.String()
+	}
+
+	return v, nil
+}
+
+// jsonValueString returns a string as is and any other value as JSON, or null
+// for a JSON null.
+func jsonValueString(v any) (types.String, error) {
+	switch vv := v.(type) {
+	case nil:
+		return types.StringNull(), nil
+	case string:
+		return types.StringValue(vv), nil
+	case json.Number:
+		return types.StringValue(vv.String()), nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return types.StringNull(), err
+	}
+
+	return types.StringValue(string(b)), nil
+}
+
+// newJSONValue converts a document decoded by decodeJSON to a value of a
+// dynamic attribute, objects becoming objects and arrays tuples as with
+// jsondecode. JSON nulls become null strings, as values need a concrete type.
+func newJSONValue(ctx context.Context, v any) (attr.Value, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	switch vv := v.(type) {
+	case nil:
+		return types.StringNull(), diags
+	case string:
+		return types.StringValue(vv), diags
+	case bool:
+		return types.BoolValue(vv), diags
+	case json.Number:
+		f, _, err := big.ParseFloat(vv.String(), 10, 512, big.ToNearestEven)
+		if err != nil {
+			diags.AddError("Failed to convert JSON number", err.Error())
+			return nil, diags
+		}
+		return types.NumberValue(f), diags
+	case []any:
+		elemTypes := []attr.Type{}
+		elems := []attr.Value{}
+		for _, e := range vv {
+			elem, d := newJSONValue(ctx, e)
+			diags.Append(d...)
+			if diags.HasError() {
+				return nil, diags
+			}
+			elemTypes = append(elemTypes, elem.Type(ctx))
+			elems = append(elems, elem)
+		}
+		value, d := types.TupleValue(elemTypes, elems)
+		diags.Append(d...)
+		return value, diags
+	case map[string]any:
+		attrTypes := map[string]attr.Type{}
+		attrs := map[string]attr.Value{}
+		for k, e := range vv {
+			elem, d := newJSONValue(ctx, e)
+			diags.Append(d...)
+			if diags.HasError() {
+				return nil, diags
+			}
+			attrTypes[k] = elem.Type(ctx)
+			attrs[k] = elem
+		}
+		value, d := types.ObjectValue(attrTypes, attrs)
+		diags.Append(d...)
+		return value, diags
+	}
+
+	diags.AddError("Failed to convert JSON value", fmt.Sprintf("Unexpected JSON value of type %T", v))
+	return nil, diags
+}
+
+// extractJSONValues evaluates the expressions of response_json_paths against
+// the response body, returning the extracted values and the errors by key.
+func extractJSONValues(paths types.Map, doc any, docErr error) (map[string]attr.Value, map[string]error) {
+	values := map[string]attr.Value{}
+	errs := map[string]error{}
+	for k, v := range paths.Elements() {
+		expr, ok := v.(types.String)
+		if !ok || expr.IsNull() || expr.IsUnknown() {
+			continue
+		}
+
+		if docErr != nil {
+			errs[k] = fmt.Errorf("the response body isn't JSON: %w", docErr)
+			continue
+		}
+
+		segments, err := parseJSONPath(expr.ValueString())
+		if err != nil {
+			errs[k] = err
+			continue
+		}
+
+		value, err := evalJSONPath(doc, segments)
+		if err != nil {
+			errs[k] = err
+			continue
+		}
+
+		values[k], err = jsonValueString(value)
+		if err != nil {
+			errs[k] = err
+		}
+	}
+
+	return values, errs
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestEvalJSONPath(t *testing.T) {
+	doc, err := decodeJSON([]byte(`{
+		"ze-string": "ze-value",
+		"ze-number": 12345678901234567890,
+		"ze-bool": true,
+		"ze-null": null,
+		"ze-array": [{"name": "ze-first"}, {"name": "ze-last"}],
+		"ze.dotted": {"ze-key": "ze-dotted-value"}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		name          string
+		expr          string
+		expected      types.String
+		expectedError string
+	}{
+		{
+			name:     "string",
+			expr:     "$.ze-string",
+			expected: types.StringValue("ze-value"),
+		},
+		{
+			name:     "without_root",
+			expr:     "ze-string",
+			expected: types.StringValue("ze-value"),
+		},
+		{
+			name:     "number",
+			expr:     "$['ze-number']",
+			expected: types.StringValue("12345678901234567890"),
+		},
+		{
+			name:     "bool",
+			expr:     "ze-bool",
+			expected: types.StringValue("true"),
+		},
+		{
+			name:     "null",
+			expr:     "ze-null",
+			expected: types.StringNull(),
+		},
+		{
+			name:     "index",
+			expr:     "$.ze-array[0].name",
+			expected: types.StringValue("ze-first"),
+		},
+		{
+			name:     "negative_index",
+			expr:     `ze-array[-1]["name"]`,
+			expected: types.StringValue("ze-last"),
+		},
+		{
+			name:     "object",
+			expr:     "$['ze.dotted']",
+			expected: types.StringValue(`{"ze-key":"ze-dotted-value"}`),
+		},
+		{
+			name:          "missing_key",
+			expr:          "$.ze-array[1].ze-missing",
+			expectedError: `$["ze-array"][1] has no key "ze-missing"`,
+		},
+		{
+			name:          "index_out_of_range",
+			expr:          "$.ze-array[2]",
+			expectedError: `index 2 is out of range of $["ze-array"] with 2 elements`,
+		},
+		{
+			name:          "index_of_object",
+			expr:          "$[0]",
+			expectedError: `$ is an object, not an array`,
+		},
+		{
+			name:          "key_of_string",
+			expr:          "$.ze-string.ze-key",
+			expectedError: `$["ze-string"] is a string, not an object or array`,
+		},
+		{
+			name:          "empty_key",
+			expr:          "$.ze-string..ze-key",
+			expectedError: `empty key at position 11 of "$.ze-string..ze-key"`,
+		},
+		{
+			name:          "invalid_index",
+			expr:          "$.ze-array[ze]",
+			expectedError: `invalid index "ze"`,
+		},
+		{
+			name:          "unterminated_key",
+			expr:          "$['ze-string",
+			expectedError: `unterminated key`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			segments, err := parseJSONPath(c.expr)
+			var value any
+			if err == nil {
+				value, err = evalJSONPath(doc, segments)
+			}
+			if c.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", c.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			s, err := jsonValueString(value)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !s.Equal(c.expected) {
+				t.Errorf("expected %s, got %s", c.expected, s)
+			}
+		})
+	}
+}
+
+func TestExtractJSONValues(t *testing.T) {
+	paths := types.MapValueMust(types.StringType, map[string]attr.Value{
+		"ze-found":   types.StringValue("$.ze-key"),
+		"ze-missing": types.StringValue("$.ze-missing"),
+	})
+
+	doc, err := decodeJSON([]byte(`{"ze-key": "ze-value"}`))
+	values, errs := extractJSONValues(paths, doc, err)
+	if !values["ze-found"].Equal(types.StringValue("ze-value")) {
+		t.Errorf("expected ze-found to be ze-value, got %s", values["ze-found"])
+	}
+	if len(errs) != 1 || errs["ze-missing"] == nil {
+		t.Errorf("expected an error for ze-missing only, got %v", errs)
+	}
+
+	doc, err = decodeJSON([]byte(`ze-body`))
+	values, errs = extractJSONValues(paths, doc, err)
+	if len(values) != 0 || len(errs) != 2 || !strings.Contains(errs["ze-found"].Error(), "the response body isn't JSON") {
+		t.Errorf("expected errors for both keys, got %v and %v", values, errs)
+	}
+}
+
+func TestNewJSONValue(t *testing.T) {
+	doc, err := decodeJSON([]byte(`{"ze-key": ["ze-value", 1.5, false, null, {}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	value, diags := newJSONValue(t.Context(), doc)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	expected := `{"ze-key":["ze-value",1.5,false,<null>,{}]}`
+	if value.String() != expected {
+		t.Errorf("expected %s, got %s", expected, value.String())
+	}
+
+	for _, body := range []string{`{} {}`, `{"a":1}}`, `[1]]`, `"ze-value" ,`} {
+		_, err = decodeJSON([]byte(body))
+		if err == nil {
+			t.Errorf("expected an error for data after the JSON value of %s", body)
+		}
+	}
+
+	_, err = decodeJSON([]byte(" {\"a\":1} \n"))
+	if err != nil {
+		t.Errorf("unexpected error for trailing whitespace: %s", err)
+	}
+}
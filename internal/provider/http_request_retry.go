@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	defaultHttpRetryMinDelay = time.Second
+	defaultHttpRetryMaxDelay = 30 * time.Second
+)
+
+// defaultHttpRetryStatusCodes are the status codes retried by
+// azidentity_http_request unless retry_on_status_codes is set, the same as
+// the defaults of the credentials.
+var defaultHttpRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// httpRetryConfig is the retry policy of azidentity_http_request. Requests
+// failing with a transport error or a status code in StatusCodes are retried
+// with an exponential backoff from MinDelay up to MaxDelay, or after the delay
+// of the Retry-After header of the response.
+type httpRetryConfig struct {
+	MaxAttempts int
+	MinDelay    time.Duration
+	MaxDelay    time.Duration
+	StatusCodes []int
+}
+
+func newHttpRetryConfig(maxAttempts types.Int64, minDelay types.String, maxDelay types.String, statusCodes types.Set) (httpRetryConfig, error) {
+	cfg := httpRetryConfig{
+		MaxAttempts: 1,
+		MinDelay:    defaultHttpRetryMinDelay,
+		MaxDelay:    defaultHttpRetryMaxDelay,
+		StatusCodes: defaultHttpRetryStatusCodes,
+	}
+
+	if !maxAttempts.IsNull() && !maxAttempts.IsUnknown() {
+		cfg.MaxAttempts = int(maxAttempts.ValueInt64())
+	}
+
+	d, err := parseRetryDelay("retry_min_delay", minDelay)
+	if err != nil {
+		return httpRetryConfig{}, err
+	}
+	if d > 0 {
+		cfg.MinDelay = d
+	}
+
+	d, err = parseRetryDelay("retry_max_delay", maxDelay)
+	if err != nil {
+		return httpRetryConfig{}, err
+	}
+	if d > 0 {
+		cfg.MaxDelay = d
+	}
+
+	if cfg.MinDelay > cfg.MaxDelay {
+		return httpRetryConfig{}, fmt.Errorf("retry_min_delay %s must not be greater than retry_max_delay %s", cfg.MinDelay, cfg.MaxDelay)
+	}
+
+	if !statusCodes.IsNull() && !statusCodes.IsUnknown() {
+		cfg.StatusCodes = []int{}
+		for _, v := range statusCodes.Elements() {
+			code, ok := v.(types.Int64)
+			if !ok {
+				continue
+			}
+			cfg.StatusCodes = append(cfg.StatusCodes, int(code.ValueInt64()))
+		}
+	}
+
+	return cfg, nil
+}
+
+// do sends the request built by newRequest until it gets a response not worth
+// retrying or runs out of attempts, returning the last response with its body
+// read and the number of attempts made. Retries are bounded by the deadline
+// of ctx, a retry that can't be made before the deadline isn't attempted.
+func (c httpRetryConfig) do(ctx context.Context, httpClient *http.Client, newRequest func() (*http.Request, error)) (*http.Response, []byte, int, string, error) {
+	for attempt := 1; ; attempt++ {
+		httpReq, err := newRequest()
+		if err != nil {
+			return nil, nil, attempt - 1, "Failed to create HTTP request", err
+		}
+
+		httpRes, resBody, errSummary, err := sendHttpRequest(httpClient, httpReq)
+		delay, retry := c.retryDelay(ctx, attempt, httpRes, err)
+		if !retry {
+			return httpRes, resBody, attempt, errSummary, err
+		}
+
+		if err != nil {
+			tflog.Debug(ctx, fmt.Sprintf("Retrying HTTP request in %s after attempt %d failed: %s", delay, attempt, err))
+		} else {
+			tflog.Debug(ctx, fmt.Sprintf("Retrying HTTP request in %s after attempt %d returned status code %d", delay, attempt, httpRes.StatusCode))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return httpRes, resBody, attempt, errSummary, err
+		case <-timer.C:
+		}
+	}
+}
+
+func sendHttpRequest(httpClient *http.Client, httpReq *http.Request) (*http.Response, []byte, string, error) {
+	httpRes, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, "Failed to send HTTP request", err
+	}
+
+	defer func() { _ = httpRes.Body.Close() }()
+
+	resBody, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		return nil, nil, "Failed to read HTTP response body", err
+	}
+
+	return httpRes, resBody, "", nil
+}
+
+// retryDelay returns how long to wait before retrying, and whether to retry at
+// all.
+func (c httpRetryConfig) retryDelay(ctx context.Context, attempt int, httpRes *http.Response, err error) (time.Duration, bool) {
+	if attempt >= c.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	if err == nil && !slices.Contains(c.StatusCodes, httpRes.StatusCode) {
+		return 0, false
+	}
+
+	// The delay stops doubling once it reaches the maximum, so large minimum
+	// delays can't overflow.
+	delay := c.MinDelay
+	for i := 1; i < attempt && delay < c.MaxDelay; i++ {
+		if delay > c.MaxDelay>>1 {
+			delay = c.MaxDelay
+			break
+		}
+
+		delay *= 2
+	}
+
+	if delay <= 0 || delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+
+	if httpRes != nil {
+		if retryAfter, ok := parseRetryAfter(httpRes.Header.Get("Retry-After"), c.MaxDelay); ok {
+			delay = retryAfter
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+// parseRetryAfter parses a Retry-After header, either in seconds or an HTTP
+// date, capping the delay at maxDelay. The seconds are capped before being
+// converted, so large values can't overflow.
+func parseRetryAfter(value string, maxDelay time.Duration) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds > int64(maxDelay/time.Second) {
+			return maxDelay, true
+		}
+
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return min(max(time.Until(t), 0), maxDelay), true
+	}
+
+	return 0, false
+}
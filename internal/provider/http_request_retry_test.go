@@ -0,0 +1,364 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestNewHttpRetryConfig(t *testing.T) {
+	cases := []struct {
+		name          string
+		maxAttempts   types.Int64
+		minDelay      types.String
+		maxDelay      types.String
+		statusCodes   types.Set
+		expected      httpRetryConfig
+		expectedError string
+	}{
+		{
+			name:        "unset",
+			maxAttempts: types.Int64Null(),
+			minDelay:    types.StringNull(),
+			maxDelay:    types.StringNull(),
+			statusCodes: types.SetNull(types.Int64Type),
+			expected: httpRetryConfig{
+				MaxAttempts: 1,
+				MinDelay:    time.Second,
+				MaxDelay:    30 * time.Second,
+				StatusCodes: []int{408, 429, 500, 502, 503, 504},
+			},
+		},
+		{
+			name:        "set",
+			maxAttempts: types.Int64Value(5),
+			minDelay:    types.StringValue("2s"),
+			maxDelay:    types.StringValue("1m"),
+			statusCodes: types.SetValueMust(types.Int64Type, []attr.Value{types.Int64Value(429)}),
+			expected: httpRetryConfig{
+				MaxAttempts: 5,
+				MinDelay:    2 * time.Second,
+				MaxDelay:    time.Minute,
+				StatusCodes: []int{429},
+			},
+		},
+		{
+			name:          "invalid_min_delay",
+			maxAttempts:   types.Int64Null(),
+			minDelay:      types.StringValue("ze-invalid"),
+			maxDelay:      types.StringNull(),
+			statusCodes:   types.SetNull(types.Int64Type),
+			expectedError: `failed to parse retry_min_delay "ze-invalid" as a duration`,
+		},
+		{
+			name:          "negative_max_delay",
+			maxAttempts:   types.Int64Null(),
+			minDelay:      types.StringNull(),
+			maxDelay:      types.StringValue("-1s"),
+			statusCodes:   types.SetNull(types.Int64Type),
+			expectedError: `retry_max_delay "-1s" must be positive`,
+		},
+		{
+			name:          "min_delay_greater_than_max_delay",
+			maxAttempts:   types.Int64Null(),
+			minDelay:      types.StringValue("1m"),
+			maxDelay:      types.StringNull(),
+			statusCodes:   types.SetNull(types.Int64Type),
+			expectedError: `retry_min_delay 1m0s must not be greater than retry_max_delay 30s`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg, err := newHttpRetryConfig(c.maxAttempts, c.minDelay, c.maxDelay, c.statusCodes)
+			if c.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", c.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if fmt.Sprintf("%#v", cfg) != fmt.Sprintf("%#v", c.expected) {
+				t.Errorf("expected %#v, got %#v", c.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		name       string
+		value      string
+		expected   time.Duration
+		expectedOk bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:       "seconds",
+			value:      "3",
+			expected:   3 * time.Second,
+			expectedOk: true,
+		},
+		{
+			name:       "negative_seconds",
+			value:      "-3",
+			expectedOk: true,
+		},
+		{
+			name:       "past_date",
+			value:      "Wed, 21 Oct 2015 07:28:00 GMT",
+			expectedOk: true,
+		},
+		{
+			name:       "above_max_delay",
+			value:      "120",
+			expected:   time.Minute,
+			expectedOk: true,
+		},
+		{
+			name:       "overflowing_seconds",
+			value:      "9223372036854775807",
+			expected:   time.Minute,
+			expectedOk: true,
+		},
+		{
+			name:  "invalid",
+			value: "ze-invalid",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, ok := parseRetryAfter(c.value, time.Minute)
+			if d != c.expected || ok != c.expectedOk {
+				t.Errorf("expected %s, %t, got %s, %t", c.expected, c.expectedOk, d, ok)
+			}
+		})
+	}
+
+	d, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 2*time.Hour)
+	if !ok || d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("expected a delay of about an hour, got %s, %t", d, ok)
+	}
+
+	d, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), time.Minute)
+	if !ok || d != time.Minute {
+		t.Errorf("expected the delay to be capped at a minute, got %s, %t", d, ok)
+	}
+}
+
+func TestHttpRetryConfigDo(t *testing.T) {
+	cases := []struct {
+		name               string
+		cfg                httpRetryConfig
+		timeout            time.Duration
+		statusCodes        []int
+		retryAfter         string
+		expectedStatusCode int
+		expectedAttempts   int
+	}{
+		{
+			name:               "success",
+			cfg:                httpRetryConfig{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond, StatusCodes: defaultHttpRetryStatusCodes},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusOK},
+			expectedStatusCode: http.StatusOK,
+			expectedAttempts:   1,
+		},
+		{
+			name:               "retried",
+			cfg:                httpRetryConfig{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond, StatusCodes: defaultHttpRetryStatusCodes},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK},
+			expectedStatusCode: http.StatusOK,
+			expectedAttempts:   3,
+		},
+		{
+			name:               "attempts_exhausted",
+			cfg:                httpRetryConfig{MaxAttempts: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond, StatusCodes: defaultHttpRetryStatusCodes},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedStatusCode: http.StatusBadGateway,
+			expectedAttempts:   2,
+		},
+		{
+			name:               "status_code_not_retried",
+			cfg:                httpRetryConfig{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond, StatusCodes: []int{}},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedAttempts:   1,
+		},
+		{
+			name:               "retry_after",
+			cfg:                httpRetryConfig{MaxAttempts: 2, MinDelay: time.Hour, MaxDelay: time.Hour, StatusCodes: defaultHttpRetryStatusCodes},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:         "0",
+			expectedStatusCode: http.StatusOK,
+			expectedAttempts:   2,
+		},
+		{
+			name:               "retry_after_beyond_timeout",
+			cfg:                httpRetryConfig{MaxAttempts: 2, MinDelay: time.Millisecond, MaxDelay: time.Hour, StatusCodes: defaultHttpRetryStatusCodes},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:         "120",
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedAttempts:   1,
+		},
+		{
+			name:               "retry_after_beyond_max_delay",
+			cfg:                httpRetryConfig{MaxAttempts: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond, StatusCodes: defaultHttpRetryStatusCodes},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:         "120",
+			expectedStatusCode: http.StatusOK,
+			expectedAttempts:   2,
+		},
+		{
+			name:               "delay_beyond_timeout",
+			cfg:                httpRetryConfig{MaxAttempts: 3, MinDelay: time.Hour, MaxDelay: time.Hour, StatusCodes: defaultHttpRetryStatusCodes},
+			timeout:            time.Minute,
+			statusCodes:        []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedAttempts:   1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(requests.Add(1)) - 1
+				if c.retryAfter != "" {
+					w.Header().Set("Retry-After", c.retryAfter)
+				}
+				w.WriteHeader(c.statusCodes[min(i, len(c.statusCodes)-1)])
+				fmt.Fprintf(w, "ze-body-%d", i+1)
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(t.Context(), c.timeout)
+			defer cancel()
+
+			httpRes, resBody, attempts, _, err := c.cfg.do(ctx, server.Client(), func() (*http.Request, error) {
+				return http.NewRequestWithContext(ctx, http.MethodGet, server.URL, http.NoBody)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if httpRes.StatusCode != c.expectedStatusCode {
+				t.Errorf("expected status code %d, got %d", c.expectedStatusCode, httpRes.StatusCode)
+			}
+
+			if attempts != c.expectedAttempts || int(requests.Load()) != c.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d attempts and %d requests", c.expectedAttempts, attempts, requests.Load())
+			}
+
+			if expectedBody := fmt.Sprintf("ze-body-%d", c.expectedAttempts); string(resBody) != expectedBody {
+				t.Errorf("expected body %q, got %q", expectedBody, resBody)
+			}
+		})
+	}
+}
+
+func TestHttpRetryConfigRetryDelay(t *testing.T) {
+	httpRes := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+
+	cases := []struct {
+		name     string
+		cfg      httpRetryConfig
+		attempt  int
+		expected time.Duration
+	}{
+		{
+			name:     "first_attempt",
+			cfg:      httpRetryConfig{MaxAttempts: 5, MinDelay: time.Second, MaxDelay: 30 * time.Second},
+			attempt:  1,
+			expected: time.Second,
+		},
+		{
+			name:     "doubled",
+			cfg:      httpRetryConfig{MaxAttempts: 5, MinDelay: time.Second, MaxDelay: 30 * time.Second},
+			attempt:  3,
+			expected: 4 * time.Second,
+		},
+		{
+			name:     "capped",
+			cfg:      httpRetryConfig{MaxAttempts: 10, MinDelay: time.Second, MaxDelay: 30 * time.Second},
+			attempt:  9,
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "large_min_delay_and_attempts",
+			cfg:      httpRetryConfig{MaxAttempts: 100, MinDelay: 100000 * time.Hour, MaxDelay: 200000 * time.Hour},
+			attempt:  99,
+			expected: 200000 * time.Hour,
+		},
+		{
+			name:     "large_min_delay_near_overflow",
+			cfg:      httpRetryConfig{MaxAttempts: 100, MinDelay: math.MaxInt64/2 + 1, MaxDelay: math.MaxInt64},
+			attempt:  2,
+			expected: math.MaxInt64,
+		},
+		{
+			name:     "zero_min_delay",
+			cfg:      httpRetryConfig{MaxAttempts: 5, MinDelay: 0, MaxDelay: 30 * time.Second},
+			attempt:  2,
+			expected: 30 * time.Second,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.cfg.StatusCodes = defaultHttpRetryStatusCodes
+
+			delay, ok := c.cfg.retryDelay(t.Context(), c.attempt, httpRes, nil)
+			if !ok {
+				t.Fatal("expected a retry")
+			}
+
+			if delay != c.expected {
+				t.Errorf("expected delay %s, got %s", c.expected, delay)
+			}
+		})
+	}
+}
+
+func TestHttpRetryConfigDoNetworkError(t *testing.T) {
+	cfg := httpRetryConfig{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	var requests atomic.Int32
+	_, _, attempts, errSummary, err := cfg.do(t.Context(), http.DefaultClient, func() (*http.Request, error) {
+		requests.Add(1)
+		return http.NewRequestWithContext(t.Context(), http.MethodGet, "http://127.0.0.1:1", http.NoBody)
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if errSummary != "Failed to send HTTP request" {
+		t.Errorf("expected error summary %q, got %q", "Failed to send HTTP request", errSummary)
+	}
+
+	if attempts != 3 || requests.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d attempts and %d requests", attempts, requests.Load())
+	}
+}
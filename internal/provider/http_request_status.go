@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// httpResponseSnippetLength is the maximum length of the response body
+// snippets included in errors.
+const httpResponseSnippetLength = 256
+
+// httpStatusCodePattern matches the expected status codes of
+// azidentity_http_request, a status code such as 200 or a class of status
+// codes such as 2xx.
+var httpStatusCodePattern = regexp.MustCompile(`(?i)^[1-5]([0-9]{2}|xx)$`)
+
+// httpResponseRedactions match the secrets redacted from response body
+// snippets, keeping the name of the redacted JSON or form field.
+var httpResponseRedactions = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{
+		pattern:     regexp.MustCompile(`(?i)("[^"]*(token|secret|password|assertion|key|credential)[^"]*"\s*:\s*)"(\\.|[^"\\])*"`),
+		replacement: `$1"[REDACTED]"`,
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)(\b[a-z_]*(token|secret|password|assertion|key|credential)[a-z_]*=)[^&\s]+`),
+		replacement: `$1[REDACTED]`,
+	},
+	{
+		pattern:     regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		replacement: `[REDACTED]`,
+	},
+}
+
+// httpStatusCodeRange is an inclusive range of status codes.
+type httpStatusCodeRange struct {
+	min int
+	max int
+}
+
+// newHttpStatusCodeRanges parses expected_status_codes, a null value expecting
+// any status code.
+func newHttpStatusCodeRanges(statusCodes types.Set) ([]httpStatusCodeRange, error) {
+	if statusCodes.IsNull() || statusCodes.IsUnknown() {
+		return nil, nil
+	}
+
+	ranges := []httpStatusCodeRange{}
+	for _, v := range statusCodes.Elements() {
+		vv, ok := v.(types.String)
+		if !ok || vv.IsNull() || vv.IsUnknown() {
+			continue
+		}
+
+		value := strings.ToLower(vv.ValueString())
+		if !httpStatusCodePattern.MatchString(value) {
+			return nil, fmt.Errorf("expected status code %q must be a status code such as 200 or a class of status codes such as 2xx", vv.ValueString())
+		}
+
+		if class, ok := strings.CutSuffix(value, "xx"); ok {
+			n, _ := strconv.Atoi(class)
+			ranges = append(ranges, httpStatusCodeRange{min: n * 100, max: n*100 + 99})
+			continue
+		}
+
+		n, _ := strconv.Atoi(value)
+		ranges = append(ranges, httpStatusCodeRange{min: n, max: n})
+	}
+
+	return ranges, nil
+}
+
+// checkHttpStatusCode returns an error describing the response when its status
+// code isn't one of the expected ones. A nil ranges expects any status code.
+func checkHttpStatusCode(ranges []httpStatusCodeRange, statusCodes types.Set, statusCode int, body []byte) error {
+	if ranges == nil || slices.ContainsFunc(ranges, func(r httpStatusCodeRange) bool {
+		return statusCode >= r.min && statusCode <= r.max
+	}) {
+		return nil
+	}
+
+	expected := []string{}
+	for _, v := range statusCodes.Elements() {
+		if vv, ok := v.(types.String); ok {
+			expected = append(expected, vv.ValueString())
+		}
+	}
+	slices.Sort(expected)
+
+	return fmt.Errorf("unexpected status code %d, expected %s, response body: %q", statusCode, strings.Join(expected, ", "), newHttpResponseSnippet(body))
+}
+
+// newHttpResponseSnippet returns the start of a response body, with anything
+// looking like a token or secret redacted.
+func newHttpResponseSnippet(body []byte) string {
+	snippet := string(body)
+	for _, r := range httpResponseRedactions {
+		snippet = r.pattern.ReplaceAllString(snippet, r.replacement)
+	}
+
+	if len(snippet) <= httpResponseSnippetLength {
+		return snippet
+	}
+
+	n := httpResponseSnippetLength
+	for n > 0 && !utf8.RuneStart(snippet[n]) {
+		n--
+	}
+
+	return snippet[:n] + "..."
+}
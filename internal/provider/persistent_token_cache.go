@@ -0,0 +1,289 @@
+package provider
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// defaultPersistentTokenCacheKeyEnvVar is the environment variable the key of
+// the persistent token cache is read from, unless key_env_var or key_file is
+// set.
+const defaultPersistentTokenCacheKeyEnvVar = "AZIDENTITY_TOKEN_CACHE_KEY"
+
+// persistentTokenCacheLockMinDelay and persistentTokenCacheLockMaxDelay bound
+// the backoff between the attempts to take the lock of the cache file.
+const (
+	persistentTokenCacheLockMinDelay = 10 * time.Millisecond
+	persistentTokenCacheLockMaxDelay = 250 * time.Millisecond
+)
+
+// errPersistentTokenCacheLocked is returned when the lock of the cache file
+// isn't released by another process in time.
+var errPersistentTokenCacheLocked = errors.New("token cache is locked by another process")
+
+// persistentTokenCacheAdditionalData binds the encrypted content to the file
+// format, so it can't be confused with other data encrypted with the key.
+var persistentTokenCacheAdditionalData = []byte("azidentity-token-cache-v1")
+
+// persistentTokenCacheCredentialTypes are the credentials whose tokens are
+// persisted. Their identity is fully determined by the configuration, and the
+// key of a token includes a hash of the secret or assertion, so a token is
+// never loaded for another identity or after the secret changed.
+var persistentTokenCacheCredentialTypes = map[credentialType]bool{
+	clientSecretCredential:    true,
+	clientAssertionCredential: true,
+}
+
+// persistentTokenCache stores tokens in a file encrypted with AES-256-GCM,
+// shared by the Terraform processes using the same file. Every access holds an
+// exclusive lock on a lock file next to it, waiting for it at most until ctx
+// is done, and the file is replaced atomically when written.
+type persistentTokenCache struct {
+	path string
+	aead cipher.AEAD
+}
+
+type persistentTokenCacheEntry struct {
+	Token     string    `json:"token"`
+	ExpiresOn time.Time `json:"expires_on"`
+	RefreshOn time.Time `json:"refresh_on,omitzero"`
+}
+
+type providerPersistentTokenCacheModel struct {
+	Path      types.String `tfsdk:"path"`
+	KeyEnvVar types.String `tfsdk:"key_env_var"`
+	KeyFile   types.String `tfsdk:"key_file"`
+}
+
+// newPersistentTokenCache reads the key from the key file or the environment
+// variable and returns the persistent token cache.
+func (m providerPersistentTokenCacheModel) newPersistentTokenCache() (*persistentTokenCache, error) {
+	var encodedKey string
+	if keyFile := m.KeyFile.ValueString(); keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		encodedKey = string(content)
+	} else {
+		keyEnvVar := m.KeyEnvVar.ValueString()
+		if keyEnvVar == "" {
+			keyEnvVar = defaultPersistentTokenCacheKeyEnvVar
+		}
+
+		encodedKey = os.Getenv(keyEnvVar)
+		if encodedKey == "" {
+			return nil, fmt.Errorf("no key specified, set the %s environment variable or key_file", keyEnvVar)
+		}
+	}
+
+	key, err := parsePersistentTokenCacheKey(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPersistentTokenCache(m.Path.ValueString(), key)
+}
+
+func newPersistentTokenCache(path string, key []byte) (*persistentTokenCache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return &persistentTokenCache{
+		path: path,
+		aead: aead,
+	}, nil
+}
+
+// parsePersistentTokenCacheKey decodes a base64 encoded 256-bit key, such as
+// generated by 'openssl rand -base64 32'.
+func parsePersistentTokenCacheKey(input string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key as base64: %w", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expected a 32 byte key, got %d bytes", len(key))
+	}
+
+	return key, nil
+}
+
+// load returns the token cached under key, if any.
+func (c *persistentTokenCache) load(ctx context.Context, key string) (azcore.AccessToken, bool, error) {
+	var entry persistentTokenCacheEntry
+	var ok bool
+	err := c.withLock(ctx, func() error {
+		entries, err := c.read()
+		if err != nil {
+			return err
+		}
+
+		entry, ok = entries[key]
+
+		return nil
+	})
+	if err != nil || !ok {
+		return azcore.AccessToken{}, false, err
+	}
+
+	return azcore.AccessToken{
+		Token:     entry.Token,
+		ExpiresOn: entry.ExpiresOn,
+		RefreshOn: entry.RefreshOn,
+	}, true, nil
+}
+
+// store caches token under key, dropping expired tokens. A file that can't be
+// decrypted, for example because the key was rotated, is replaced.
+func (c *persistentTokenCache) store(ctx context.Context, key string, token azcore.AccessToken) error {
+	return c.withLock(ctx, func() error {
+		entries, err := c.read()
+		if err != nil {
+			entries = map[string]persistentTokenCacheEntry{}
+		}
+
+		now := time.Now()
+		for k, entry := range entries {
+			if !entry.ExpiresOn.After(now) {
+				delete(entries, k)
+			}
+		}
+
+		entries[key] = persistentTokenCacheEntry{
+			Token:     token.Token,
+			ExpiresOn: token.ExpiresOn,
+			RefreshOn: token.RefreshOn,
+		}
+
+		return c.write(entries)
+	})
+}
+
+// withLock runs fn holding the lock of the cache file. The lock is polled with
+// a backoff rather than blocking, so a process holding it can't stall the
+// caller past the deadline of ctx, or the default timeout when ctx has none.
+func (c *persistentTokenCache) withLock(ctx context.Context, fn func() error) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	err := os.MkdirAll(filepath.Dir(c.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create token cache directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(c.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open token cache lock file: %w", err)
+	}
+	defer func() { _ = lock.Close() }()
+
+	delay := persistentTokenCacheLockMinDelay
+	for {
+		locked, err := tryLockFile(lock)
+		if err != nil {
+			return fmt.Errorf("failed to lock token cache: %w", err)
+		}
+		if locked {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", errPersistentTokenCacheLocked, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, persistentTokenCacheLockMaxDelay)
+	}
+	defer func() { _ = unlockFile(lock) }()
+
+	return fn()
+}
+
+func (c *persistentTokenCache) read() (map[string]persistentTokenCacheEntry, error) {
+	entries := map[string]persistentTokenCacheEntry{}
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token cache: %w", err)
+	}
+
+	nonceSize := c.aead.NonceSize()
+	if len(content) < nonceSize {
+		return nil, errors.New("failed to decrypt token cache: file is too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, content[:nonceSize], content[nonceSize:], persistentTokenCacheAdditionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token cache, the key may have changed: %w", err)
+	}
+
+	err = json.Unmarshal(plaintext, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token cache: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (c *persistentTokenCache) write(entries map[string]persistentTokenCacheEntry) error {
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token cache: %w", err)
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	content := c.aead.Seal(nonce, nonce, plaintext, persistentTokenCacheAdditionalData)
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create token cache: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), c.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace token cache: %w", err)
+	}
+
+	return nil
+}
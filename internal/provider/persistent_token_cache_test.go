@@ -0,0 +1,510 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func testNewPersistentTokenCacheKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	return key
+}
+
+func TestPersistentTokenCache(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "ze-dir", "tokens")
+	key := testNewPersistentTokenCacheKey(t)
+
+	cache, err := newPersistentTokenCache(cachePath, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, ok, err := cache.load(t.Context(), "ze-key")
+	if err != nil || ok {
+		t.Fatalf("expected no token in an empty cache, got %t and %v", ok, err)
+	}
+
+	expiresOn := time.Now().Add(time.Hour).Truncate(time.Second)
+	err = cache.store(t.Context(), "ze-key", azcore.AccessToken{Token: "ze-token", ExpiresOn: expiresOn})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = cache.store(t.Context(), "ze-expired-key", azcore.AccessToken{Token: "ze-expired-token", ExpiresOn: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("failed to read cache file: %s", err)
+	}
+
+	if strings.Contains(string(content), "ze-token") {
+		t.Errorf("expected the cache file to be encrypted")
+	}
+
+	// A second instance reads the tokens stored by the first, as another
+	// Terraform process would.
+	other, err := newPersistentTokenCache(cachePath, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	token, ok, err := other.load(t.Context(), "ze-key")
+	if err != nil || !ok {
+		t.Fatalf("expected a cached token, got %t and %v", ok, err)
+	}
+
+	if token.Token != "ze-token" || !token.ExpiresOn.Equal(expiresOn) {
+		t.Errorf("unexpected token %q expiring on %s", token.Token, token.ExpiresOn)
+	}
+
+	err = other.store(t.Context(), "ze-other-key", azcore.AccessToken{Token: "ze-other-token", ExpiresOn: expiresOn})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, ok, err = other.load(t.Context(), "ze-expired-key")
+	if err != nil || ok {
+		t.Errorf("expected expired tokens to be dropped, got %t and %v", ok, err)
+	}
+
+	wrongKey, err := newPersistentTokenCache(cachePath, testNewPersistentTokenCacheKey(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, _, err = wrongKey.load(t.Context(), "ze-key")
+	if err == nil || !strings.Contains(err.Error(), "failed to decrypt token cache") {
+		t.Errorf("expected a decryption error, got %v", err)
+	}
+
+	// Storing with another key replaces the cache.
+	err = wrongKey.store(t.Context(), "ze-key", azcore.AccessToken{Token: "ze-new-token", ExpiresOn: expiresOn})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	token, ok, err = wrongKey.load(t.Context(), "ze-key")
+	if err != nil || !ok || token.Token != "ze-new-token" {
+		t.Errorf("expected the replaced token, got %q, %t and %v", token.Token, ok, err)
+	}
+}
+
+func TestPersistentTokenCacheConcurrent(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "tokens")
+	key := testNewPersistentTokenCacheKey(t)
+	expiresOn := time.Now().Add(time.Hour)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Go(func() {
+			cache, err := newPersistentTokenCache(cachePath, key)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+
+			err = cache.store(t.Context(), fmt.Sprintf("ze-key-%d", i), azcore.AccessToken{Token: fmt.Sprintf("ze-token-%d", i), ExpiresOn: expiresOn})
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	cache, err := newPersistentTokenCache(cachePath, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := range 10 {
+		token, ok, err := cache.load(t.Context(), fmt.Sprintf("ze-key-%d", i))
+		if err != nil || !ok || token.Token != fmt.Sprintf("ze-token-%d", i) {
+			t.Errorf("expected token %d to be cached, got %q, %t and %v", i, token.Token, ok, err)
+		}
+	}
+}
+
+func TestPersistentTokenCacheLocked(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "tokens")
+	persistent, err := newPersistentTokenCache(cachePath, testNewPersistentTokenCacheKey(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Another process holding the lock.
+	lock, err := os.OpenFile(cachePath+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open lock file: %s", err)
+	}
+	defer func() { _ = lock.Close() }()
+
+	locked, err := tryLockFile(lock)
+	if err != nil || !locked {
+		t.Fatalf("failed to lock: %t and %v", locked, err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	_, _, err = persistent.load(ctx, "ze-key")
+	if !errors.Is(err, errPersistentTokenCacheLocked) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a locked error, got %v", err)
+	}
+
+	// Tokens are still requested and cached in memory, without waiting for the
+	// lock longer than the timeout of the credential.
+	var requests atomic.Int32
+	getCredFn := testNewCountingCredentialFn(&requests, time.Hour)
+	cfg := credentialConfig{
+		ClientID:     "ze-client",
+		ClientSecret: "ze-secret",
+		Scopes:       []string{"ze-scope"},
+		Timeout:      100 * time.Millisecond,
+	}
+
+	cache := newTokenCache(defaultTokenCacheRefreshMargin)
+	cache.setPersistent(persistent)
+	for i := range 2 {
+		token, cacheHit, _, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if cacheHit != (i == 1) || token.Token != "ze-token-1" {
+			t.Errorf("request %d: unexpected token %q with cache hit %t", i, token.Token, cacheHit)
+		}
+	}
+
+	_, err = os.Stat(cachePath)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the locked cache not to be written, got %v", err)
+	}
+
+	// The cache is used again once the lock is released.
+	err = unlockFile(lock)
+	if err != nil {
+		t.Fatalf("failed to unlock: %s", err)
+	}
+
+	err = persistent.store(t.Context(), "ze-key", azcore.AccessToken{Token: "ze-token", ExpiresOn: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 token request, got %d", requests.Load())
+	}
+}
+
+func TestParsePersistentTokenCacheKey(t *testing.T) {
+	cases := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:  "valid",
+			input: base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\n",
+		},
+		{
+			name:          "invalid_base64",
+			input:         "ze-invalid",
+			expectedError: "failed to decode key as base64",
+		},
+		{
+			name:          "short",
+			input:         base64.StdEncoding.EncodeToString(make([]byte, 16)),
+			expectedError: "expected a 32 byte key, got 16 bytes",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parsePersistentTokenCacheKey(c.input)
+			if c.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+				t.Errorf("expected error containing %q, got %v", c.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGetCachedTokenPersistent(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "tokens")
+	persistent, err := newPersistentTokenCache(cachePath, testNewPersistentTokenCacheKey(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var requests atomic.Int32
+	getCredFn := testNewCountingCredentialFn(&requests, time.Hour)
+	cfg := credentialConfig{
+		ClientID:     "ze-client",
+		ClientSecret: "ze-secret",
+		Scopes:       []string{"ze-scope"},
+		Timeout:      defaultTimeout,
+	}
+
+	for i := range 2 {
+		// Every iteration is a new provider instance, as in another Terraform run.
+		cache := newTokenCache(defaultTokenCacheRefreshMargin)
+		cache.setPersistent(persistent)
+
+		token, cacheHit, _, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if cacheHit != (i == 1) || token.Token != "ze-token-1" {
+			t.Errorf("run %d: unexpected token %q with cache hit %t", i, token.Token, cacheHit)
+		}
+	}
+
+	// A changed secret doesn't load the token of the previous one.
+	cache := newTokenCache(defaultTokenCacheRefreshMargin)
+	cache.setPersistent(persistent)
+	otherCfg := cfg
+	otherCfg.ClientSecret = "ze-other-secret"
+	_, cacheHit, _, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, otherCfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if cacheHit {
+		t.Errorf("expected the token of another secret not to be loaded")
+	}
+
+	// Tokens of credentials that aren't persisted are only cached in memory.
+	for _, credType := range []credentialType{azureCLICredential, defaultCredential} {
+		for range 2 {
+			cache := newTokenCache(defaultTokenCacheRefreshMargin)
+			cache.setPersistent(persistent)
+
+			_, cacheHit, _, err := getCachedToken(t.Context(), cache, credType, getCredFn, cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if cacheHit {
+				t.Errorf("expected %s tokens not to be persisted", credType)
+			}
+		}
+	}
+
+	if requests.Load() != 6 {
+		t.Errorf("expected 6 token requests, got %d", requests.Load())
+	}
+}
+
+func TestEphemeralCredentialPersistentTokenCache(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	cacheDir := t.TempDir()
+	cachePath := filepath.Join(cacheDir, "tokens")
+	t.Setenv("ZE_TOKEN_CACHE_KEY", base64.StdEncoding.EncodeToString(testNewPersistentTokenCacheKey(t)))
+
+	var requests atomic.Int32
+	config := fmt.Sprintf(`
+provider "azidentity" {
+	persistent_token_cache = {
+		path        = %q
+		key_env_var = "ZE_TOKEN_CACHE_KEY"
+	}
+}
+
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this
+}
+
+resource "echo" "this" {}
+`, cachePath)
+
+	for _, expectedCacheHit := range []bool{false, true} {
+		// The first run caches the token on disk, the second run reuses it.
+		if !expectedCacheHit {
+			requests.Store(0)
+		}
+
+		resource.Test(t, resource.TestCase{
+			TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+				tfversion.SkipBelow(tfversion.Version1_10_0),
+			},
+			IsUnitTest:               true,
+			ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewCountingCredentialFn(&requests, time.Hour)),
+			Steps: []resource.TestStep{
+				{
+					Config: config,
+					ConfigStateChecks: []statecheck.StateCheck{
+						statecheck.ExpectKnownValue(
+							"echo.this",
+							tfjsonpath.New("data").AtMapKey("access_token"),
+							knownvalue.StringExact("ze-token-1"),
+						),
+					},
+				},
+			},
+		})
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected a single token request across runs, got %d", requests.Load())
+	}
+}
+
+func TestEphemeralCredentialPersistentTokenCacheDisabled(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	cacheDir := t.TempDir()
+	t.Setenv("AZIDENTITY_TOKEN_CACHE_KEY", base64.StdEncoding.EncodeToString(testNewPersistentTokenCacheKey(t)))
+	t.Chdir(cacheDir)
+
+	var requests atomic.Int32
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewCountingCredentialFn(&requests, time.Hour)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this.access_token
+}
+
+resource "echo" "this" {}
+`,
+			},
+		},
+	})
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %s", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected nothing to be written without persistent_token_cache, got %d entries", len(entries))
+	}
+}
+
+func TestEphemeralCredentialPersistentTokenCacheInvalid(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	cachePath := filepath.Join(t.TempDir(), "tokens")
+	keyFilePath := filepath.Join(t.TempDir(), "key")
+	err := os.WriteFile(keyFilePath, []byte("ze-invalid-key"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write key file: %s", err)
+	}
+
+	t.Setenv("AZIDENTITY_TOKEN_CACHE_KEY", "")
+
+	cases := []struct {
+		name          string
+		attributes    string
+		expectedError string
+	}{
+		{
+			name:          "missing_key",
+			attributes:    "",
+			expectedError: "no key specified, set the AZIDENTITY_TOKEN_CACHE_KEY environment\\s+variable\\s+or\\s+key_file",
+		},
+		{
+			name:          "invalid_key_file",
+			attributes:    fmt.Sprintf("key_file = %q", keyFilePath),
+			expectedError: "failed to decode key as base64",
+		},
+		{
+			name:          "conflicting_key_sources",
+			attributes:    fmt.Sprintf("key_file = %q\n\t\tkey_env_var = \"ZE_KEY\"", keyFilePath),
+			expectedError: "Invalid Attribute Combination",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+				Steps: []resource.TestStep{
+					{
+						Config: fmt.Sprintf(`
+provider "azidentity" {
+	persistent_token_cache = {
+		path = %q
+		%s
+	}
+}
+
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this.access_token
+}
+
+resource "echo" "this" {}
+`, cachePath, c.attributes),
+						ExpectError: regexp.MustCompile(c.expectedError),
+					},
+				},
+			})
+		})
+	}
+}
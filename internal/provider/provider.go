@@ -2,14 +2,23 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 )
 
 var _ provider.Provider = &azidentityProvider{}
@@ -17,13 +26,39 @@ var _ provider.ProviderWithFunctions = &azidentityProvider{}
 var _ provider.ProviderWithEphemeralResources = &azidentityProvider{}
 
 type azidentityProvider struct {
-	version    string
-	getCredFn  getCredentialFn
-	httpClient *http.Client
-	runCmdFn   runCommandFn
+	version       string
+	getCredFn     getCredentialFn
+	httpClient    *http.Client
+	runCmdFn      runCommandFn
+	defaults      providerDefaults
+	cloudMetadata *cloudMetadataCache
+	tokenCache    *tokenCache
+	// httpCloseRequests keeps the close requests of the open HTTP request
+	// resources.
+	httpCloseRequests *httpCloseRequestStore
 }
 
-type AzidentityProviderModel struct{}
+type AzidentityProviderModel struct {
+	Cloud                        types.String `tfsdk:"cloud"`
+	TenantID                     types.String `tfsdk:"tenant_id"`
+	Timeout                      types.String `tfsdk:"timeout"`
+	DisableInstanceDiscovery     types.Bool   `tfsdk:"disable_instance_discovery"`
+	AdditionallyAllowedTenants   types.Set    `tfsdk:"additionally_allowed_tenants"`
+	ActiveDirectoryAuthorityHost types.String `tfsdk:"active_directory_authority_host"`
+	CloudServices                types.Map    `tfsdk:"cloud_services"`
+	MaxRetries                   types.Int64  `tfsdk:"max_retries"`
+	RetryDelay                   types.String `tfsdk:"retry_delay"`
+	MaxRetryDelay                types.String `tfsdk:"max_retry_delay"`
+	RetryStatusCodes             types.Set    `tfsdk:"retry_status_codes"`
+	ProxyURL                     types.String `tfsdk:"proxy_url"`
+	NoProxy                      types.String `tfsdk:"no_proxy"`
+	CACertificatesPEM            types.String `tfsdk:"ca_certificates_pem"`
+	ClientCertificate            types.String `tfsdk:"client_certificate"`
+	ClientCertificatePassword    types.String `tfsdk:"client_certificate_password"`
+	TokenCacheRefreshMargin      types.String `tfsdk:"token_cache_refresh_margin"`
+	PersistentTokenCache         types.Object `tfsdk:"persistent_token_cache"`
+	RenewMargin                  types.String `tfsdk:"renew_margin"`
+}
 
 func (p *azidentityProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
 	resp.TypeName = "azidentity"
@@ -32,7 +67,133 @@ func (p *azidentityProvider) Metadata(ctx context.Context, req provider.Metadata
 
 func (p *azidentityProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Attributes: map[string]schema.Attribute{},
+		MarkdownDescription: "The provider attributes are defaults for the credential resources, which resource-level attributes override. Unset attributes fall back to the environment variables named below.",
+		Attributes: map[string]schema.Attribute{
+			"cloud": schema.StringAttribute{
+				MarkdownDescription: "Cloud specifies the default cloud of the credentials, either AzurePublic, AzureChina, AzureGovernment or the https URL of an ARM metadata endpoint, such as https://management.local.azurestack.external/metadata/endpoints. Falls back to the environment variable AZIDENTITY_CLOUD. The default is AzurePublic.",
+				Optional:            true,
+				Validators: []validator.String{
+					newCloudValidator(),
+				},
+			},
+			"tenant_id": schema.StringAttribute{
+				MarkdownDescription: "TenantID sets the default tenant of the credentials. Falls back to the environment variable AZURE_TENANT_ID, except for the Azure CLI, Azure Developer CLI and default credentials, which keep the tenant of the CLI login or read AZURE_TENANT_ID themselves.",
+				Optional:            true,
+			},
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout sets the default maximum time allowed for acquiring a token, in the same format as the `timeout` attribute of the credential resources, e.g. '30s'. Falls back to the environment variable AZIDENTITY_TIMEOUT. The default is 30 seconds ('30s').",
+				Optional:            true,
+			},
+			"disable_instance_discovery": schema.BoolAttribute{
+				MarkdownDescription: "DisableInstanceDiscovery sets the default of `disable_instance_discovery` for the credentials. It should be set true only by applications authenticating in disconnected clouds, or private clouds such as Azure Stack. Falls back to the environment variable AZURE_DISABLE_INSTANCE_DISCOVERY. The default is false.",
+				Optional:            true,
+			},
+			"additionally_allowed_tenants": schema.SetAttribute{
+				MarkdownDescription: "AdditionallyAllowedTenants sets the default tenants to which the credentials may authenticate, in addition to the tenant ID. Add the wildcard value '*' to allow authenticating to any tenant. Falls back to the environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS, a semicolon delimited list of tenants.",
+				Optional:            true,
+				ElementType:         types.StringType,
+			},
+			"active_directory_authority_host": schema.StringAttribute{
+				MarkdownDescription: "ActiveDirectoryAuthorityHost replaces the Microsoft Entra authority host of the cloud for all credentials, e.g. https://login.microsoftonline.com/ or the authority of an Azure Stack Hub or ADFS deployment. Falls back to the environment variable AZURE_AUTHORITY_HOST. The default is the authority host of the cloud.",
+				Optional:            true,
+			},
+			"cloud_services": schema.MapNestedAttribute{
+				MarkdownDescription: "CloudServices adds or replaces the configuration of services of the cloud for all credentials, keyed by service name, e.g. `resourceManager` for Azure Resource Manager. Together with `active_directory_authority_host` this describes a custom cloud. The default is the services of the cloud.",
+				Optional:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"audience": schema.StringAttribute{
+							MarkdownDescription: "Audience is the audience the service accepts, e.g. https://management.azure.com.",
+							Required:            true,
+						},
+						"endpoint": schema.StringAttribute{
+							MarkdownDescription: "Endpoint is the base URL of the service, e.g. https://management.azure.com.",
+							Required:            true,
+						},
+					},
+				},
+			},
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "MaxRetries is the default maximum number of times a failed HTTP request of a credential is retried, 0 disables retries. The default is 3, and 6 for managed identities.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+				},
+			},
+			"retry_delay": schema.StringAttribute{
+				MarkdownDescription: "RetryDelay is the default initial delay before retrying a failed HTTP request of a credential, increasing exponentially with each retry up to MaxRetryDelay. It's only used when the response doesn't contain a Retry-After header. The default is '800ms', and '2s' for managed identities.",
+				Optional:            true,
+			},
+			"max_retry_delay": schema.StringAttribute{
+				MarkdownDescription: "MaxRetryDelay is the default maximum delay before retrying a failed HTTP request of a credential. The default is '60s', and '25s' for managed identities.",
+				Optional:            true,
+			},
+			"retry_status_codes": schema.SetAttribute{
+				MarkdownDescription: "RetryStatusCodes are the default HTTP status codes retried by the credentials, an empty set only retries on network errors. The default is 408, 429, 500, 502, 503 and 504, and for managed identities 404, 410, 429 and 5xx.",
+				Optional:            true,
+				ElementType:         types.Int64Type,
+				Validators: []validator.Set{
+					setvalidator.ValueInt64sAre(int64validator.Between(100, 599)),
+				},
+			},
+			"proxy_url": schema.StringAttribute{
+				MarkdownDescription: "ProxyURL is the URL of the proxy used for all requests of the provider, e.g. http://proxy.example.com:3128. The default is the HTTPS_PROXY and HTTP_PROXY environment variables.",
+				Optional:            true,
+			},
+			"no_proxy": schema.StringAttribute{
+				MarkdownDescription: "NoProxy is a comma separated list of hosts, domains and IP ranges requested without the proxy, e.g. 'localhost,.internal.example.com,10.0.0.0/8'. The default is the NO_PROXY environment variable.",
+				Optional:            true,
+			},
+			"ca_certificates_pem": schema.StringAttribute{
+				MarkdownDescription: "CACertificatesPEM contains PEM encoded root certificates trusted in addition to the system roots, such as a private root CA of a TLS inspecting proxy.",
+				Optional:            true,
+			},
+			"client_certificate": schema.StringAttribute{
+				MarkdownDescription: "ClientCertificate is a client certificate presented to servers requiring mutual TLS, either a PEM encoded certificate and private key or a base64 encoded PKCS#12 archive.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"client_certificate_password": schema.StringAttribute{
+				MarkdownDescription: "ClientCertificatePassword is the password of the client certificate, if it's encrypted.",
+				Optional:            true,
+				Sensitive:           true,
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRoot("client_certificate")),
+				},
+			},
+			"token_cache_refresh_margin": schema.StringAttribute{
+				MarkdownDescription: "TokenCacheRefreshMargin is how long before their expiry the tokens cached by the provider are requested again. Credential resources requesting a token for the same credential type, tenant, client, secret, scopes, claims and CAE setting reuse the cached token, as reported by their `cache_hit` attribute. The default is 5 minutes ('5m').",
+				Optional:            true,
+			},
+			"renew_margin": schema.StringAttribute{
+				MarkdownDescription: "RenewMargin is how long before their expiry Terraform renews the credential resources during long operations, requesting a new token with the same parameters. Renewing only refreshes the tokens cached by the provider, so ephemeral resources opened afterwards get a valid token. It can't change the token Terraform already holds, nor the values passed to other providers. The default is 5 minutes ('5m').",
+				Optional:            true,
+			},
+			"persistent_token_cache": schema.SingleNestedAttribute{
+				MarkdownDescription: "PersistentTokenCache opts in to storing the tokens of the `azidentity_client_secret_credential` and `azidentity_client_assertion_credential` resources in a file encrypted with AES-256-GCM, to reuse them across Terraform runs until they expire within `token_cache_refresh_margin`. Tokens are keyed on the tenant, client and a hash of the secret or assertion, so changing either requests a new token. A persisted token stays usable until it expires even if its secret is revoked, as tokens issued by Microsoft Entra ID do. Tokens of the other credentials, including `azidentity_default_credential` whose identity depends on the environment, are only cached in memory. Concurrent Terraform processes using the same file are serialised with a lock file next to it, and a credential that can't take the lock within its `timeout` only caches its token in memory. Nothing is read from or written to disk when this isn't set.",
+				Optional:            true,
+				Attributes: map[string]schema.Attribute{
+					"path": schema.StringAttribute{
+						MarkdownDescription: "Path is the path of the cache file, created with its directory if missing, e.g. '${pathexpand(\"~/.cache/azidentity/tokens\")}'.",
+						Required:            true,
+						Validators: []validator.String{
+							stringvalidator.LengthAtLeast(1),
+						},
+					},
+					"key_env_var": schema.StringAttribute{
+						MarkdownDescription: "KeyEnvVar is the environment variable containing the base64 encoded 256-bit encryption key, such as generated by 'openssl rand -base64 32'. The default is AZIDENTITY_TOKEN_CACHE_KEY.",
+						Optional:            true,
+						Validators: []validator.String{
+							stringvalidator.ConflictsWith(path.MatchRelative().AtParent().AtName("key_file")),
+						},
+					},
+					"key_file": schema.StringAttribute{
+						MarkdownDescription: "KeyFile is the path of a file containing the base64 encoded 256-bit encryption key, instead of reading it from an environment variable.",
+						Optional:            true,
+					},
+				},
+			},
+		},
 	}
 }
 
@@ -45,6 +206,95 @@ func (p *azidentityProvider) Configure(ctx context.Context, req provider.Configu
 		return
 	}
 
+	transportConfig := providerTransportConfig{
+		ProxyURL:                  data.ProxyURL.ValueString(),
+		NoProxy:                   data.NoProxy.ValueString(),
+		CACertificatesPEM:         data.CACertificatesPEM.ValueString(),
+		ClientCertificate:         data.ClientCertificate.ValueString(),
+		ClientCertificatePassword: data.ClientCertificatePassword.ValueString(),
+	}
+	if transportConfig.isSet() {
+		httpClient, err := newProviderHTTPClient(transportConfig)
+		if err != nil {
+			resp.Diagnostics.AddError("Invalid Transport Configuration", err.Error())
+			return
+		}
+
+		p.httpClient = httpClient
+		p.cloudMetadata = nil
+	}
+
+	defaults, diags := newProviderDefaults(ctx, data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if p.cloudMetadata == nil {
+		p.cloudMetadata = newCloudMetadataCache(p.httpClient)
+	}
+
+	refreshMargin := defaultTokenCacheRefreshMargin
+	if v := data.TokenCacheRefreshMargin.ValueString(); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("token_cache_refresh_margin"),
+				"Invalid Token Cache Refresh Margin",
+				fmt.Sprintf("The token cache refresh margin %q must be a non-negative duration, such as '5m'.", v),
+			)
+			return
+		}
+		refreshMargin = d
+	}
+
+	renewMargin := defaultRenewMargin
+	if v := data.RenewMargin.ValueString(); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("renew_margin"),
+				"Invalid Renew Margin",
+				fmt.Sprintf("The renew margin %q must be a non-negative duration, such as '5m'.", v),
+			)
+			return
+		}
+		renewMargin = d
+	}
+
+	var persistent *persistentTokenCache
+	if !data.PersistentTokenCache.IsNull() {
+		var persistentData providerPersistentTokenCacheModel
+		resp.Diagnostics.Append(data.PersistentTokenCache.As(ctx, &persistentData, basetypes.ObjectAsOptions{})...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		var err error
+		persistent, err = persistentData.newPersistentTokenCache()
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("persistent_token_cache"),
+				"Invalid Persistent Token Cache",
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if p.tokenCache == nil {
+		p.tokenCache = newTokenCache(refreshMargin)
+	}
+	p.tokenCache.setRefreshMargin(refreshMargin)
+	p.tokenCache.setPersistent(persistent)
+
+	if p.httpCloseRequests == nil {
+		p.httpCloseRequests = newHttpCloseRequestStore()
+	}
+
+	defaults.CloudMetadata = p.cloudMetadata
+	defaults.RenewMargin = renewMargin
+	p.defaults = defaults
 	resp.EphemeralResourceData = p
 }
 
@@ -56,11 +306,21 @@ func (p *azidentityProvider) EphemeralResources(ctx context.Context) []func() ep
 	return []func() ephemeral.EphemeralResource{
 		newEphemeralAzureCLIAccount,
 		newEphemeralAzureCLICredential,
+		newEphemeralAzureDeveloperCLICredential,
+		newEphemeralAzurePipelinesCredential,
+		newEphemeralChainedCredential,
 		newEphemeralClientAssertionCredential,
+		newEphemeralClientCertificateCredential,
 		newEphemeralClientSecretCredential,
 		newEphemeralDefaultCredential,
+		newEphemeralEnvironmentCredential,
 		newEphemeralEnvironmentVariable,
+		newEphemeralGitHubActionsCredential,
 		newEphemeralHttpRequest,
+		newEphemeralManagedIdentityCredential,
+		newEphemeralOnBehalfOfCredential,
+		newEphemeralUsernamePasswordCredential,
+		newEphemeralWorkloadIdentityCredential,
 	}
 }
 
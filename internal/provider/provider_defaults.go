@@ -0,0 +1,295 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	providerDefaultsSourceConfig = "provider configuration"
+	providerDefaultsSourceEnv    = "environment variable %s"
+)
+
+// providerDefaults are the provider-level defaults for the credential
+// resources, merged below the values configured on each resource. Sources
+// records where each default came from, keyed by attribute name.
+type providerDefaults struct {
+	Cloud                      types.String
+	TenantID                   types.String
+	Timeout                    types.String
+	DisableInstanceDiscovery   types.Bool
+	AdditionallyAllowedTenants types.Set
+	MaxRetries                 types.Int64
+	RetryDelay                 types.String
+	MaxRetryDelay              types.String
+	RetryStatusCodes           types.Set
+	// ActiveDirectoryAuthorityHost and CloudServices replace the respective
+	// parts of the configuration of any cloud, for custom and private clouds.
+	ActiveDirectoryAuthorityHost types.String
+	CloudServices                map[cloud.ServiceName]cloud.ServiceConfiguration
+	// CloudMetadata loads clouds given as ARM metadata URLs, shared by all
+	// resources of a provider instance.
+	CloudMetadata *cloudMetadataCache
+	// RenewMargin is how long before the expiry of their token the credential
+	// resources are renewed.
+	RenewMargin time.Duration
+	Sources     map[string]string
+}
+
+type providerCloudServiceModel struct {
+	Audience types.String `tfsdk:"audience"`
+	Endpoint types.String `tfsdk:"endpoint"`
+}
+
+// newProviderDefaults resolves the defaults from the provider configuration,
+// falling back to the standard AZURE_* environment variables for unset
+// attributes. The cloud and timeout, which have no standard variable, fall
+// back to AZIDENTITY_* variables instead.
+func newProviderDefaults(ctx context.Context, data AzidentityProviderModel) (providerDefaults, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	defaults := providerDefaults{
+		Cloud:                      data.Cloud,
+		TenantID:                   data.TenantID,
+		Timeout:                    data.Timeout,
+		DisableInstanceDiscovery:   data.DisableInstanceDiscovery,
+		AdditionallyAllowedTenants: data.AdditionallyAllowedTenants,
+		MaxRetries:                 data.MaxRetries,
+		RetryDelay:                 data.RetryDelay,
+		MaxRetryDelay:              data.MaxRetryDelay,
+		RetryStatusCodes:           data.RetryStatusCodes,
+		Sources:                    map[string]string{},
+	}
+
+	defaults.Cloud = stringDefaultOrEnv(defaults.Sources, "cloud", defaults.Cloud, "AZIDENTITY_CLOUD")
+	if v := defaults.Cloud.ValueString(); isCloudMetadataURL(v) {
+		if _, err := url.Parse(v); err != nil {
+			diags.AddAttributeError(
+				path.Root("cloud"),
+				"Invalid Cloud",
+				fmt.Sprintf("The ARM metadata URL from the %s is invalid: %s", defaults.Sources["cloud"], err),
+			)
+		}
+	} else if _, err := getCloudConfig(v); err != nil {
+		diags.AddAttributeError(
+			path.Root("cloud"),
+			"Invalid Cloud",
+			fmt.Sprintf("The cloud from the %s is invalid: %s", defaults.Sources["cloud"], err),
+		)
+	}
+
+	defaults.ActiveDirectoryAuthorityHost = stringDefaultOrEnv(defaults.Sources, "active_directory_authority_host", data.ActiveDirectoryAuthorityHost, "AZURE_AUTHORITY_HOST")
+	if v := defaults.ActiveDirectoryAuthorityHost.ValueString(); v != "" {
+		if u, err := url.Parse(v); err != nil || u.Scheme != "https" || u.Host == "" {
+			diags.AddAttributeError(
+				path.Root("active_directory_authority_host"),
+				"Invalid Authority Host",
+				fmt.Sprintf("The authority host %q from the %s must be an https URL, e.g. https://login.microsoftonline.com/ or https://adfs.contoso.com/.", v, defaults.Sources["active_directory_authority_host"]),
+			)
+		}
+	}
+
+	if !data.CloudServices.IsNull() {
+		services := map[string]providerCloudServiceModel{}
+		diags.Append(data.CloudServices.ElementsAs(ctx, &services, false)...)
+		defaults.CloudServices = map[cloud.ServiceName]cloud.ServiceConfiguration{}
+		for name, service := range services {
+			defaults.CloudServices[cloud.ServiceName(name)] = cloud.ServiceConfiguration{
+				Audience: service.Audience.ValueString(),
+				Endpoint: service.Endpoint.ValueString(),
+			}
+		}
+		defaults.Sources["cloud_services"] = providerDefaultsSourceConfig
+	}
+
+	defaults.TenantID = stringDefaultOrEnv(defaults.Sources, "tenant_id", defaults.TenantID, "AZURE_TENANT_ID")
+	defaults.Timeout = stringDefaultOrEnv(defaults.Sources, "timeout", defaults.Timeout, "AZIDENTITY_TIMEOUT")
+
+	if defaults.DisableInstanceDiscovery.IsNull() {
+		if v := os.Getenv("AZURE_DISABLE_INSTANCE_DISCOVERY"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				diags.AddAttributeError(
+					path.Root("disable_instance_discovery"),
+					"Invalid Environment Variable",
+					fmt.Sprintf("Failed to parse AZURE_DISABLE_INSTANCE_DISCOVERY %q as a boolean: %s", v, err),
+				)
+			}
+			defaults.DisableInstanceDiscovery = types.BoolValue(b)
+			defaults.Sources["disable_instance_discovery"] = fmt.Sprintf(providerDefaultsSourceEnv, "AZURE_DISABLE_INSTANCE_DISCOVERY")
+		}
+	} else {
+		defaults.Sources["disable_instance_discovery"] = providerDefaultsSourceConfig
+	}
+
+	if defaults.AdditionallyAllowedTenants.IsNull() {
+		if v := os.Getenv("AZURE_ADDITIONALLY_ALLOWED_TENANTS"); v != "" {
+			tenants := []attr.Value{}
+			for _, tenant := range strings.Split(v, ";") {
+				if tenant = strings.TrimSpace(tenant); tenant != "" {
+					tenants = append(tenants, types.StringValue(tenant))
+				}
+			}
+			set, setDiags := types.SetValue(types.StringType, tenants)
+			diags.Append(setDiags...)
+			defaults.AdditionallyAllowedTenants = set
+			defaults.Sources["additionally_allowed_tenants"] = fmt.Sprintf(providerDefaultsSourceEnv, "AZURE_ADDITIONALLY_ALLOWED_TENANTS")
+		}
+	} else {
+		defaults.Sources["additionally_allowed_tenants"] = providerDefaultsSourceConfig
+	}
+
+	retryAttributes := map[string]attr.Value{
+		"max_retries":        defaults.MaxRetries,
+		"retry_delay":        defaults.RetryDelay,
+		"max_retry_delay":    defaults.MaxRetryDelay,
+		"retry_status_codes": defaults.RetryStatusCodes,
+	}
+	for name, value := range retryAttributes {
+		if !value.IsNull() {
+			defaults.Sources[name] = providerDefaultsSourceConfig
+		}
+	}
+
+	if _, err := newRetryConfig(defaults.MaxRetries, defaults.RetryDelay, defaults.MaxRetryDelay, defaults.RetryStatusCodes); err != nil {
+		diags.AddError("Invalid Retry Configuration", err.Error())
+	}
+
+	for name, source := range defaults.Sources {
+		tflog.Debug(ctx, fmt.Sprintf("Provider default %s set from the %s", name, source))
+	}
+
+	return defaults, diags
+}
+
+func stringDefaultOrEnv(sources map[string]string, name string, value types.String, key string) types.String {
+	if !value.IsNull() {
+		sources[name] = providerDefaultsSourceConfig
+		return value
+	}
+
+	if v := os.Getenv(key); v != "" {
+		sources[name] = fmt.Sprintf(providerDefaultsSourceEnv, key)
+		return types.StringValue(v)
+	}
+
+	return value
+}
+
+func (d providerDefaults) cloud(ctx context.Context, value types.String) types.String {
+	return mergeProviderDefault(ctx, "cloud", value, d.Cloud, d.Sources)
+}
+
+// cloudConfig returns the configuration of the effective cloud, with the
+// authority host and services replaced when set on the provider. Loading the
+// cloud from an ARM metadata endpoint is bounded by the effective timeout of
+// the resource, as acquiring the token is.
+func (d providerDefaults) cloudConfig(ctx context.Context, value types.String, timeout types.String) (cloud.Configuration, error) {
+	var cloudConfig cloud.Configuration
+	var err error
+	if name := d.cloud(ctx, value).ValueString(); isCloudMetadataURL(name) {
+		if d.CloudMetadata == nil {
+			return cloud.Configuration{}, fmt.Errorf("the provider must be configured to load the cloud from %s", name)
+		}
+
+		metadataTimeout := parseTimeout(ctx, d.timeout(ctx, timeout))
+		metadataCtx, cancel := context.WithTimeout(ctx, metadataTimeout)
+		cloudConfig, err = d.CloudMetadata.get(metadataCtx, name, metadataTimeout)
+		cancel()
+	} else {
+		cloudConfig, err = getCloudConfig(name)
+	}
+	if err != nil {
+		return cloud.Configuration{}, err
+	}
+
+	if authorityHost := d.ActiveDirectoryAuthorityHost.ValueString(); authorityHost != "" {
+		tflog.Debug(ctx, fmt.Sprintf("Using active_directory_authority_host from the provider default, set from the %s", d.Sources["active_directory_authority_host"]))
+		cloudConfig.ActiveDirectoryAuthorityHost = authorityHost
+	}
+
+	if len(d.CloudServices) > 0 {
+		// The services of the predefined clouds are shared, never modify them.
+		services := maps.Clone(cloudConfig.Services)
+		if services == nil {
+			services = map[cloud.ServiceName]cloud.ServiceConfiguration{}
+		}
+		maps.Copy(services, d.CloudServices)
+		cloudConfig.Services = services
+	}
+
+	return cloudConfig, nil
+}
+
+func (d providerDefaults) tenantID(ctx context.Context, value types.String) types.String {
+	return mergeProviderDefault(ctx, "tenant_id", value, d.TenantID, d.Sources)
+}
+
+// configuredTenantID is tenantID without the AZURE_TENANT_ID fallback, for the
+// credentials using the tenant of a CLI login or reading AZURE_TENANT_ID
+// themselves, so the variable doesn't change their tenant.
+func (d providerDefaults) configuredTenantID(ctx context.Context, value types.String) types.String {
+	defaultValue := d.TenantID
+	if d.Sources["tenant_id"] != providerDefaultsSourceConfig {
+		defaultValue = types.StringNull()
+	}
+
+	return mergeProviderDefault(ctx, "tenant_id", value, defaultValue, d.Sources)
+}
+
+func (d providerDefaults) timeout(ctx context.Context, value types.String) types.String {
+	return mergeProviderDefault(ctx, "timeout", value, d.Timeout, d.Sources)
+}
+
+func (d providerDefaults) disableInstanceDiscovery(ctx context.Context, value types.Bool) types.Bool {
+	return mergeProviderDefault(ctx, "disable_instance_discovery", value, d.DisableInstanceDiscovery, d.Sources)
+}
+
+func (d providerDefaults) additionallyAllowedTenants(ctx context.Context, value types.Set) types.Set {
+	return mergeProviderDefault(ctx, "additionally_allowed_tenants", value, d.AdditionallyAllowedTenants, d.Sources)
+}
+
+// retryConfig returns the retry policy from the resource-level retry
+// attributes, each falling back to its provider default.
+func (d providerDefaults) retryConfig(ctx context.Context, maxRetries types.Int64, retryDelay types.String, maxRetryDelay types.String, statusCodes types.Set) (retryConfig, error) {
+	return newRetryConfig(
+		mergeProviderDefault(ctx, "max_retries", maxRetries, d.MaxRetries, d.Sources),
+		mergeProviderDefault(ctx, "retry_delay", retryDelay, d.RetryDelay, d.Sources),
+		mergeProviderDefault(ctx, "max_retry_delay", maxRetryDelay, d.MaxRetryDelay, d.Sources),
+		mergeProviderDefault(ctx, "retry_status_codes", statusCodes, d.RetryStatusCodes, d.Sources),
+	)
+}
+
+// mergeProviderDefault returns the resource-level value when set, otherwise
+// the provider default. The effective source is logged, as well as resource
+// values overriding a different provider default.
+func mergeProviderDefault[T attr.Value](ctx context.Context, name string, value T, defaultValue T, sources map[string]string) T {
+	resourceSet := !value.IsNull() && !value.IsUnknown()
+	defaultSet := !defaultValue.IsNull() && !defaultValue.IsUnknown()
+
+	switch {
+	case resourceSet && defaultSet && !value.Equal(defaultValue):
+		tflog.Info(ctx, fmt.Sprintf("Resource configuration of %s overrides the provider default from the %s", name, sources[name]))
+		return value
+	case resourceSet:
+		tflog.Debug(ctx, fmt.Sprintf("Using %s from the resource configuration", name))
+		return value
+	case defaultSet:
+		tflog.Debug(ctx, fmt.Sprintf("Using %s from the provider default, set from the %s", name, sources[name]))
+		return defaultValue
+	default:
+		return value
+	}
+}
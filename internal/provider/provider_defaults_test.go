@@ -0,0 +1,462 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestNewProviderDefaults(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+	t.Setenv("AZIDENTITY_CLOUD", "AzureChina")
+	t.Setenv("AZURE_TENANT_ID", "ze-env-tenant")
+	t.Setenv("AZIDENTITY_TIMEOUT", "5s")
+	t.Setenv("AZURE_DISABLE_INSTANCE_DISCOVERY", "true")
+	t.Setenv("AZURE_ADDITIONALLY_ALLOWED_TENANTS", "ze-env-tenant-1; ze-env-tenant-2")
+
+	defaults, diags := newProviderDefaults(context.Background(), AzidentityProviderModel{
+		TenantID: types.StringValue("ze-provider-tenant"),
+	})
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if defaults.Cloud.ValueString() != "AzureChina" {
+		t.Errorf("expected cloud to be AzureChina, got %q", defaults.Cloud.ValueString())
+	}
+
+	if defaults.TenantID.ValueString() != "ze-provider-tenant" {
+		t.Errorf("expected tenant_id to be ze-provider-tenant, got %q", defaults.TenantID.ValueString())
+	}
+
+	if defaults.Timeout.ValueString() != "5s" {
+		t.Errorf("expected timeout to be 5s, got %q", defaults.Timeout.ValueString())
+	}
+
+	if !defaults.DisableInstanceDiscovery.ValueBool() {
+		t.Errorf("expected disable_instance_discovery to be true")
+	}
+
+	tenants := typesSetToStringSlice(defaults.AdditionallyAllowedTenants)
+	slices.Sort(tenants)
+	if !slices.Equal(tenants, []string{"ze-env-tenant-1", "ze-env-tenant-2"}) {
+		t.Errorf("unexpected additionally_allowed_tenants %v", tenants)
+	}
+
+	expectedSources := map[string]string{
+		"cloud":                        "environment variable AZIDENTITY_CLOUD",
+		"tenant_id":                    "provider configuration",
+		"timeout":                      "environment variable AZIDENTITY_TIMEOUT",
+		"disable_instance_discovery":   "environment variable AZURE_DISABLE_INSTANCE_DISCOVERY",
+		"additionally_allowed_tenants": "environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS",
+	}
+	for name, expected := range expectedSources {
+		if defaults.Sources[name] != expected {
+			t.Errorf("expected source of %s to be %q, got %q", name, expected, defaults.Sources[name])
+		}
+	}
+}
+
+func TestNewProviderDefaultsInvalidEnvironment(t *testing.T) {
+	cases := []struct {
+		name          string
+		key           string
+		value         string
+		expectedError string
+	}{
+		{
+			name:          "cloud",
+			key:           "AZIDENTITY_CLOUD",
+			value:         "ze-cloud",
+			expectedError: "Invalid Cloud",
+		},
+		{
+			name:          "disable_instance_discovery",
+			key:           "AZURE_DISABLE_INSTANCE_DISCOVERY",
+			value:         "ze-bool",
+			expectedError: "Invalid Environment Variable",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testClearProviderDefaultsEnv(t)
+			t.Setenv(c.key, c.value)
+
+			_, diags := newProviderDefaults(context.Background(), AzidentityProviderModel{})
+			if !diags.HasError() {
+				t.Fatalf("expected an error")
+			}
+
+			if diags.Errors()[0].Summary() != c.expectedError {
+				t.Errorf("expected error %q, got %q", c.expectedError, diags.Errors()[0].Summary())
+			}
+		})
+	}
+}
+
+func TestMergeProviderDefault(t *testing.T) {
+	ctx := context.Background()
+	defaults := providerDefaults{
+		TenantID: types.StringValue("ze-provider-tenant"),
+		Sources:  map[string]string{"tenant_id": "provider configuration"},
+	}
+
+	if v := defaults.tenantID(ctx, types.StringNull()).ValueString(); v != "ze-provider-tenant" {
+		t.Errorf("expected the provider default, got %q", v)
+	}
+
+	if v := defaults.tenantID(ctx, types.StringValue("ze-tenant")).ValueString(); v != "ze-tenant" {
+		t.Errorf("expected the resource value, got %q", v)
+	}
+
+	if v := defaults.cloud(ctx, types.StringNull()); !v.IsNull() {
+		t.Errorf("expected null without a provider default, got %q", v.ValueString())
+	}
+
+	if v := (providerDefaults{}).disableInstanceDiscovery(ctx, types.BoolValue(true)); !v.ValueBool() {
+		t.Errorf("expected the resource value without provider defaults")
+	}
+}
+
+func TestProviderDefaultsConfiguredTenantID(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+	t.Setenv("AZURE_TENANT_ID", "ze-env-tenant")
+	ctx := context.Background()
+
+	defaults, diags := newProviderDefaults(ctx, AzidentityProviderModel{})
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if v := defaults.tenantID(ctx, types.StringNull()).ValueString(); v != "ze-env-tenant" {
+		t.Errorf("expected the environment variable, got %q", v)
+	}
+
+	if v := defaults.configuredTenantID(ctx, types.StringNull()); !v.IsNull() {
+		t.Errorf("expected the environment variable to be ignored, got %q", v.ValueString())
+	}
+
+	if v := defaults.configuredTenantID(ctx, types.StringValue("ze-tenant")).ValueString(); v != "ze-tenant" {
+		t.Errorf("expected the resource value, got %q", v)
+	}
+
+	defaults, diags = newProviderDefaults(ctx, AzidentityProviderModel{
+		TenantID: types.StringValue("ze-provider-tenant"),
+	})
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if v := defaults.configuredTenantID(ctx, types.StringNull()).ValueString(); v != "ze-provider-tenant" {
+		t.Errorf("expected the provider configuration, got %q", v)
+	}
+}
+
+func TestEphemeralCredentialProviderDefaults(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	cases := []struct {
+		name     string
+		resource string
+		expected credentialConfig
+	}{
+		{
+			name: "provider",
+			resource: `
+ephemeral "azidentity_client_secret_credential" "this" {
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope-1"]
+}
+`,
+			expected: credentialConfig{
+				CloudConfig:                cloud.AzureGovernment,
+				TenantID:                   "ze-provider-tenant",
+				AdditionallyAllowedTenants: []string{"ze-provider-additional-tenant"},
+				DisableInstanceDiscovery:   true,
+				Timeout:                    5 * time.Second,
+			},
+		},
+		{
+			name: "resource",
+			resource: `
+ephemeral "azidentity_client_secret_credential" "this" {
+	cloud                        = "AzureChina"
+	tenant_id                    = "ze-tenant"
+	client_id                    = "ze-client"
+	client_secret                = "ze-secret"
+	additionally_allowed_tenants = ["ze-additional-tenant"]
+	disable_instance_discovery   = false
+	scopes                       = ["ze-scope-1"]
+	timeout                      = "10s"
+}
+`,
+			expected: credentialConfig{
+				CloudConfig:                cloud.AzureChina,
+				TenantID:                   "ze-tenant",
+				AdditionallyAllowedTenants: []string{"ze-additional-tenant"},
+				DisableInstanceDiscovery:   false,
+				Timeout:                    10 * time.Second,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got credentialConfig
+			getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = cfg
+
+				return &testCredential{t: t}, nil
+			}
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, getCredFn),
+				Steps: []resource.TestStep{
+					{
+						Config: `
+provider "azidentity" {
+	cloud                        = "AzureGovernment"
+	tenant_id                    = "ze-provider-tenant"
+	additionally_allowed_tenants = ["ze-provider-additional-tenant"]
+	disable_instance_discovery   = true
+	timeout                      = "5s"
+}
+` + c.resource + `
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this
+}
+
+resource "echo" "this" {}
+`,
+					},
+				},
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if got.CloudConfig.ActiveDirectoryAuthorityHost != c.expected.CloudConfig.ActiveDirectoryAuthorityHost {
+				t.Errorf("expected authority host %q, got %q", c.expected.CloudConfig.ActiveDirectoryAuthorityHost, got.CloudConfig.ActiveDirectoryAuthorityHost)
+			}
+
+			if got.TenantID != c.expected.TenantID {
+				t.Errorf("expected tenant_id %q, got %q", c.expected.TenantID, got.TenantID)
+			}
+
+			if !slices.Equal(got.AdditionallyAllowedTenants, c.expected.AdditionallyAllowedTenants) {
+				t.Errorf("expected additionally_allowed_tenants %v, got %v", c.expected.AdditionallyAllowedTenants, got.AdditionallyAllowedTenants)
+			}
+
+			if got.DisableInstanceDiscovery != c.expected.DisableInstanceDiscovery {
+				t.Errorf("expected disable_instance_discovery %t, got %t", c.expected.DisableInstanceDiscovery, got.DisableInstanceDiscovery)
+			}
+
+			if got.Timeout != c.expected.Timeout {
+				t.Errorf("expected timeout %s, got %s", c.expected.Timeout, got.Timeout)
+			}
+		})
+	}
+}
+
+func TestProviderDefaultsCloudConfig(t *testing.T) {
+	ctx := context.Background()
+	defaults := providerDefaults{
+		Cloud:                        types.StringValue("AzureChina"),
+		ActiveDirectoryAuthorityHost: types.StringValue("https://adfs.example.com/adfs/"),
+		CloudServices: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {
+				Audience: "https://management.ze-stack.example.com",
+				Endpoint: "https://management.ze-stack.example.com",
+			},
+		},
+		Sources: map[string]string{},
+	}
+
+	cloudConfig, err := defaults.cloudConfig(ctx, types.StringNull(), types.StringNull())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if cloudConfig.ActiveDirectoryAuthorityHost != "https://adfs.example.com/adfs/" {
+		t.Errorf("unexpected authority host %q", cloudConfig.ActiveDirectoryAuthorityHost)
+	}
+
+	if cloudConfig.Services[cloud.ResourceManager].Endpoint != "https://management.ze-stack.example.com" {
+		t.Errorf("unexpected resource manager endpoint %q", cloudConfig.Services[cloud.ResourceManager].Endpoint)
+	}
+
+	if cloud.AzureChina.Services[cloud.ResourceManager].Endpoint != "https://management.chinacloudapi.cn" {
+		t.Errorf("expected the predefined cloud to be unmodified, got %q", cloud.AzureChina.Services[cloud.ResourceManager].Endpoint)
+	}
+
+	_, err = defaults.cloudConfig(ctx, types.StringValue("ze-cloud"), types.StringNull())
+	if err == nil || !strings.Contains(err.Error(), `unknown cloud "ze-cloud"`) {
+		t.Errorf("expected an unknown cloud error, got %v", err)
+	}
+}
+
+func TestEphemeralCredentialProviderAuthorityHost(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	err := os.WriteFile(tokenFilePath, []byte("ze-federated-token"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+
+	server := testNewEntraAuthorityServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ze-tenant/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path %q", r.URL.Path)
+		}
+
+		testEntraTokenResponse(w, "ze-custom-cloud-token")
+	})
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEchoHttpClient(t, newGetCredentialFn(), server.Client()),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "azidentity" {
+	active_directory_authority_host = %q
+	disable_instance_discovery      = true
+
+	cloud_services = {
+		resourceManager = {
+			audience = "https://management.ze-stack.example.com"
+			endpoint = "https://management.ze-stack.example.com"
+		}
+	}
+}
+
+ephemeral "azidentity_workload_identity_credential" "this" {
+	tenant_id       = "ze-tenant"
+	client_id       = "ze-client"
+	token_file_path = %q
+	scopes          = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_workload_identity_credential.this
+}
+
+resource "echo" "this" {}
+`, server.URL, tokenFilePath),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("access_token"),
+						knownvalue.StringExact("ze-custom-cloud-token"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralCredentialProviderInvalidAuthorityHost(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+provider "azidentity" {
+	active_directory_authority_host = "http://ze-authority.example.com"
+}
+
+ephemeral "azidentity_default_credential" "this" {
+	scopes = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_default_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Authority Host`),
+			},
+		},
+	})
+}
+
+func TestEphemeralCredentialMissingTenantID(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, newGetCredentialFn()),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_client_secret_credential" "this" {
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope-1"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`no tenant ID specified`),
+			},
+		},
+	})
+}
+
+func testClearProviderDefaultsEnv(t *testing.T) {
+	t.Helper()
+
+	for _, k := range []string{
+		"AZIDENTITY_CLOUD",
+		"AZURE_TENANT_ID",
+		"AZIDENTITY_TIMEOUT",
+		"AZURE_DISABLE_INSTANCE_DISCOVERY",
+		"AZURE_ADDITIONALLY_ALLOWED_TENANTS",
+		"AZURE_AUTHORITY_HOST",
+	} {
+		t.Setenv(k, "")
+	}
+}
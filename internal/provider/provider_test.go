@@ -2,6 +2,7 @@ package provider
 
 import (
 	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-framework/provider"
@@ -22,13 +23,26 @@ func testNew(t *testing.T, getCredFn getCredentialFn) func() provider.Provider {
 	}
 }
 
+func testNewHttpClient(t *testing.T, getCredFn getCredentialFn, httpClient *http.Client) func() provider.Provider {
+	t.Helper()
+
+	return func() provider.Provider {
+		return &azidentityProvider{
+			version:    "test",
+			getCredFn:  getCredFn,
+			httpClient: httpClient,
+		}
+	}
+}
+
 func testNewRunCommand(t *testing.T, runCmdFn runCommandFn) func() provider.Provider {
 	t.Helper()
 
 	return func() provider.Provider {
 		return &azidentityProvider{
-			version:  "test",
-			runCmdFn: runCmdFn,
+			version:   "test",
+			getCredFn: newGetCredentialFn(),
+			runCmdFn:  runCmdFn,
 		}
 	}
 }
@@ -46,3 +60,46 @@ func testProtoV6ProviderFactoriesWithEchoRunCommand(t *testing.T, runCmdFn runCo
 		"echo":       echoprovider.NewProviderServer(),
 	}
 }
+
+func testProtoV6ProviderFactoriesWithEchoHttpClient(t *testing.T, getCredFn getCredentialFn, httpClient *http.Client) map[string]func() (tfprotov6.ProviderServer, error) {
+	return map[string]func() (tfprotov6.ProviderServer, error){
+		"azidentity": providerserver.NewProtocol6WithError(testNewHttpClient(t, getCredFn, httpClient)()),
+		"echo":       echoprovider.NewProviderServer(),
+	}
+}
+
+// testRedirectTransport sends every request to target, regardless of the
+// scheme and host of the original request. It is used to point credentials
+// with hard-coded endpoints (such as IMDS) at a local test server. Requests
+// already addressed to a local test server are sent unchanged.
+type testRedirectTransport struct {
+	target *url.URL
+}
+
+func (rt *testRedirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Hostname() == "127.0.0.1" {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	req.Host = rt.target.Host
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func testNewRedirectHttpClient(t *testing.T, serverURL string) *http.Client {
+	t.Helper()
+
+	target, err := url.Parse(serverURL)
+	if err != nil {
+		t.Fatalf("failed to parse server URL %q: %s", serverURL, err)
+	}
+
+	return &http.Client{
+		Transport: &testRedirectTransport{
+			target: target,
+		},
+	}
+}
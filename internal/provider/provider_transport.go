@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// providerTransportConfig are the provider attributes customising the HTTP
+// transport shared by azidentity_http_request and the credentials.
+type providerTransportConfig struct {
+	ProxyURL                  string
+	NoProxy                   string
+	CACertificatesPEM         string
+	ClientCertificate         string
+	ClientCertificatePassword string
+}
+
+func (c providerTransportConfig) isSet() bool {
+	return c != providerTransportConfig{}
+}
+
+// newProviderHTTPClient returns an HTTP client with a transport built from the
+// provider attributes, starting from the defaults of http.DefaultTransport.
+// Proxies fall back to the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment
+// variables when proxy_url or no_proxy aren't set.
+func newProviderHTTPClient(cfg providerTransportConfig) (*http.Client, error) {
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("expected http.DefaultTransport to be *http.Transport, got: %T", http.DefaultTransport)
+	}
+
+	transport := defaultTransport.Clone()
+
+	proxyConfig := httpproxy.FromEnvironment()
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("proxy_url %q is not a valid URL", cfg.ProxyURL)
+		}
+
+		proxyConfig.HTTPProxy = cfg.ProxyURL
+		proxyConfig.HTTPSProxy = cfg.ProxyURL
+	}
+
+	if cfg.NoProxy != "" {
+		proxyConfig.NoProxy = cfg.NoProxy
+	}
+
+	proxyFn := proxyConfig.ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFn(req.URL)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.CACertificatesPEM != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		if !rootCAs.AppendCertsFromPEM([]byte(cfg.CACertificatesPEM)) {
+			return nil, errors.New("ca_certificates_pem doesn't contain any PEM encoded certificate")
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if cfg.ClientCertificate != "" {
+		certs, key, err := parseCertificate(cfg.ClientCertificate, cfg.ClientCertificatePassword)
+		if err != nil {
+			return nil, fmt.Errorf("client_certificate: %w", err)
+		}
+
+		clientCert := tls.Certificate{
+			PrivateKey: key,
+			Leaf:       certs[0],
+		}
+		for _, cert := range certs {
+			clientCert.Certificate = append(clientCert.Certificate, cert.Raw)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
@@ -0,0 +1,264 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestNewProviderHTTPClientCACertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ze-response")
+	}))
+	defer server.Close()
+
+	httpClient, err := newProviderHTTPClient(providerTransportConfig{
+		NoProxy: "*",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = httpClient.Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected the server certificate to be rejected, got %v", err)
+	}
+
+	httpClient, err = newProviderHTTPClient(providerTransportConfig{
+		NoProxy:           "*",
+		CACertificatesPEM: testCertificatePEM(server.Certificate()),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	res, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, res.StatusCode)
+	}
+}
+
+func TestNewProviderHTTPClientProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("NO_PROXY", "")
+
+	var proxiedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		fmt.Fprint(w, "ze-proxied")
+	}))
+	defer proxy.Close()
+
+	httpClient, err := newProviderHTTPClient(providerTransportConfig{
+		ProxyURL: proxy.URL,
+		NoProxy:  ".ze-internal.example.com,10.0.0.0/8",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	res, err := httpClient.Get("http://ze-upstream.example.com/ze-path")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if proxiedURL != "http://ze-upstream.example.com/ze-path" {
+		t.Errorf("expected the request to be sent through the proxy, got %q", proxiedURL)
+	}
+
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got %T", httpClient.Transport)
+	}
+
+	cases := []struct {
+		requestURL    string
+		expectedProxy string
+	}{
+		{
+			requestURL:    "https://login.microsoftonline.com/",
+			expectedProxy: proxy.URL,
+		},
+		{
+			requestURL:    "https://ze-service.ze-internal.example.com/",
+			expectedProxy: "",
+		},
+		{
+			requestURL:    "https://10.1.2.3/",
+			expectedProxy: "",
+		},
+	}
+
+	for _, c := range cases {
+		u, err := url.Parse(c.requestURL)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		proxyURL, err := transport.Proxy(&http.Request{URL: u})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		actualProxy := ""
+		if proxyURL != nil {
+			actualProxy = proxyURL.String()
+		}
+
+		if actualProxy != c.expectedProxy {
+			t.Errorf("expected proxy %q for %s, got %q", c.expectedProxy, c.requestURL, actualProxy)
+		}
+	}
+}
+
+func TestNewProviderHTTPClientClientCertificate(t *testing.T) {
+	certificatePEM := testNewCertificatePEM(t, true)
+	certs, _, err := parseCertificate(certificatePEM, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(certs[0])
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	httpClient, err := newProviderHTTPClient(providerTransportConfig{
+		NoProxy:           "*",
+		CACertificatesPEM: testCertificatePEM(server.Certificate()),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = httpClient.Get(server.URL)
+	if err == nil {
+		t.Errorf("expected the request without a client certificate to fail")
+	}
+
+	httpClient, err = newProviderHTTPClient(providerTransportConfig{
+		NoProxy:           "*",
+		CACertificatesPEM: testCertificatePEM(server.Certificate()),
+		ClientCertificate: certificatePEM,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	res, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, res.StatusCode)
+	}
+}
+
+func TestNewProviderHTTPClientInvalid(t *testing.T) {
+	cases := []struct {
+		name          string
+		cfg           providerTransportConfig
+		expectedError string
+	}{
+		{
+			name:          "proxy_url",
+			cfg:           providerTransportConfig{ProxyURL: "ze-proxy"},
+			expectedError: "proxy_url \"ze-proxy\" is not a valid URL",
+		},
+		{
+			name:          "ca_certificates_pem",
+			cfg:           providerTransportConfig{CACertificatesPEM: "ze-not-pem"},
+			expectedError: "ca_certificates_pem doesn't contain any PEM encoded certificate",
+		},
+		{
+			name:          "client_certificate",
+			cfg:           providerTransportConfig{ClientCertificate: "ze-not-a-certificate"},
+			expectedError: "client_certificate:",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := newProviderHTTPClient(c.cfg)
+			if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+				t.Errorf("expected error containing %q, got %v", c.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestEphemeralHttpRequestProviderCACertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ze-trusted")
+	}))
+	defer server.Close()
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewTestCredentialFn(t)),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "azidentity" {
+	no_proxy            = "*"
+	ca_certificates_pem = %q
+}
+
+ephemeral "azidentity_http_request" "this" {
+	request_url    = "%s"
+	request_method = "GET"
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_http_request.this.response_body
+}
+
+resource "echo" "this" {}
+`, testCertificatePEM(server.Certificate()), server.URL),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data"),
+						knownvalue.StringExact("ze-trusted"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func testCertificatePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
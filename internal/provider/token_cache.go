@@ -0,0 +1,497 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultTokenCacheRefreshMargin is how long before their expiry cached tokens
+// are requested again, unless token_cache_refresh_margin is set.
+const defaultTokenCacheRefreshMargin = 5 * time.Minute
+
+// tokenCache reuses the tokens acquired by the credential resources of a
+// provider instance, as Terraform opens every ephemeral resource several times
+// during a plan or apply. Tokens are requested again when they expire within
+// the refresh margin. Failures aren't cached. Tokens of some credentials are
+// also stored in the persistent cache, when it's enabled, to reuse them across
+// Terraform runs. Concurrent requests of a key share a single token request,
+// and the mutex only guards the entries, never a request.
+type tokenCache struct {
+	mu            sync.Mutex
+	refreshMargin time.Duration
+	persistent    *persistentTokenCache
+	entries       map[string]*tokenCacheEntry
+	requests      singleflight.Group
+}
+
+// tokenCacheEntry is a token acquired for a key, guarded by the mutex of the
+// cache. Entries are only created by successful token requests, and are
+// evicted once their token has expired and no open resource holds them.
+// Source is the index of the source that acquired the token of a credential
+// chain.
+type tokenCacheEntry struct {
+	token  azcore.AccessToken
+	source int
+	// acquire requests a new token with the configuration of the last Open of
+	// the key. It's only kept in memory, so renewing a resource doesn't need
+	// its secrets in the private data sent to Terraform.
+	acquire tokenAcquireFn
+	// refs counts the open resources holding the entry, which keep it past the
+	// expiry of its token as long as they may renew it.
+	refs int
+}
+
+// tokenAcquireFn requests a new token, returning the index of the source that
+// acquired it for credential chains.
+type tokenAcquireFn func(ctx context.Context) (azcore.AccessToken, int, string, error)
+
+// tokenLoadFn loads a token acquired by an earlier Terraform run, reporting
+// false when there's none valid for long enough.
+type tokenLoadFn func(ctx context.Context) (azcore.AccessToken, bool)
+
+// tokenRequestResult is the outcome of a token request, shared by the
+// concurrent requests of a key.
+type tokenRequestResult struct {
+	token      azcore.AccessToken
+	source     int
+	cacheHit   bool
+	errSummary string
+}
+
+// tokenCacheKey identifies the identity and request a token was acquired for.
+// UserAssertion is hashed with the rest of the key, the key itself never
+// contains it. CredentialHash covers the material authenticating the
+// identity, so a token is never reused once a secret, certificate or
+// assertion changes, even though the identity stays the same.
+type tokenCacheKey struct {
+	CredType       credentialType `json:"cred_type"`
+	AuthorityHost  string         `json:"authority_host"`
+	TenantID       string         `json:"tenant_id"`
+	ClientID       string         `json:"client_id"`
+	ObjectID       string         `json:"object_id"`
+	ResourceID     string         `json:"resource_id"`
+	Username       string         `json:"username"`
+	UserAssertion  string         `json:"user_assertion"`
+	Scopes         []string       `json:"scopes"`
+	Claims         string         `json:"claims"`
+	EnableCAE      bool           `json:"enable_cae"`
+	CredentialHash string         `json:"credential_hash"`
+}
+
+// tokenCacheCredentialMaterial is the part of a credential configuration that
+// authenticates the identity or selects how it's authenticated, hashed into
+// tokenCacheKey.CredentialHash.
+type tokenCacheCredentialMaterial struct {
+	ClientSecret               string   `json:"client_secret"`
+	Password                   string   `json:"password"`
+	Certificate                string   `json:"certificate"`
+	CertificatePassword        string   `json:"certificate_password"`
+	SendCertificateChain       bool     `json:"send_certificate_chain"`
+	Assertion                  string   `json:"client_assertion"`
+	SystemAccessToken          string   `json:"system_access_token"`
+	ServiceConnectionID        string   `json:"service_connection_id"`
+	TokenFilePath              string   `json:"token_file_path"`
+	Audience                   string   `json:"audience"`
+	SubscriptionID             string   `json:"subscription_id"`
+	AdditionallyAllowedTenants []string `json:"additionally_allowed_tenants"`
+}
+
+func newTokenCache(refreshMargin time.Duration) *tokenCache {
+	return &tokenCache{
+		refreshMargin: refreshMargin,
+		entries:       map[string]*tokenCacheEntry{},
+	}
+}
+
+func (c *tokenCache) setRefreshMargin(refreshMargin time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refreshMargin = refreshMargin
+}
+
+func newTokenCacheKey(credType credentialType, cfg credentialConfig) (string, error) {
+	scopes := slices.Clone(cfg.Scopes)
+	slices.Sort(scopes)
+
+	credentialHash, err := newCredentialMaterialHash(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(tokenCacheKey{
+		CredType:       credType,
+		AuthorityHost:  cfg.CloudConfig.ActiveDirectoryAuthorityHost,
+		TenantID:       cfg.TenantID,
+		ClientID:       cfg.ClientID,
+		ObjectID:       cfg.ObjectID,
+		ResourceID:     cfg.ResourceID,
+		Username:       cfg.Username,
+		UserAssertion:  cfg.UserAssertion,
+		Scopes:         scopes,
+		Claims:         cfg.Claims,
+		EnableCAE:      cfg.EnableCAE,
+		CredentialHash: credentialHash,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newCredentialMaterialHash returns the hex encoded SHA-256 of the credential
+// material of cfg.
+func newCredentialMaterialHash(cfg credentialConfig) (string, error) {
+	tenants := slices.Clone(cfg.AdditionallyAllowedTenants)
+	slices.Sort(tenants)
+
+	b, err := json.Marshal(tokenCacheCredentialMaterial{
+		ClientSecret:               cfg.ClientSecret,
+		Password:                   cfg.Password,
+		Certificate:                cfg.Certificate,
+		CertificatePassword:        cfg.CertificatePassword,
+		SendCertificateChain:       cfg.SendCertificateChain,
+		Assertion:                  cfg.Assertion,
+		SystemAccessToken:          cfg.SystemAccessToken,
+		ServiceConnectionID:        cfg.ServiceConnectionID,
+		TokenFilePath:              cfg.TokenFilePath,
+		Audience:                   cfg.Audience,
+		SubscriptionID:             cfg.SubscriptionID,
+		AdditionallyAllowedTenants: tenants,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// setPersistent enables the persistent cache, or disables it when nil.
+func (c *tokenCache) setPersistent(persistent *persistentTokenCache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.persistent = persistent
+}
+
+// settings returns the current refresh margin and persistent cache.
+func (c *tokenCache) settings() (time.Duration, *persistentTokenCache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.refreshMargin, c.persistent
+}
+
+// tokenValidFor reports whether token is valid for longer than margin.
+func tokenValidFor(token azcore.AccessToken, margin time.Duration) bool {
+	return token.Token != "" && time.Now().Add(margin).Before(token.ExpiresOn)
+}
+
+// hold returns the entry of key when its token is valid for longer than the
+// refresh margin, holding it for an open resource and keeping acquire to
+// renew it. The entry is looked up and held in a single critical section, so
+// it can't be evicted in between.
+func (c *tokenCache) hold(key string, acquire tokenAcquireFn) (tokenCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || !tokenValidFor(e.token, c.refreshMargin) {
+		return tokenCacheEntry{}, false
+	}
+
+	e.acquire = acquire
+	e.refs++
+
+	return *e, true
+}
+
+// store caches a token acquired for key and holds its entry for an open
+// resource, keeping acquire to renew it.
+func (c *tokenCache) store(key string, token azcore.AccessToken, source int, acquire tokenAcquireFn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &tokenCacheEntry{}
+		c.entries[key] = e
+	}
+
+	e.token = token
+	e.source = source
+	e.acquire = acquire
+	e.refs++
+
+	c.evict()
+}
+
+// release drops the hold of a closed resource on the entry of key. The entry
+// stays cached for the resources opened later with the same key, until its
+// token expires. The persistent cache is left as is.
+func (c *tokenCache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok && e.refs > 0 {
+		e.refs--
+	}
+
+	c.evict()
+}
+
+// evict removes the entries whose token has expired and that no open resource
+// holds, along with the configuration kept to renew them. The caller holds
+// the mutex.
+func (c *tokenCache) evict() {
+	now := time.Now()
+	for key, e := range c.entries {
+		if e.refs <= 0 && !now.Before(e.token.ExpiresOn) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// request runs fn for key, sharing it with the concurrent requests of the
+// same key. The shared request isn't cancelled with the ctx of the request
+// starting it, each request stops waiting when its own ctx is done, while the
+// token requests are bounded by the timeout of their credential.
+func (c *tokenCache) request(ctx context.Context, key string, fn func(ctx context.Context) (tokenRequestResult, error)) (tokenRequestResult, error) {
+	ch := c.requests.DoChan(key, func() (any, error) {
+		return fn(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case res := <-ch:
+		result, ok := res.Val.(tokenRequestResult)
+		if !ok {
+			return tokenRequestResult{errSummary: "Error getting token"}, fmt.Errorf("unexpected token request result %T", res.Val)
+		}
+
+		return result, res.Err
+	case <-ctx.Done():
+		return tokenRequestResult{errSummary: "Error getting token"}, ctx.Err()
+	}
+}
+
+// get returns the token of key and holds its entry for an open resource. A
+// cached token valid for longer than the refresh margin is reused, otherwise
+// a token loaded by load, when not nil, or requested by acquire is cached.
+// Nothing is cached when the request fails. It reports whether the token was
+// reused.
+func (c *tokenCache) get(ctx context.Context, key string, acquire tokenAcquireFn, load tokenLoadFn) (azcore.AccessToken, int, bool, string, error) {
+	if e, ok := c.hold(key, acquire); ok {
+		return e.token, e.source, true, "", nil
+	}
+
+	// Failures aren't shared, as the concurrent requests may differ in
+	// configuration not covered by the key, such as their retries. A request
+	// that waited for a failing request of another one tries again with its
+	// own.
+	for {
+		var requested atomic.Bool
+		result, err := c.request(ctx, key, func(ctx context.Context) (tokenRequestResult, error) {
+			requested.Store(true)
+			if load != nil {
+				if token, ok := load(ctx); ok {
+					return tokenRequestResult{token: token, cacheHit: true}, nil
+				}
+			}
+
+			token, source, errSummary, err := acquire(ctx)
+			return tokenRequestResult{token: token, source: source, errSummary: errSummary}, err
+		})
+		if err != nil && !requested.Load() && ctx.Err() == nil {
+			continue
+		}
+
+		if err != nil {
+			return azcore.AccessToken{}, -1, false, result.errSummary, err
+		}
+
+		c.store(key, result.token, result.source, acquire)
+
+		return result.token, result.source, result.cacheHit, "", nil
+	}
+}
+
+// renew requests a new token for the entry of key, unless its token is valid
+// for longer than both the refresh margin and minValidity, using the
+// configuration of the last Open of the key. It reports false when there's
+// nothing to renew, because the entry isn't cached.
+func (c *tokenCache) renew(ctx context.Context, key string, minValidity time.Duration) (azcore.AccessToken, bool, string, error) {
+	c.mu.Lock()
+	var e tokenCacheEntry
+	cached, ok := c.entries[key]
+	if ok {
+		e = *cached
+	}
+	refreshMargin := max(c.refreshMargin, minValidity)
+	c.mu.Unlock()
+
+	if !ok || e.acquire == nil {
+		return azcore.AccessToken{}, false, "", nil
+	}
+
+	if tokenValidFor(e.token, refreshMargin) {
+		tflog.Debug(ctx, fmt.Sprintf("Cached token expiring on %s is still valid", e.token.ExpiresOn.Format(time.RFC3339)))
+		return e.token, true, "", nil
+	}
+
+	result, err := c.request(ctx, key, func(ctx context.Context) (tokenRequestResult, error) {
+		token, source, errSummary, err := e.acquire(ctx)
+		return tokenRequestResult{token: token, source: source, errSummary: errSummary}, err
+	})
+	if err != nil {
+		return azcore.AccessToken{}, true, result.errSummary, err
+	}
+
+	c.mu.Lock()
+	if cached, ok := c.entries[key]; ok {
+		cached.token = result.token
+		cached.source = result.source
+	}
+	c.mu.Unlock()
+
+	return result.token, true, "", nil
+}
+
+// getCachedToken returns a cached token valid for longer than the refresh
+// margin, otherwise it acquires and caches a new one with getToken. The entry
+// of the token is held for the open resource, which releases it when closed.
+// It reports whether the token came from the cache. A nil cache always
+// acquires a new token.
+func getCachedToken(ctx context.Context, cache *tokenCache, credType credentialType, getCredFn getCredentialFn, cfg credentialConfig) (azcore.AccessToken, bool, string, error) {
+	if cache == nil {
+		token, errSummary, err := getToken(ctx, credType, getCredFn, cfg)
+		return token, false, errSummary, err
+	}
+
+	key, err := newTokenCacheKey(credType, cfg)
+	if err != nil {
+		return azcore.AccessToken{}, false, "Error creating token cache key", err
+	}
+
+	refreshMargin, persistent := cache.settings()
+	if !persistentTokenCacheCredentialTypes[credType] {
+		persistent = nil
+	}
+
+	acquire := func(ctx context.Context) (azcore.AccessToken, int, string, error) {
+		token, errSummary, err := getToken(ctx, credType, getCredFn, cfg)
+		if err != nil {
+			return azcore.AccessToken{}, 0, errSummary, err
+		}
+
+		if persistent != nil {
+			storeCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+			err := persistent.store(storeCtx, key, token)
+			cancel()
+			if err != nil {
+				tflog.Warn(ctx, fmt.Sprintf("Failed to store token in the persistent token cache, it's only cached in memory: %s", err))
+			}
+		}
+
+		return token, 0, "", nil
+	}
+
+	var load tokenLoadFn
+	if persistent != nil {
+		load = func(ctx context.Context) (azcore.AccessToken, bool) {
+			loadCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+			token, ok, err := persistent.load(loadCtx, key)
+			cancel()
+			if err != nil {
+				tflog.Warn(ctx, fmt.Sprintf("Failed to load token from the persistent token cache, requesting a new one: %s", err))
+			}
+
+			if !ok || !tokenValidFor(token, refreshMargin) {
+				return azcore.AccessToken{}, false
+			}
+
+			tflog.Debug(ctx, fmt.Sprintf("Using %s token from the persistent token cache expiring on %s", credType, token.ExpiresOn.Format(time.RFC3339)))
+			return token, true
+		}
+	}
+
+	token, _, cacheHit, errSummary, err := cache.get(ctx, key, acquire, load)
+	if err != nil {
+		return azcore.AccessToken{}, false, errSummary, err
+	}
+
+	if cacheHit {
+		tflog.Debug(ctx, fmt.Sprintf("Using cached %s token expiring on %s", credType, token.ExpiresOn.Format(time.RFC3339)))
+	}
+
+	return token, cacheHit, "", nil
+}
+
+// newChainedTokenCacheKey returns the key of the token of a credential chain,
+// made of the keys of all its links in order, as an earlier link may acquire
+// the token the next time the chain is tried.
+func newChainedTokenCacheKey(links []*chainedCredentialLink, cfg credentialConfig) (string, error) {
+	keys := []string{}
+	for _, link := range links {
+		key, err := newTokenCacheKey(link.credType, link.requestConfig(cfg))
+		if err != nil {
+			return "", err
+		}
+
+		keys = append(keys, key)
+	}
+
+	b, err := json.Marshal(keys)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// getCachedChainedToken is getCachedToken for a credential chain,
+// additionally returning the index of the link that acquired the token.
+// Tokens of chains are never stored in the persistent cache.
+func getCachedChainedToken(ctx context.Context, cache *tokenCache, getCredFn getCredentialFn, links []*chainedCredentialLink, cfg credentialConfig) (azcore.AccessToken, int, bool, string, error) {
+	if cache == nil {
+		token, index, errSummary, err := getChainedToken(ctx, getCredFn, links, cfg)
+		return token, index, false, errSummary, err
+	}
+
+	key, err := newChainedTokenCacheKey(links, cfg)
+	if err != nil {
+		return azcore.AccessToken{}, -1, false, "Error creating token cache key", err
+	}
+
+	acquire := func(ctx context.Context) (azcore.AccessToken, int, string, error) {
+		return getChainedToken(ctx, getCredFn, links, cfg)
+	}
+
+	token, index, cacheHit, errSummary, err := cache.get(ctx, key, acquire, nil)
+	if err != nil {
+		return azcore.AccessToken{}, -1, false, errSummary, err
+	}
+
+	if cacheHit {
+		tflog.Debug(ctx, fmt.Sprintf("Using cached token of %s expiring on %s", links[index].name, token.ExpiresOn.Format(time.RFC3339)))
+	}
+
+	return token, index, cacheHit, "", nil
+}
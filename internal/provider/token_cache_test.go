@@ -0,0 +1,643 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+// testCountingCredential returns tokens expiring after expiresIn, numbered by
+// the count of token requests.
+type testCountingCredential struct {
+	requests  *atomic.Int32
+	expiresIn time.Duration
+	err       error
+}
+
+func (c *testCountingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	n := c.requests.Add(1)
+	if c.err != nil {
+		return azcore.AccessToken{}, c.err
+	}
+
+	return azcore.AccessToken{
+		Token:     fmt.Sprintf("ze-token-%d", n),
+		ExpiresOn: time.Now().Add(c.expiresIn),
+	}, nil
+}
+
+var _ azcore.TokenCredential = (*testCountingCredential)(nil)
+
+func testNewCountingCredentialFn(requests *atomic.Int32, expiresIn time.Duration) getCredentialFn {
+	return func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		return &testCountingCredential{
+			requests:  requests,
+			expiresIn: expiresIn,
+		}, nil
+	}
+}
+
+func TestGetCachedToken(t *testing.T) {
+	cfg := credentialConfig{
+		TenantID: "ze-tenant",
+		ClientID: "ze-client",
+		Scopes:   []string{"ze-scope-1", "ze-scope-2"},
+		Timeout:  defaultTimeout,
+	}
+
+	cases := []struct {
+		name             string
+		expiresIn        time.Duration
+		credType         credentialType
+		cfg              func(cfg credentialConfig) credentialConfig
+		expectedCacheHit bool
+	}{
+		{
+			name:      "same_key",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.Scopes = []string{"ze-scope-2", "ze-scope-1"}
+				cfg.Timeout = time.Minute
+				return cfg
+			},
+			expectedCacheHit: true,
+		},
+		{
+			name:      "expiring_within_margin",
+			expiresIn: time.Minute,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "credential_type",
+			expiresIn: time.Hour,
+			credType:  clientAssertionCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "tenant",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.TenantID = "ze-other-tenant"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "client",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.ClientID = "ze-other-client"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "scopes",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.Scopes = []string{"ze-scope-1"}
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "claims",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.Claims = `{"access_token":{"nbf":{"essential":true}}}`
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "enable_cae",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.EnableCAE = true
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "user_assertion",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.UserAssertion = "ze-other-user-assertion"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "client_secret",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.ClientSecret = "ze-other-secret"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "password",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.Password = "ze-other-password"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "certificate",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.Certificate = "ze-other-certificate"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "assertion",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.Assertion = "ze-other-assertion"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "system_access_token",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.SystemAccessToken = "ze-other-system-access-token"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "service_connection",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.ServiceConnectionID = "ze-other-service-connection"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "token_file_path",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.TokenFilePath = "ze-other-token-file"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "audience",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.Audience = "ze-other-audience"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "subscription",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.SubscriptionID = "ze-other-subscription"
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+		{
+			name:      "additionally_allowed_tenants",
+			expiresIn: time.Hour,
+			credType:  clientSecretCredential,
+			cfg: func(cfg credentialConfig) credentialConfig {
+				cfg.AdditionallyAllowedTenants = []string{"ze-other-tenant"}
+				return cfg
+			},
+			expectedCacheHit: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var requests atomic.Int32
+			cache := newTokenCache(defaultTokenCacheRefreshMargin)
+			getCredFn := testNewCountingCredentialFn(&requests, c.expiresIn)
+
+			token, cacheHit, _, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if cacheHit {
+				t.Errorf("expected the first token not to be a cache hit")
+			}
+
+			token2, cacheHit, _, err := getCachedToken(t.Context(), cache, c.credType, getCredFn, c.cfg(cfg))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if cacheHit != c.expectedCacheHit {
+				t.Errorf("expected cache hit %t, got %t", c.expectedCacheHit, cacheHit)
+			}
+
+			if (token.Token == token2.Token) != c.expectedCacheHit {
+				t.Errorf("expected tokens %q and %q to be equal: %t", token.Token, token2.Token, c.expectedCacheHit)
+			}
+
+			expectedRequests := int32(2)
+			if c.expectedCacheHit {
+				expectedRequests = 1
+			}
+
+			if requests.Load() != expectedRequests {
+				t.Errorf("expected %d token requests, got %d", expectedRequests, requests.Load())
+			}
+		})
+	}
+}
+
+func TestGetCachedTokenFailure(t *testing.T) {
+	var requests atomic.Int32
+	cache := newTokenCache(defaultTokenCacheRefreshMargin)
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		return &testCountingCredential{
+			requests: &requests,
+			err:      fmt.Errorf("ze-get-token-error"),
+		}, nil
+	}
+
+	for range 2 {
+		_, cacheHit, errSummary, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, credentialConfig{Timeout: defaultTimeout})
+		if err == nil || err.Error() != "ze-get-token-error" {
+			t.Fatalf("expected error %q, got %v", "ze-get-token-error", err)
+		}
+
+		if cacheHit {
+			t.Errorf("expected failures not to be cache hits")
+		}
+
+		if errSummary != "Error getting token" {
+			t.Errorf("expected error summary %q, got %q", "Error getting token", errSummary)
+		}
+	}
+
+	if requests.Load() != 2 {
+		t.Errorf("expected failures not to be cached, got %d token requests", requests.Load())
+	}
+
+	if len(cache.entries) != 0 {
+		t.Errorf("expected failures not to create entries, got %d", len(cache.entries))
+	}
+}
+
+func TestGetCachedTokenConcurrent(t *testing.T) {
+	var requests atomic.Int32
+	cache := newTokenCache(defaultTokenCacheRefreshMargin)
+	getCredFn := testNewCountingCredentialFn(&requests, time.Hour)
+	cfg := credentialConfig{
+		ClientID: "ze-client",
+		Scopes:   []string{"ze-scope"},
+		Timeout:  defaultTimeout,
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			token, _, _, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, cfg)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if token.Token != "ze-token-1" {
+				t.Errorf("expected token %q, got %q", "ze-token-1", token.Token)
+			}
+		})
+	}
+	wg.Wait()
+
+	if requests.Load() != 1 {
+		t.Errorf("expected a single token request, got %d", requests.Load())
+	}
+
+	key, err := newTokenCacheKey(clientSecretCredential, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if refs := cache.entries[key].refs; refs != 10 {
+		t.Errorf("expected every request to hold the entry, got %d holds", refs)
+	}
+}
+
+func TestTokenCacheRelease(t *testing.T) {
+	var requests atomic.Int32
+	cache := newTokenCache(defaultTokenCacheRefreshMargin)
+	getCredFn := testNewCountingCredentialFn(&requests, time.Hour)
+	cfg := credentialConfig{
+		ClientID: "ze-client",
+		Scopes:   []string{"ze-scope"},
+		Timeout:  defaultTimeout,
+	}
+
+	key, err := newTokenCacheKey(clientSecretCredential, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Two resources hold the entry.
+	for range 2 {
+		_, _, _, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if refs := cache.entries[key].refs; refs != 2 {
+		t.Errorf("expected 2 holds on the entry, got %d", refs)
+	}
+
+	cache.release(key)
+	cache.release(key)
+	cache.release(key)
+
+	// The token outlives the resources until it expires.
+	token, cacheHit, _, err := getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !cacheHit || token.Token != "ze-token-1" {
+		t.Errorf("expected the cached token after releasing, got %q with cache hit %t", token.Token, cacheHit)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 token request, got %d", requests.Load())
+	}
+}
+
+func TestTokenCacheEvict(t *testing.T) {
+	var requests atomic.Int32
+	cache := newTokenCache(defaultTokenCacheRefreshMargin)
+	getCredFn := testNewCountingCredentialFn(&requests, -time.Minute)
+	cfg := credentialConfig{
+		ClientID: "ze-client",
+		Scopes:   []string{"ze-scope"},
+		Timeout:  defaultTimeout,
+	}
+
+	key, err := newTokenCacheKey(clientSecretCredential, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, _, _, err = getCachedToken(t.Context(), cache, clientSecretCredential, getCredFn, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// An expired token stays cached while a resource holds it, as it may still
+	// be renewed.
+	cache.release("ze-other-key")
+	if _, ok := cache.entries[key]; !ok {
+		t.Fatal("expected the held entry to be kept")
+	}
+
+	cache.release(key)
+	if _, ok := cache.entries[key]; ok {
+		t.Error("expected the expired entry to be evicted once released")
+	}
+}
+
+func TestGetCachedTokenWithoutCache(t *testing.T) {
+	var requests atomic.Int32
+	getCredFn := testNewCountingCredentialFn(&requests, time.Hour)
+
+	for range 2 {
+		_, cacheHit, _, err := getCachedToken(t.Context(), nil, clientSecretCredential, getCredFn, credentialConfig{Timeout: defaultTimeout})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if cacheHit {
+			t.Errorf("expected no cache hit without a cache")
+		}
+	}
+
+	if requests.Load() != 2 {
+		t.Errorf("expected 2 token requests, got %d", requests.Load())
+	}
+}
+
+func TestGetCachedChainedToken(t *testing.T) {
+	var requests atomic.Int32
+	countingFn := testNewCountingCredentialFn(&requests, time.Hour)
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		if credType == workloadIdentityCredential {
+			return nil, fmt.Errorf("ze-workload-identity-error")
+		}
+
+		return countingFn(credType, cfg)
+	}
+
+	newLinks := func(clientSecret string) []*chainedCredentialLink {
+		return []*chainedCredentialLink{
+			{name: "workload_identity", credType: workloadIdentityCredential},
+			{name: "client_secret", credType: clientSecretCredential, cfg: credentialConfig{TenantID: "ze-tenant", ClientID: "ze-client", ClientSecret: clientSecret}},
+		}
+	}
+
+	cfg := credentialConfig{Scopes: []string{"ze-scope"}, Timeout: defaultTimeout}
+	cache := newTokenCache(defaultTokenCacheRefreshMargin)
+	cases := []struct {
+		clientSecret     string
+		expectedToken    string
+		expectedCacheHit bool
+	}{
+		{clientSecret: "ze-secret", expectedToken: "ze-token-1"},
+		{clientSecret: "ze-secret", expectedToken: "ze-token-1", expectedCacheHit: true},
+		{clientSecret: "ze-other-secret", expectedToken: "ze-token-2"},
+	}
+
+	for i, c := range cases {
+		token, index, cacheHit, _, err := getCachedChainedToken(t.Context(), cache, getCredFn, newLinks(c.clientSecret), cfg)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %s", i, err)
+		}
+
+		if token.Token != c.expectedToken || index != 1 || cacheHit != c.expectedCacheHit {
+			t.Errorf("%d: expected %s from source 1 with cache hit %t, got %s from source %d with cache hit %t", i, c.expectedToken, c.expectedCacheHit, token.Token, index, cacheHit)
+		}
+	}
+}
+
+func TestEphemeralCredentialTokenCache(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var requests atomic.Int32
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewCountingCredentialFn(&requests, time.Hour)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+ephemeral "azidentity_client_secret_credential" "that" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+provider "echo" {
+  data = {
+    tokens     = toset([ephemeral.azidentity_client_secret_credential.this.access_token, ephemeral.azidentity_client_secret_credential.that.access_token])
+    cache_hits = toset([ephemeral.azidentity_client_secret_credential.this.cache_hit, ephemeral.azidentity_client_secret_credential.that.cache_hit])
+  }
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("tokens"),
+						knownvalue.SetSizeExact(1),
+					),
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data").AtMapKey("cache_hits"),
+						knownvalue.SetPartial([]knownvalue.Check{
+							knownvalue.Bool(true),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestEphemeralCredentialTokenCacheRefreshMargin(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	var requests atomic.Int32
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_10_0),
+		},
+		IsUnitTest:               true,
+		ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, testNewCountingCredentialFn(&requests, time.Hour)),
+		Steps: []resource.TestStep{
+			{
+				Config: `
+provider "azidentity" {
+	token_cache_refresh_margin = "ze-invalid"
+}
+
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+provider "echo" {
+  data = ephemeral.azidentity_client_secret_credential.this.access_token
+}
+
+resource "echo" "this" {}
+`,
+				ExpectError: regexp.MustCompile(`Invalid Token Cache Refresh Margin`),
+			},
+			{
+				Config: `
+provider "azidentity" {
+	token_cache_refresh_margin = "2h"
+}
+
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+ephemeral "azidentity_client_secret_credential" "that" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["ze-scope"]
+}
+
+provider "echo" {
+  data = [
+    ephemeral.azidentity_client_secret_credential.this.cache_hit,
+    ephemeral.azidentity_client_secret_credential.that.cache_hit,
+  ]
+}
+
+resource "echo" "this" {}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"echo.this",
+						tfjsonpath.New("data"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.Bool(false),
+							knownvalue.Bool(false),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
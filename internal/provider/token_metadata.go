@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+// tokenTypeBearer is the type of every token acquired by the credentials, as
+// azidentity only acquires bearer tokens.
+const tokenTypeBearer = "Bearer"
+
+// tokenMetadata describes an access token, with the claims identifying the
+// identity it was issued to. The claims are null for tokens that aren't JWTs
+// or lack them.
+type tokenMetadata struct {
+	RefreshOn        types.String
+	TokenType        types.String
+	ExpiresInSeconds types.Int64
+	TenantID         types.String
+	ObjectID         types.String
+	AppID            types.String
+	Audience         types.String
+}
+
+// newTokenMetadata decodes the claims of the token without verifying it, the
+// token is only inspected and never trusted.
+func newTokenMetadata(token azcore.AccessToken) tokenMetadata {
+	metadata := tokenMetadata{
+		RefreshOn:        types.StringNull(),
+		TokenType:        types.StringValue(tokenTypeBearer),
+		ExpiresInSeconds: types.Int64Value(int64(max(time.Until(token.ExpiresOn), 0) / time.Second)),
+		TenantID:         types.StringNull(),
+		ObjectID:         types.StringNull(),
+		AppID:            types.StringNull(),
+		Audience:         types.StringNull(),
+	}
+
+	if !token.RefreshOn.IsZero() {
+		metadata.RefreshOn = types.StringValue(token.RefreshOn.Format(time.RFC3339))
+	}
+
+	parsedToken, err := jwt.ParseString(token.Token, jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return metadata
+	}
+
+	metadata.TenantID = tokenClaim(parsedToken, "tid")
+	metadata.ObjectID = tokenClaim(parsedToken, "oid")
+	// v1.0 tokens identify the application with appid, v2.0 tokens with azp.
+	metadata.AppID = tokenClaim(parsedToken, "appid")
+	if metadata.AppID.IsNull() {
+		metadata.AppID = tokenClaim(parsedToken, "azp")
+	}
+
+	if audience, ok := parsedToken.Audience(); ok && len(audience) > 0 {
+		metadata.Audience = types.StringValue(strings.Join(audience, ","))
+	}
+
+	return metadata
+}
+
+// tokenModel is embedded in the model of each credential resource, with the
+// issued access token and its metadata.
+type tokenModel struct {
+	AccessToken      types.String `tfsdk:"access_token"`
+	ExpiresOn        types.String `tfsdk:"expires_on"`
+	RefreshOn        types.String `tfsdk:"refresh_on"`
+	TokenType        types.String `tfsdk:"token_type"`
+	ExpiresInSeconds types.Int64  `tfsdk:"expires_in_seconds"`
+	TokenTenantID    types.String `tfsdk:"token_tenant_id"`
+	TokenObjectID    types.String `tfsdk:"token_object_id"`
+	TokenAppID       types.String `tfsdk:"token_app_id"`
+	TokenAudience    types.String `tfsdk:"token_audience"`
+}
+
+// setToken sets the model from the issued access token.
+func (m *tokenModel) setToken(token azcore.AccessToken) {
+	metadata := newTokenMetadata(token)
+	m.AccessToken = types.StringValue(token.Token)
+	m.ExpiresOn = types.StringValue(token.ExpiresOn.Format(time.RFC3339))
+	m.RefreshOn = metadata.RefreshOn
+	m.TokenType = metadata.TokenType
+	m.ExpiresInSeconds = metadata.ExpiresInSeconds
+	m.TokenTenantID = metadata.TenantID
+	m.TokenObjectID = metadata.ObjectID
+	m.TokenAppID = metadata.AppID
+	m.TokenAudience = metadata.Audience
+}
+
+// withTokenSchemaAttributes adds the attributes of tokenModel to the schema
+// attributes of a credential resource.
+func withTokenSchemaAttributes(attributes map[string]schema.Attribute) map[string]schema.Attribute {
+	attributes["access_token"] = schema.StringAttribute{
+		MarkdownDescription: "The issued access token, acquired when the resource was opened. Renewing the resource can't replace it, Terraform keeps this token for the rest of the operation.",
+		Computed:            true,
+		Sensitive:           true,
+	}
+	attributes["expires_on"] = schema.StringAttribute{
+		MarkdownDescription: "When the issued access token expires in RFC3339 format.",
+		Computed:            true,
+	}
+	attributes["refresh_on"] = schema.StringAttribute{
+		MarkdownDescription: "When the issued access token should be refreshed in RFC3339 format, as suggested by Microsoft Entra ID. Null when no refresh time was suggested.",
+		Computed:            true,
+	}
+	attributes["token_type"] = schema.StringAttribute{
+		MarkdownDescription: "The type of the issued access token, always 'Bearer'.",
+		Computed:            true,
+	}
+	attributes["expires_in_seconds"] = schema.Int64Attribute{
+		MarkdownDescription: "The number of seconds the issued access token was valid for when the resource was opened.",
+		Computed:            true,
+	}
+	attributes["token_tenant_id"] = schema.StringAttribute{
+		MarkdownDescription: "The tenant that issued the access token, from its `tid` claim. The claims are decoded without verifying the token, and are null for tokens that are not JWTs or lack the claim.",
+		Computed:            true,
+	}
+	attributes["token_object_id"] = schema.StringAttribute{
+		MarkdownDescription: "The object ID of the identity the access token was issued to, from its `oid` claim.",
+		Computed:            true,
+	}
+	attributes["token_app_id"] = schema.StringAttribute{
+		MarkdownDescription: "The application ID of the client the access token was issued to, from its `appid` claim, or `azp` for v2.0 tokens.",
+		Computed:            true,
+	}
+	attributes["token_audience"] = schema.StringAttribute{
+		MarkdownDescription: "The audience of the issued access token, from its `aud` claim, comma separated when there are several.",
+		Computed:            true,
+	}
+
+	return attributes
+}
+
+func tokenClaim(token jwt.Token, name string) types.String {
+	var value string
+	err := token.Get(name, &value)
+	if err != nil || value == "" {
+		return types.StringNull()
+	}
+
+	return types.StringValue(value)
+}
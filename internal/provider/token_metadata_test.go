@@ -0,0 +1,246 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+// testNewAccessTokenJWT returns an unsigned access token with the given
+// claims.
+func testNewAccessTokenJWT(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %s", err)
+	}
+
+	return strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)),
+		base64.RawURLEncoding.EncodeToString(payload),
+		base64.RawURLEncoding.EncodeToString([]byte("ze-signature")),
+	}, ".")
+}
+
+func TestNewTokenMetadata(t *testing.T) {
+	expiresOn := time.Now().Add(time.Hour)
+	refreshOn := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		token    func(t *testing.T) azcore.AccessToken
+		expected tokenMetadata
+	}{
+		{
+			name: "v1_token",
+			token: func(t *testing.T) azcore.AccessToken {
+				return azcore.AccessToken{
+					Token: testNewAccessTokenJWT(t, map[string]any{
+						"aud":   "https://management.azure.com",
+						"tid":   "ze-tenant",
+						"oid":   "ze-object",
+						"appid": "ze-app",
+						"azp":   "ze-other-app",
+					}),
+					ExpiresOn: expiresOn,
+					RefreshOn: refreshOn,
+				}
+			},
+			expected: tokenMetadata{
+				RefreshOn: types.StringValue("2030-01-02T03:04:05Z"),
+				TenantID:  types.StringValue("ze-tenant"),
+				ObjectID:  types.StringValue("ze-object"),
+				AppID:     types.StringValue("ze-app"),
+				Audience:  types.StringValue("https://management.azure.com"),
+			},
+		},
+		{
+			name: "v2_token",
+			token: func(t *testing.T) azcore.AccessToken {
+				return azcore.AccessToken{
+					Token: testNewAccessTokenJWT(t, map[string]any{
+						"aud": []string{"ze-audience-1", "ze-audience-2"},
+						"tid": "ze-tenant",
+						"azp": "ze-app",
+					}),
+					ExpiresOn: expiresOn,
+				}
+			},
+			expected: tokenMetadata{
+				RefreshOn: types.StringNull(),
+				TenantID:  types.StringValue("ze-tenant"),
+				ObjectID:  types.StringNull(),
+				AppID:     types.StringValue("ze-app"),
+				Audience:  types.StringValue("ze-audience-1,ze-audience-2"),
+			},
+		},
+		{
+			name: "opaque_token",
+			token: func(t *testing.T) azcore.AccessToken {
+				return azcore.AccessToken{
+					Token:     "ze-token",
+					ExpiresOn: expiresOn,
+				}
+			},
+			expected: tokenMetadata{
+				RefreshOn: types.StringNull(),
+				TenantID:  types.StringNull(),
+				ObjectID:  types.StringNull(),
+				AppID:     types.StringNull(),
+				Audience:  types.StringNull(),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			metadata := newTokenMetadata(c.token(t))
+
+			if metadata.TokenType.ValueString() != "Bearer" {
+				t.Errorf("expected token type Bearer, got %s", metadata.TokenType)
+			}
+
+			if expiresIn := metadata.ExpiresInSeconds.ValueInt64(); expiresIn > 3600 || expiresIn < 3590 {
+				t.Errorf("expected the token to expire in an hour, got %d seconds", expiresIn)
+			}
+
+			for name, values := range map[string][2]types.String{
+				"refresh_on": {c.expected.RefreshOn, metadata.RefreshOn},
+				"tenant_id":  {c.expected.TenantID, metadata.TenantID},
+				"object_id":  {c.expected.ObjectID, metadata.ObjectID},
+				"app_id":     {c.expected.AppID, metadata.AppID},
+				"audience":   {c.expected.Audience, metadata.Audience},
+			} {
+				if !values[0].Equal(values[1]) {
+					t.Errorf("expected %s %s, got %s", name, values[0], values[1])
+				}
+			}
+		})
+	}
+}
+
+func TestNewTokenMetadataExpired(t *testing.T) {
+	metadata := newTokenMetadata(azcore.AccessToken{
+		Token:     "ze-token",
+		ExpiresOn: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	if metadata.ExpiresInSeconds.ValueInt64() != 0 {
+		t.Errorf("expected an expired token to expire in 0 seconds, got %d", metadata.ExpiresInSeconds.ValueInt64())
+	}
+}
+
+// testJWTCredential returns a JWT access token for the client of the
+// credential config.
+type testJWTCredential struct {
+	t   *testing.T
+	cfg credentialConfig
+}
+
+func (c *testJWTCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{
+		Token: testNewAccessTokenJWT(c.t, map[string]any{
+			"aud":   strings.TrimSuffix(options.Scopes[0], "/.default"),
+			"tid":   c.cfg.TenantID,
+			"oid":   "ze-object",
+			"appid": c.cfg.ClientID,
+		}),
+		ExpiresOn: time.Now().Add(time.Hour),
+		RefreshOn: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}, nil
+}
+
+var _ azcore.TokenCredential = (*testJWTCredential)(nil)
+
+func TestEphemeralCredentialTokenMetadata(t *testing.T) {
+	testClearProviderDefaultsEnv(t)
+
+	getCredFn := func(credType credentialType, cfg credentialConfig) (azcore.TokenCredential, error) {
+		return &testJWTCredential{t: t, cfg: cfg}, nil
+	}
+
+	for _, resourceType := range []string{"azidentity_client_secret_credential", "azidentity_chained_credential"} {
+		t.Run(resourceType, func(t *testing.T) {
+			config := `
+ephemeral "azidentity_client_secret_credential" "this" {
+	tenant_id     = "ze-tenant"
+	client_id     = "ze-client"
+	client_secret = "ze-secret"
+	scopes        = ["https://management.azure.com/.default"]
+}
+`
+			if resourceType == "azidentity_chained_credential" {
+				config = `
+ephemeral "azidentity_chained_credential" "this" {
+	scopes = ["https://management.azure.com/.default"]
+
+	sources = [
+		{
+			client_secret = {
+				tenant_id     = "ze-tenant"
+				client_id     = "ze-client"
+				client_secret = "ze-secret"
+			}
+		},
+	]
+}
+`
+			}
+
+			resource.Test(t, resource.TestCase{
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					tfversion.SkipBelow(tfversion.Version1_10_0),
+				},
+				IsUnitTest:               true,
+				ProtoV6ProviderFactories: testProtoV6ProviderFactoriesWithEcho(t, getCredFn),
+				Steps: []resource.TestStep{
+					{
+						Config: config + `
+provider "echo" {
+  data = {
+    refresh_on         = ephemeral.` + resourceType + `.this.refresh_on
+    token_type         = ephemeral.` + resourceType + `.this.token_type
+    expires_in_seconds = ephemeral.` + resourceType + `.this.expires_in_seconds
+    token_tenant_id    = ephemeral.` + resourceType + `.this.token_tenant_id
+    token_object_id    = ephemeral.` + resourceType + `.this.token_object_id
+    token_app_id       = ephemeral.` + resourceType + `.this.token_app_id
+    token_audience     = ephemeral.` + resourceType + `.this.token_audience
+  }
+}
+
+resource "echo" "this" {}
+`,
+						ConfigStateChecks: []statecheck.StateCheck{
+							statecheck.ExpectKnownValue(
+								"echo.this",
+								tfjsonpath.New("data"),
+								knownvalue.ObjectExact(map[string]knownvalue.Check{
+									"refresh_on":         knownvalue.StringExact("2030-01-02T03:04:05Z"),
+									"token_type":         knownvalue.StringExact("Bearer"),
+									"expires_in_seconds": knownvalue.NotNull(),
+									"token_tenant_id":    knownvalue.StringExact("ze-tenant"),
+									"token_object_id":    knownvalue.StringExact("ze-object"),
+									"token_app_id":       knownvalue.StringExact("ze-client"),
+									"token_audience":     knownvalue.StringExact("https://management.azure.com"),
+								}),
+							),
+						},
+					},
+				},
+			})
+		})
+	}
+}